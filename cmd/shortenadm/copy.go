@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+// Source database schema differs from the embedded one, copy would lose or
+// misread columns
+var errSchemaVersion = errors.New("source schema is not migrated to the latest version, run migrate up on it")
+
+// Users and records of storage. Click history is not part of it, click limited
+// records keep remaining clicks
+type dataset struct {
+	users   []models.User
+	records []models.Record
+}
+
+func copyCommand(ctx context.Context, e env, args []string) error {
+	flags := commandFlags(e, "copy", "SOURCE TARGET")
+	if err := parseCommandFlags(flags, args, 2, 2); err != nil {
+		return err
+	}
+	source, target := flags.Arg(0), flags.Arg(1)
+
+	var data dataset
+	var err error
+	if isDSN(source) {
+		data, err = loadDB(ctx, source)
+	} else {
+		data, err = loadFile(source)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+
+	if isDSN(target) {
+		err = storeDB(ctx, target, data)
+	} else {
+		err = storeFile(e, target, data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+
+	deleted := 0
+	for _, record := range data.records {
+		if record.IsDeleted {
+			deleted++
+		}
+	}
+	fmt.Fprintf(e.stdout, "copied %d users and %d records (%d deleted)\n", len(data.users), len(data.records), deleted)
+
+	return nil
+}
+
+func isDSN(storage string) bool {
+	return strings.HasPrefix(storage, "postgres://") || strings.HasPrefix(storage, "postgresql://")
+}
+
+// Source database is read as is, it must be migrated to the embedded schema
+// version beforehand
+func loadDB(ctx context.Context, dsn string) (dataset, error) {
+	db, err := storage.OpenDBStorage(dsn)
+	if err != nil {
+		return dataset{}, err
+	}
+	defer db.Close()
+
+	version, dirty, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return dataset{}, err
+	}
+	latest, err := storage.LatestSchemaVersion()
+	if err != nil {
+		return dataset{}, err
+	}
+	if dirty || version != latest {
+		return dataset{}, fmt.Errorf("%w: version %d, want %d", errSchemaVersion, version, latest)
+	}
+
+	var data dataset
+	if data.users, err = db.Users(ctx); err != nil {
+		return dataset{}, err
+	}
+	err = db.Records(ctx, func(record models.Record) error {
+		data.records = append(data.records, record)
+		return nil
+	})
+
+	return data, err
+}
+
+// Snapshot must be verified, otherwise restore of file and database storages
+// would keep different records. Snapshot users are the owners of records
+func loadFile(path string) (dataset, error) {
+	fs := storage.NewFileStorage(path)
+	report, err := fs.Verify()
+	if err != nil {
+		return dataset{}, err
+	}
+	if !report.OK() {
+		return dataset{}, errSnapshotNotOK
+	}
+	records, err := fs.Snapshot()
+	if err != nil {
+		return dataset{}, err
+	}
+
+	userIDs := make(map[int]struct{})
+	for _, record := range records {
+		if record.UserID > 0 {
+			userIDs[record.UserID] = struct{}{}
+		}
+	}
+	users := make([]models.User, 0, len(userIDs))
+	for id := range userIDs {
+		users = append(users, models.User{ID: id})
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	return dataset{users: users, records: records}, nil
+}
+
+func storeDB(ctx context.Context, dsn string, data dataset) error {
+	db, err := storage.NewDBStorage(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Restore(ctx, data.users, data.records)
+}
+
+// Snapshot keeps users having records only, so restored file storage counts
+// users up to the greatest user ID having records
+func storeFile(e env, path string, data dataset) error {
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+		return errors.New("snapshot file is not empty")
+	}
+
+	owners := make(map[int]struct{})
+	for _, record := range data.records {
+		owners[record.UserID] = struct{}{}
+	}
+	withoutRecords := 0
+	for _, user := range data.users {
+		if _, ok := owners[user.ID]; !ok {
+			withoutRecords++
+		}
+	}
+	if withoutRecords > 0 {
+		fmt.Fprintf(e.stderr, "shortenadm: %d users without records are not kept in snapshot, their IDs may be reused\n", withoutRecords)
+	}
+
+	return storage.NewFileStorage(path).Write(data.records)
+}
@@ -0,0 +1,121 @@
+// Command shortenadm is an offline maintenance tool of shortener storages. It
+// applies and rolls back database migrations, verifies and compacts file
+// storage snapshots and copies data between file and database storages.
+//
+//	shortenadm <command> [flags] [args]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const usage = `Usage: shortenadm <command> [flags] [args]
+
+Commands:
+  migrate up [-steps N]           apply pending database migrations
+  migrate down (-steps N | -all)  roll back database migrations
+  migrate version                 show database schema version
+  snapshot verify [FILE]          check file storage snapshot
+  snapshot compact [FILE]         drop malformed, invalid and duplicate snapshot records
+  copy SOURCE TARGET              copy users and URLs between storages
+
+Database is given by -d flag or DATABASE_DSN, snapshot file by FILE or
+FILE_STORAGE_PATH. Storages of copy are database URLs starting with
+postgres:// or postgresql://, or snapshot files otherwise. Source database
+is not migrated by copy and must be at the latest schema version.
+`
+
+type command func(ctx context.Context, e env, args []string) error
+
+// Command environment
+type env struct {
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+}
+
+var commands = map[string]command{
+	"migrate":  migrateCommand,
+	"snapshot": snapshotCommand,
+	"copy":     copyCommand,
+}
+
+// Error of command line usage
+var errUsage = errors.New("usage error")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], env{stdout: os.Stdout, stderr: os.Stderr, getenv: os.Getenv})
+	stop()
+	os.Exit(code)
+}
+
+// Run command, returns exit code: 0 on success, 1 on failure and 2 on usage
+// error
+func run(ctx context.Context, args []string, e env) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		fmt.Fprint(e.stderr, usage)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(e.stderr, "shortenadm: unknown command %q\n%s", args[0], usage)
+		return 2
+	}
+
+	err := cmd(ctx, e, args[1:])
+	if errors.Is(err, errUsage) {
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(e.stderr, "shortenadm:", err)
+		return 1
+	}
+
+	return 0
+}
+
+// Flag set of command. Parse errors are reported to stderr
+func commandFlags(e env, name, argsUsage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: shortenadm %s [flags] %s\n", name, argsUsage)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+func parseCommandFlags(flags *flag.FlagSet, args []string, minArgs, maxArgs int) error {
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() < minArgs || flags.NArg() > maxArgs {
+		flags.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+// Subcommand name and its arguments
+func subcommand(e env, args []string, subcommands ...string) (string, []string, error) {
+	if len(args) != 0 {
+		for _, sub := range subcommands {
+			if args[0] == sub {
+				return sub, args[1:], nil
+			}
+		}
+	}
+	fmt.Fprint(e.stderr, usage)
+
+	return "", nil, errUsage
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	adm := func(environ map[string]string, args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := run(context.Background(), args, env{
+			stdout: &stdout,
+			stderr: &stderr,
+			getenv: func(key string) string { return environ[key] },
+		})
+
+		return code, stdout.String(), stderr.String()
+	}
+
+	snapshotPath := filepath.Join(dir, "storage.json")
+	lines := []string{
+		`{"original_url":"http://example.com","shortened_path":"1","user_id":1}`,
+		`{"original_url":"http://example.org","shortened_path":"2","user_id":3,"is_deleted":true}`,
+		`{"original_url":"http://example.com","shortened_path":"3","user_id":1}`,
+	}
+	require.NoError(t, os.WriteFile(snapshotPath, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+
+	t.Run("verifies snapshot", func(t *testing.T) {
+		code, stdout, stderr := adm(nil, "snapshot", "verify", snapshotPath)
+		assert.Equal(t, 1, code)
+		assert.Equal(t, "lines: 3\nrecords: 2 (1 deleted)\nusers: 2\nduplicate lines: 3\n", stdout)
+		assert.Equal(t, "shortenadm: "+errSnapshotNotOK.Error()+"\n", stderr)
+	})
+
+	t.Run("refuses to copy unverified snapshot", func(t *testing.T) {
+		code, _, stderr := adm(nil, "copy", snapshotPath, filepath.Join(dir, "copy.json"))
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, errSnapshotNotOK.Error())
+	})
+
+	t.Run("compacts snapshot", func(t *testing.T) {
+		code, stdout, stderr := adm(map[string]string{"FILE_STORAGE_PATH": snapshotPath}, "snapshot", "compact")
+		require.Equal(t, 0, code, stderr)
+		assert.Contains(t, stdout, "compacted 3 lines to 2 records\n")
+
+		code, _, stderr = adm(nil, "snapshot", "verify", snapshotPath)
+		assert.Equal(t, 0, code, stderr)
+	})
+
+	t.Run("copies snapshot keeping codes, users and deletion state", func(t *testing.T) {
+		copyPath := filepath.Join(dir, "copy.json")
+		code, stdout, stderr := adm(nil, "copy", snapshotPath, copyPath)
+		require.Equal(t, 0, code, stderr)
+		assert.Equal(t, "copied 2 users and 2 records (1 deleted)\n", stdout)
+
+		records, err := storage.NewFileStorage(copyPath).Snapshot()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "1", records[0].ShortenedPath)
+		assert.Equal(t, 1, records[0].UserID)
+		assert.Equal(t, "2", records[1].ShortenedPath)
+		assert.Equal(t, 3, records[1].UserID)
+		assert.True(t, records[1].IsDeleted)
+
+		code, _, stderr = adm(nil, "copy", snapshotPath, copyPath)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "snapshot file is not empty")
+	})
+
+	t.Run("requires database URL for migrations", func(t *testing.T) {
+		code, _, stderr := adm(nil, "migrate", "up")
+		assert.Equal(t, 1, code)
+		assert.Equal(t, "shortenadm: database URL is not set\n", stderr)
+	})
+
+	t.Run("reports usage errors", func(t *testing.T) {
+		for _, args := range [][]string{
+			{},
+			{"unknown"},
+			{"migrate"},
+			{"migrate", "down", "-d", "postgres://localhost/db"},
+			{"migrate", "down", "-d", "postgres://localhost/db", "-steps", "1", "-all"},
+			{"snapshot", "verify", "a", "b"},
+			{"copy", snapshotPath},
+		} {
+			code, _, _ := adm(nil, args...)
+			assert.Equal(t, 2, code, args)
+		}
+	})
+}
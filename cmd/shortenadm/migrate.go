@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+func migrateCommand(ctx context.Context, e env, args []string) error {
+	sub, args, err := subcommand(e, args, "up", "down", "version")
+	if err != nil {
+		return err
+	}
+
+	flags := commandFlags(e, "migrate "+sub, "")
+	dsn := flags.String("d", e.getenv("DATABASE_DSN"), "database URL")
+	var steps int
+	var all bool
+	if sub != "version" {
+		flags.IntVar(&steps, "steps", 0, "number of migrations, all pending ones by default for up")
+	}
+	if sub == "down" {
+		flags.BoolVar(&all, "all", false, "roll back all migrations, dropping all data")
+	}
+	if err = parseCommandFlags(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *dsn == "" {
+		return errors.New("database URL is not set")
+	}
+	if steps < 0 || (sub == "down" && (steps == 0) == !all) {
+		flags.Usage()
+		return errUsage
+	}
+
+	migrator, err := storage.NewMigrator(*dsn)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	var changed bool
+	switch sub {
+	case "up":
+		changed, err = migrator.Up(steps)
+	case "down":
+		changed, err = migrator.Down(steps)
+	}
+	if err != nil {
+		return err
+	}
+	if sub != "version" && !changed {
+		fmt.Fprintln(e.stdout, "no change")
+	}
+
+	version, dirty, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		fmt.Fprintf(e.stdout, "version %d (dirty)\n", version)
+	} else {
+		fmt.Fprintf(e.stdout, "version %d\n", version)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+// Snapshot verification failure, snapshot is readable but has to be compacted
+var errSnapshotNotOK = errors.New("snapshot has records restore would skip, compact it")
+
+func snapshotCommand(ctx context.Context, e env, args []string) error {
+	sub, args, err := subcommand(e, args, "verify", "compact")
+	if err != nil {
+		return err
+	}
+
+	flags := commandFlags(e, "snapshot "+sub, "[FILE]")
+	var purgeDeleted bool
+	if sub == "compact" {
+		flags.BoolVar(&purgeDeleted, "purge-deleted", false, "drop deleted records too")
+	}
+	if err = parseCommandFlags(flags, args, 0, 1); err != nil {
+		return err
+	}
+	path := flags.Arg(0)
+	if path == "" {
+		path = e.getenv("FILE_STORAGE_PATH")
+	}
+	if path == "" {
+		return errors.New("snapshot file is not set")
+	}
+
+	fs := storage.NewFileStorage(path)
+	if sub == "verify" {
+		report, err := fs.Verify()
+		if err != nil {
+			return err
+		}
+		printReport(e.stdout, report)
+		if !report.OK() {
+			return errSnapshotNotOK
+		}
+		return nil
+	}
+
+	report, err := fs.Compact(purgeDeleted)
+	if err != nil {
+		return err
+	}
+	printReport(e.stdout, report)
+	kept := report.Records
+	if purgeDeleted {
+		kept -= report.Deleted
+	}
+	fmt.Fprintf(e.stdout, "compacted %d lines to %d records\n", report.Lines, kept)
+
+	return nil
+}
+
+func printReport(w io.Writer, report storage.SnapshotReport) {
+	fmt.Fprintf(w, "lines: %d\n", report.Lines)
+	fmt.Fprintf(w, "records: %d (%d deleted)\n", report.Records, report.Deleted)
+	fmt.Fprintf(w, "users: %d\n", report.Users)
+	printLines(w, "malformed", report.MalformedLines)
+	printLines(w, "invalid", report.InvalidLines)
+	printLines(w, "duplicate", report.DuplicateLines)
+}
+
+func printLines(w io.Writer, kind string, lines []int) {
+	if len(lines) == 0 {
+		return
+	}
+	numbers := make([]string, len(lines))
+	for i, line := range lines {
+		numbers[i] = strconv.Itoa(line)
+	}
+	fmt.Fprintf(w, "%s lines: %s\n", kind, strings.Join(numbers, ", "))
+}
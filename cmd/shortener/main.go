@@ -11,17 +11,32 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/acme/autocert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	// Decompress gzipped requests of clients
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 
 	"github.com/ilya-burinskiy/urlshort/internal/app/configs"
 	"github.com/ilya-burinskiy/urlshort/internal/app/handlers"
+	"github.com/ilya-burinskiy/urlshort/internal/app/handlers/graphql"
 	pb "github.com/ilya-burinskiy/urlshort/internal/app/handlers/grpc"
 	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
+	"github.com/ilya-burinskiy/urlshort/internal/app/metrics"
 	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
 	"github.com/ilya-burinskiy/urlshort/internal/app/services"
 	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+	"github.com/ilya-burinskiy/urlshort/internal/app/tracing"
+)
+
+const (
+	shutdownTimeout     = 10 * time.Second
+	healthCheckInterval = 5 * time.Second
 )
 
 var (
@@ -36,42 +51,87 @@ func main() {
 		panic(err)
 	}
 	showBuildInfo()
+	shutdownTracing, err := tracing.Initialize(config.TraceExporter, config.OTLPEndpoint, buildVersion)
+	if err != nil {
+		panic(err)
+	}
 
-	store := configureStorage(config)
+	rawStore := configureStorage(config)
+	store := metrics.NewStorage(rawStore)
+	if db, ok := rawStore.(*storage.DBStorage); ok {
+		prometheus.MustRegister(metrics.NewPoolCollector(db.Stat))
+	}
 	urlCreateService := services.NewURLShortener(
 		8,
 		services.RandHexStrGenerator{},
 		store,
 	)
 	userAuthenticator := services.NewUserAuthenticator(store)
+	urlUpdater := services.NewURLUpdater(store)
 	urlDeleter := services.NewDeferredDeleter(store)
 	ipChecker := services.NewIPChecker(config)
+	urlImporter := services.NewURLImporter(8, services.RandHexStrGenerator{}, store, config.ShortURL)
+	importJobs := services.NewImportJobs(urlImporter, services.RandHexStrGenerator{})
+	urlExporter := services.NewURLExporter(store, config.ShortURL)
+	prometheus.MustRegister(metrics.NewDeleterQueueCollector(urlDeleter.QueueLen))
 	go urlDeleter.Run()
-	go startGRPCServer(config, store, userAuthenticator, ipChecker, urlCreateService, urlDeleter)
-	startHTTPServer(config, store, userAuthenticator, ipChecker, urlCreateService, urlDeleter)
+
+	urlsServer := pb.NewURLsServer(
+		config, store, userAuthenticator, urlCreateService, urlUpdater, urlDeleter, urlExporter,
+	)
+	grpcServer, healthServer := newGRPCServer(config, urlsServer, userAuthenticator, ipChecker)
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	go pb.WatchStorageHealth(healthCtx, healthServer, store, healthCheckInterval)
+	gatewayServer, gateway := newGateway(config, urlsServer, userAuthenticator, ipChecker)
+	httpServer := newHTTPServer(
+		config, store, userAuthenticator, ipChecker, urlCreateService, urlUpdater, urlDeleter,
+		urlImporter, importJobs, urlExporter, gateway,
+	)
+
+	exit := make(chan os.Signal, 1)
+	signal.Notify(exit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	stopped := make(chan struct{})
+	go func() {
+		<-exit
+		stopHealth()
+		onExit(httpServer, grpcServer, healthServer, gatewayServer, gateway, rawStore, shutdownTracing)
+		close(stopped)
+	}()
+
+	go startGRPCServer(config, grpcServer)
+	startHTTPServer(config, httpServer)
+	<-stopped
 }
 
-func startHTTPServer(
+func newHTTPServer(
 	config configs.Config,
 	store storage.Storage,
 	userAuthenticator services.UserAuthenticator,
 	ipChecker services.IPChecker,
 	shortener services.URLShortener,
-	urlDeleter services.DeferredDeleter) {
+	urlUpdater services.URLUpdater,
+	urlDeleter services.DeferredDeleter,
+	urlImporter services.URLImporter,
+	importJobs *services.ImportJobs,
+	urlExporter services.URLExporter,
+	gateway http.Handler) *http.Server {
 
-	server := http.Server{
-		Handler: configureRouter(store, config, userAuthenticator, ipChecker, shortener, urlDeleter),
-		Addr:    config.ServerAddress,
+	return &http.Server{
+		Handler: configureRouter(
+			store, config, userAuthenticator, ipChecker, shortener, urlUpdater, urlDeleter,
+			urlImporter, importJobs, urlExporter, gateway,
+		),
+		Addr: config.ServerAddress,
 	}
-	exit := make(chan os.Signal, 1)
-	signal.Notify(exit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-	go onExit(exit, &server, store)
+}
 
+func startHTTPServer(config configs.Config, server *http.Server) {
 	var serveErr error
 	if config.UseHTTPS() {
+		// Certificates are issued for hosts of base URL and additional domains
 		manager := &autocert.Manager{
 			Prompt:     autocert.AcceptTOS,
-			HostPolicy: autocert.HostWhitelist("urlshort.ru"),
+			HostPolicy: autocert.HostWhitelist(config.Hosts()...),
 		}
 		server.TLSConfig = manager.TLSConfig()
 		serveErr = server.ListenAndServeTLS("", "")
@@ -84,35 +144,128 @@ func startHTTPServer(
 	}
 }
 
-func startGRPCServer(
+func newGRPCServer(
 	config configs.Config,
-	store storage.Storage,
+	urlsServer pb.URLsServer,
 	userAuthenticator services.UserAuthenticator,
-	ipChecker services.IPChecker,
-	shortener services.URLShortener,
-	urlDeleter services.DeferredDeleter) {
+	ipChecker services.IPChecker) (*grpc.Server, *health.Server) {
 
-	listen, err := net.Listen("tcp", config.GRPCServerAddress)
-	if err != nil {
-		panic(err)
+	// Tracing and metrics interceptors come first to observe calls rejected by
+	// the others. Gateway calls are counted as HTTP requests only
+	opts := append(
+		[]grpc.ServerOption{
+			grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor, metrics.UnaryServerInterceptor),
+			grpc.ChainStreamInterceptor(tracing.StreamServerInterceptor, metrics.StreamServerInterceptor),
+		},
+		grpcInterceptors(config, userAuthenticator, ipChecker)...,
+	)
+	if config.UseGRPCTLS() {
+		tlsConfig, err := pb.ServerTLSConfig(config.GRPCCertFile, config.GRPCKeyFile, config.GRPCClientCAFile)
+		if err != nil {
+			panic(err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	} else if config.UseHTTPS() {
+		logger.Log.Info("grpc server listens in plaintext, set grpc certificate and key to enable TLS")
 	}
-	srv := grpc.NewServer(
+	srv := grpc.NewServer(opts...)
+	pb.RegisterURLServiceServer(srv, urlsServer)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(srv, healthServer)
+	if config.EnableGRPCReflection {
+		reflection.Register(srv)
+	}
+
+	return srv, healthServer
+}
+
+func grpcInterceptors(
+	config configs.Config,
+	userAuthenticator services.UserAuthenticator,
+	ipChecker services.IPChecker) []grpc.ServerOption {
+
+	return []grpc.ServerOption{
 		grpc.ChainUnaryInterceptor(
 			pb.AuthenticateInterceptor(userAuthenticator),
-			pb.TrustedIPInterceptor(ipChecker),
+			pb.TrustedIPInterceptor(ipChecker, config.GRPCTrustedClients),
 		),
+		grpc.ChainStreamInterceptor(
+			pb.StreamAuthenticateInterceptor(userAuthenticator),
+			pb.StreamTrustedIPInterceptor(ipChecker, config.GRPCTrustedClients),
+		),
+	}
+}
+
+// Gateway has its own plaintext gRPC server, connection to it never leaves the process
+func newGateway(
+	config configs.Config,
+	urlsServer pb.URLsServer,
+	userAuthenticator services.UserAuthenticator,
+	ipChecker services.IPChecker) (*grpc.Server, *pb.Gateway) {
+
+	// Gateway calls continue traces of HTTP requests
+	opts := append(
+		[]grpc.ServerOption{
+			grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor),
+			grpc.ChainStreamInterceptor(tracing.StreamServerInterceptor),
+		},
+		grpcInterceptors(config, userAuthenticator, ipChecker)...,
 	)
-	pb.RegisterURLServiceServer(
-		srv,
-		pb.NewURLsServer(config, store, userAuthenticator, shortener, urlDeleter),
-	)
+	srv := grpc.NewServer(opts...)
+	pb.RegisterURLServiceServer(srv, urlsServer)
+	gateway, err := pb.NewGateway(context.Background(), srv)
+	if err != nil {
+		panic(err)
+	}
+
+	return srv, gateway
+}
+
+func startGRPCServer(config configs.Config, srv *grpc.Server) {
+	listen, err := net.Listen("tcp", config.GRPCServerAddress)
+	if err != nil {
+		panic(err)
+	}
 	if err := srv.Serve(listen); err != nil {
 		panic(err)
 	}
 }
 
-func onExit(exit <-chan os.Signal, server *http.Server, s storage.Storage) {
-	<-exit
+// Servers are stopped before storage is closed, so in-flight requests finish.
+// Requests still running after shutdown timeout are cancelled
+func onExit(
+	server *http.Server,
+	grpcServer *grpc.Server,
+	healthServer *health.Server,
+	gatewayServer *grpc.Server,
+	gateway *pb.Gateway,
+	s storage.Storage,
+	shutdownTracing func(context.Context) error) {
+
+	healthServer.Shutdown()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+	// Gateway requests are finished by HTTP server shutdown
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Log.Info("failed to shutdown", zap.Error(err))
+	}
+	if err := gateway.Close(); err != nil {
+		logger.Log.Info("failed to close gateway", zap.Error(err))
+	}
+	gatewayServer.Stop()
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		logger.Log.Info("failed to stop grpc server gracefully", zap.Error(ctx.Err()))
+		grpcServer.Stop()
+	}
+
 	switch s := s.(type) {
 	case *storage.MapStorage:
 		err := s.Dump()
@@ -123,8 +276,11 @@ func onExit(exit <-chan os.Signal, server *http.Server, s storage.Storage) {
 		s.Close()
 	}
 
-	if err := server.Shutdown(context.TODO()); err != nil {
-		logger.Log.Info("failed to shutdown", zap.Error(err))
+	// Spans of requests finished during shutdown are flushed last
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		logger.Log.Info("failed to shutdown tracing", zap.Error(err))
 	}
 }
 
@@ -134,11 +290,20 @@ func configureRouter(
 	userAuthenticator services.UserAuthenticator,
 	ipChecker services.IPChecker,
 	shortener services.URLShortener,
-	urlDeleter services.DeferredDeleter) chi.Router {
+	urlUpdater services.URLUpdater,
+	urlDeleter services.DeferredDeleter,
+	urlImporter services.URLImporter,
+	importJobs *services.ImportJobs,
+	urlExporter services.URLExporter,
+	gateway http.Handler) chi.Router {
 
 	router := chi.NewRouter()
+	v1 := handlers.NewV1(handlers.NewHandlers(config, store))
 	handlers := handlers.NewHandlers(config, store)
 	router.Use(
+		tracing.Middleware,
+		metrics.Middleware,
+		middleware.RequestID,
 		middlewares.ResponseLogger,
 		middlewares.RequestLogger,
 		middlewares.GzipCompress,
@@ -148,22 +313,60 @@ func configureRouter(
 		router.Use(middleware.AllowContentType("text/plain", "application/x-gzip"))
 		router.Post("/", handlers.CreateURL(shortener, userAuthenticator))
 		router.Get("/{id}", handlers.GetOriginalURL)
+		router.Head("/{id}", handlers.GetOriginalURL)
+		router.Get("/{id}/qr", handlers.GetQRCode)
 		router.Get("/ping", handlers.PingDB)
 	})
 	router.Group(func(router chi.Router) {
 		router.Use(middleware.AllowContentType("application/json", "application/x-gzip"))
 		router.Post("/api/shorten", handlers.CreateURLFromJSON(shortener, userAuthenticator))
 		router.Post("/api/shorten/batch", handlers.BatchCreateURL(shortener, userAuthenticator))
+		router.Get("/api/urls/{id}", handlers.GetURLPreview)
 		router.Group(func(router chi.Router) {
 			router.Use(middlewares.Authenticate(userAuthenticator))
 			router.Get("/api/user/urls", handlers.GetUserURLs)
+			router.Get("/api/user/urls/export", handlers.ExportUserURLs(urlExporter))
+			router.Patch("/api/user/urls/{id}", handlers.UpdateUserURL(urlUpdater))
+			router.Get("/api/user/urls/{id}/stats", handlers.GetUserURLStats)
 			router.Delete("/api/user/urls", handlers.DeleteUserURLs(urlDeleter))
+			router.Get("/api/user/import/{id}", handlers.GetImportJob(importJobs))
+			router.Post("/api/graphql", graphql.NewHandler(config, store, shortener, urlUpdater, urlDeleter).ServeHTTP)
 		})
 	})
+	router.Group(func(router chi.Router) {
+		router.Use(
+			middleware.AllowContentType("text/csv", "application/x-gzip"),
+			middlewares.Authenticate(userAuthenticator),
+		)
+		router.Post("/api/user/import", handlers.ImportURLs(urlImporter, importJobs))
+	})
 	router.Group(func(router chi.Router) {
 		router.Use(middlewares.OnlyTrustedIP(ipChecker), middleware.AllowContentType("application/json"))
 		router.Get("/api/internal/stats", handlers.GetStats)
 	})
+	router.Route("/api/v1", func(router chi.Router) {
+		router.NotFound(v1.NotFound)
+		router.MethodNotAllowed(v1.MethodNotAllowed)
+		router.Post("/urls", v1.CreateURL(shortener, userAuthenticator))
+		router.Post("/urls/batch", v1.BatchCreateURL(shortener, userAuthenticator))
+		router.Get("/urls/{id}", v1.GetURLPreview)
+		router.Group(func(router chi.Router) {
+			router.Use(middlewares.AuthenticateV1(userAuthenticator))
+			router.Get("/user/urls", v1.GetUserURLs)
+			router.Get("/user/urls/export", v1.ExportUserURLs(urlExporter))
+			router.Patch("/user/urls/{id}", v1.UpdateUserURL(urlUpdater))
+			router.Get("/user/urls/{id}/stats", v1.GetUserURLStats)
+			router.Delete("/user/urls", v1.DeleteUserURLs(urlDeleter))
+			router.Post("/user/import", v1.ImportURLs(urlImporter, importJobs))
+			router.Get("/user/import/{id}", v1.GetImportJob(importJobs))
+		})
+		router.With(middlewares.OnlyTrustedIPV1(ipChecker)).Get("/internal/stats", v1.GetStats)
+	})
+	router.With(middlewares.OnlyAdmin(ipChecker, config.MetricsToken)).Get("/metrics", metrics.Handler().ServeHTTP)
+	router.Get("/api/openapi.json", handlers.GetOpenAPISpec)
+	router.Get("/api/docs", handlers.GetDocs)
+	router.Get("/api/docs/{file}", handlers.GetDocsAsset)
+	router.Mount("/api/v2", gateway)
 
 	return router
 }
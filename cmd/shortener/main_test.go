@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/configs"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+func TestOpenAPIDescribesRoutes(t *testing.T) {
+	config := configs.Config{BaseURL: "http://localhost:8080"}
+	store := storage.NewMapStorage(nil)
+	urlImporter := services.NewURLImporter(8, services.RandHexStrGenerator{}, store, config.ShortURL)
+	router := configureRouter(
+		store,
+		config,
+		services.NewUserAuthenticator(store),
+		services.NewIPChecker(config),
+		services.NewURLShortener(8, services.RandHexStrGenerator{}, store),
+		services.NewURLUpdater(store),
+		services.NewDeferredDeleter(store),
+		urlImporter,
+		services.NewImportJobs(urlImporter, services.RandHexStrGenerator{}),
+		services.NewURLExporter(store, config.ShortURL),
+		http.NotFoundHandler(),
+	)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	response, err := testServer.Client().Get(testServer.URL + "/api/openapi.json")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&spec))
+
+	documented := make(map[string]bool)
+	err = chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		// Gateway is described by document generated from urls.proto
+		if strings.HasPrefix(route, "/api/v2/") {
+			return nil
+		}
+		_, ok := spec.Paths[route][strings.ToLower(method)]
+		assert.True(t, ok, "%s %s is not documented", method, route)
+		documented[strings.ToLower(method)+" "+route] = true
+		return nil
+	})
+	require.NoError(t, err)
+
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if method == "parameters" {
+				continue
+			}
+			assert.True(t, documented[method+" "+path], "%s %s is not routed", method, path)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/ilya-burinskiy/urlshort/pkg/exitizer"
 
@@ -205,7 +206,7 @@ Registered analyzers:
 	    usesgenerics detect whether a package uses generics features
 		errcheck     checks unchecked errors
 		bodyclose    checks whenether res.Body is correctly closed
-		exitizer     checks for os.Exic calls in main funcion
+		exitizer     checks for os.Exic calls in main funcion, except of exempted commands
 */
 func main() {
 	analyzers := []*analysis.Analyzer{
@@ -260,9 +261,23 @@ func main() {
 		exitizer.Analyzer,
 	}
 	analyzers = append(analyzers, staticcheckAnalyzers()...)
+	exemptFromExitizer()
 	multichecker.Main(analyzers...)
 }
 
+// Command-line tools report failure by exit code of the process, which
+// only os.Exit can set. Their main calls it once, after resources are released
+var exitizerExempt = []string{
+	"github.com/ilya-burinskiy/urlshort/cmd/urlshortctl",
+	"github.com/ilya-burinskiy/urlshort/cmd/shortenadm",
+}
+
+func exemptFromExitizer() {
+	if err := exitizer.Analyzer.Flags.Set("exempt", strings.Join(exitizerExempt, ",")); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to configure exitizer: %s\n", err.Error())
+	}
+}
+
 func staticcheckAnalyzers() []*analysis.Analyzer {
 	configFname := "staticcheck.json"
 	appfile, err := os.Executable()
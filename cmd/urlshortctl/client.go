@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Shortener API client. Implementations keep JWT issued by the server in session
+type client interface {
+	Shorten(ctx context.Context, originalURL string, opts shortenOptions) (string, error)
+	BatchShorten(ctx context.Context, originalURLs []string, domain string) ([]batchResult, error)
+	List(ctx context.Context, filter listFilter) ([]userURL, error)
+	Delete(ctx context.Context, codes []string, domain string) error
+	Stats(ctx context.Context) (stats, error)
+	Resolve(ctx context.Context, code, domain string) (string, error)
+	Close() error
+}
+
+// JWT shared by requests of one run
+type session struct {
+	jwt string
+	// Client address as seen by server, sent for trusted subnet check
+	realIP string
+}
+
+type shortenOptions struct {
+	Domain      string
+	Title       string
+	Description string
+	Tags        []string
+	Folder      string
+}
+
+type listFilter struct {
+	Tags   []string
+	Folder string
+	Query  string
+	// Zero means no limit
+	Limit int
+	// Newest URLs first
+	Desc bool
+}
+
+// Short URL of batch item. Correlation ID is the position of original URL in
+// input, starting from 1
+type batchResult struct {
+	CorrelationID string `json:"correlation_id"`
+	OriginalURL   string `json:"original_url"`
+	ShortURL      string `json:"short_url"`
+}
+
+type userURL struct {
+	ShortURL    string    `json:"short_url"`
+	OriginalURL string    `json:"original_url"`
+	Title       string    `json:"title,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Folder      string    `json:"folder,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type stats struct {
+	URLs  int `json:"urls"`
+	Users int `json:"users"`
+}
+
+// Error of already shortened URL
+type errAlreadyShortened struct {
+	ShortURL string
+}
+
+func (err *errAlreadyShortened) Error() string {
+	return "original URL is already shortened as " + err.ShortURL
+}
+
+// Fill original URLs of batch results by correlation ID
+func withOriginalURLs(results []batchResult, originalURLs []string) []batchResult {
+	for i := range results {
+		line, err := strconv.Atoi(results[i].CorrelationID)
+		if err == nil && line >= 1 && line <= len(originalURLs) {
+			results[i].OriginalURL = originalURLs[line-1]
+		}
+	}
+
+	return results
+}
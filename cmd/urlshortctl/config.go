@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Persistent client settings. Server and transport are defaults for flags, JWT
+// is saved after each command so the same user is used on the next run
+type ctlConfig struct {
+	Server    string `json:"server,omitempty"`
+	Transport string `json:"transport,omitempty"`
+	JWT       string `json:"jwt,omitempty"`
+}
+
+// Config file in user config directory
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".urlshortctl.json"
+	}
+
+	return filepath.Join(dir, "urlshortctl", "config.json")
+}
+
+// Load config file, missing file is empty config
+func loadConfig(path string) (ctlConfig, error) {
+	var config ctlConfig
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// Save config file readable by user only, it keeps JWT
+func saveConfig(path string, config ctlConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err = os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/ilya-burinskiy/urlshort/internal/app/handlers/grpc"
+)
+
+// Client of gRPC URL service
+type grpcClient struct {
+	conn    *grpc.ClientConn
+	client  pb.URLServiceClient
+	session *session
+}
+
+// Dial without TLS if tlsConfig is nil
+func newGRPCClient(ctx context.Context, address string, s *session, useGzip bool, tlsConfig *tls.Config) (*grpcClient, error) {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if useGzip {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+	}
+	conn, err := grpc.DialContext(ctx, address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %w", address, err)
+	}
+
+	return &grpcClient{conn: conn, client: pb.NewURLServiceClient(conn), session: s}, nil
+}
+
+// Client TLS config. Server certificate is verified against caFile or system
+// roots if caFile is empty, client certificate is sent if certFile and keyFile are given
+func clientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA: %w", err)
+		}
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("failed to parse CA: no certificates found")
+		}
+		config.RootCAs = rootCAs
+	}
+	if certFile == "" && keyFile == "" {
+		return config, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("client certificate and key must be given together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	config.Certificates = []tls.Certificate{cert}
+
+	return config, nil
+}
+
+func (c *grpcClient) Shorten(ctx context.Context, originalURL string, opts shortenOptions) (string, error) {
+	var header metadata.MD
+	response, err := c.client.CreateURL(
+		c.outgoing(ctx),
+		&pb.CreateURLRequest{
+			OriginalUrl: originalURL,
+			Domain:      opts.Domain,
+			Title:       opts.Title,
+			Description: opts.Description,
+			Tags:        opts.Tags,
+			Folder:      opts.Folder,
+		},
+		grpc.Header(&header),
+	)
+	c.updateJWT(header)
+	if err != nil {
+		return "", statusError(err)
+	}
+
+	return response.ShortUrl, nil
+}
+
+func (c *grpcClient) BatchShorten(ctx context.Context, originalURLs []string, domain string) ([]batchResult, error) {
+	items := make([]*pb.BatchCreateURLRequest_Item, len(originalURLs))
+	for i, originalURL := range originalURLs {
+		items[i] = &pb.BatchCreateURLRequest_Item{
+			OriginalUrl:   originalURL,
+			CorrelationId: strconv.Itoa(i + 1),
+			Domain:        domain,
+		}
+	}
+	var header metadata.MD
+	response, err := c.client.BatchCreateURL(c.outgoing(ctx), &pb.BatchCreateURLRequest{Items: items}, grpc.Header(&header))
+	c.updateJWT(header)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	results := make([]batchResult, len(response.Items))
+	for i, item := range response.Items {
+		results[i] = batchResult{CorrelationID: item.CorrelationId, ShortURL: item.ShortUrl}
+	}
+
+	return withOriginalURLs(results, originalURLs), nil
+}
+
+func (c *grpcClient) List(ctx context.Context, filter listFilter) ([]userURL, error) {
+	request := &pb.GetUserURLsRequest{
+		Tags:   filter.Tags,
+		Folder: filter.Folder,
+		Query:  filter.Query,
+		Limit:  int32(filter.Limit),
+	}
+	if filter.Desc {
+		request.Order = "desc"
+	}
+	response, err := c.client.GetUserURLs(c.outgoing(ctx), request)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	urls := make([]userURL, len(response.Items))
+	for i, item := range response.Items {
+		urls[i] = userURL{
+			ShortURL:    item.ShortUrl,
+			OriginalURL: item.OriginalUrl,
+			Title:       item.Title,
+			Tags:        item.Tags,
+			Folder:      item.Folder,
+			CreatedAt:   item.CreatedAt.AsTime(),
+		}
+	}
+
+	return urls, nil
+}
+
+func (c *grpcClient) Delete(ctx context.Context, shortPaths []string, domain string) error {
+	_, err := c.client.DeleteUserURLs(c.outgoing(ctx), &pb.DeleteUserURLsRequest{ShortUrls: shortPaths, Domain: domain})
+	if err != nil {
+		return statusError(err)
+	}
+
+	return nil
+}
+
+func (c *grpcClient) Stats(ctx context.Context) (stats, error) {
+	response, err := c.client.GetStats(c.outgoing(ctx), &pb.GetStatsRequest{})
+	if err != nil {
+		return stats{}, statusError(err)
+	}
+
+	return stats{URLs: int(response.Urls), Users: int(response.Users)}, nil
+}
+
+func (c *grpcClient) Resolve(ctx context.Context, code, domain string) (string, error) {
+	response, err := c.client.GetURLPreview(c.outgoing(ctx), &pb.GetURLPreviewRequest{ShortUrl: code, Domain: domain})
+	if err != nil {
+		return "", statusError(err)
+	}
+
+	return response.OriginalUrl, nil
+}
+
+func (c *grpcClient) Close() error {
+	return c.conn.Close()
+}
+
+// JWT and client address are sent as metadata
+func (c *grpcClient) outgoing(ctx context.Context) context.Context {
+	if c.session.jwt != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "jwt", c.session.jwt)
+	}
+	if c.session.realIP != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-real-ip", c.session.realIP)
+	}
+
+	return ctx
+}
+
+func (c *grpcClient) updateJWT(header metadata.MD) {
+	if values := header.Get("jwt"); len(values) != 0 && values[0] != "" {
+		c.session.jwt = values[0]
+	}
+}
+
+// Status message as error. Details of already exists status hold the short URL
+// of existing link
+func statusError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	if st.Code() == codes.AlreadyExists {
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.ResourceInfo); ok && info.ResourceType == "short_url" {
+				return &errAlreadyShortened{ShortURL: info.ResourceName}
+			}
+		}
+	}
+
+	return errors.New(st.Message())
+}
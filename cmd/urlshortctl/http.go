@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/apierror"
+)
+
+// Client of versioned JSON API
+type httpClient struct {
+	baseURL string
+	client  *http.Client
+	session *session
+	// Compress request bodies and accept compressed responses
+	gzip bool
+}
+
+func newHTTPClient(baseURL string, s *session, useGzip bool) *httpClient {
+	return &httpClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Transport: &http.Transport{DisableCompression: !useGzip}},
+		session: s,
+		gzip:    useGzip,
+	}
+}
+
+func (c *httpClient) Shorten(ctx context.Context, originalURL string, opts shortenOptions) (string, error) {
+	requestBody := struct {
+		URL         string   `json:"url"`
+		Domain      string   `json:"domain,omitempty"`
+		Title       string   `json:"title,omitempty"`
+		Description string   `json:"description,omitempty"`
+		Tags        []string `json:"tags,omitempty"`
+		Folder      string   `json:"folder,omitempty"`
+	}{
+		URL:         originalURL,
+		Domain:      opts.Domain,
+		Title:       opts.Title,
+		Description: opts.Description,
+		Tags:        opts.Tags,
+		Folder:      opts.Folder,
+	}
+	var response struct {
+		ShortURL string `json:"short_url"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/urls", nil, requestBody, &response); err != nil {
+		return "", err
+	}
+
+	return response.ShortURL, nil
+}
+
+func (c *httpClient) BatchShorten(ctx context.Context, originalURLs []string, domain string) ([]batchResult, error) {
+	type item struct {
+		OriginalURL   string `json:"original_url"`
+		CorrelationID string `json:"correlation_id"`
+		Domain        string `json:"domain,omitempty"`
+	}
+	items := make([]item, len(originalURLs))
+	for i, originalURL := range originalURLs {
+		items[i] = item{OriginalURL: originalURL, CorrelationID: strconv.Itoa(i + 1), Domain: domain}
+	}
+	var results []batchResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/urls/batch", nil, items, &results); err != nil {
+		return nil, err
+	}
+
+	return withOriginalURLs(results, originalURLs), nil
+}
+
+func (c *httpClient) List(ctx context.Context, filter listFilter) ([]userURL, error) {
+	query := url.Values{}
+	for _, tag := range filter.Tags {
+		query.Add("tag", tag)
+	}
+	if filter.Folder != "" {
+		query.Set("folder", filter.Folder)
+	}
+	if filter.Query != "" {
+		query.Set("q", filter.Query)
+	}
+	if filter.Limit != 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Desc {
+		query.Set("order", "desc")
+	}
+	var page struct {
+		Items []userURL `json:"items"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/user/urls", query, nil, &page); err != nil {
+		return nil, err
+	}
+
+	return page.Items, nil
+}
+
+func (c *httpClient) Delete(ctx context.Context, codes []string, domain string) error {
+	query := url.Values{}
+	if domain != "" {
+		query.Set("domain", domain)
+	}
+
+	return c.do(ctx, http.MethodDelete, "/api/v1/user/urls", query, codes, nil)
+}
+
+func (c *httpClient) Stats(ctx context.Context) (stats, error) {
+	var result stats
+	err := c.do(ctx, http.MethodGet, "/api/v1/internal/stats", nil, nil, &result)
+
+	return result, err
+}
+
+func (c *httpClient) Resolve(ctx context.Context, code, domain string) (string, error) {
+	query := url.Values{}
+	if domain != "" {
+		query.Set("domain", domain)
+	}
+	var preview struct {
+		OriginalURL string `json:"original_url"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/urls/"+url.PathEscape(code), query, nil, &preview); err != nil {
+		return "", err
+	}
+
+	return preview.OriginalURL, nil
+}
+
+func (c *httpClient) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// Send request with JWT cookie and decode response into out. JWT cookie of
+// response replaces session JWT, error envelope is returned as error
+func (c *httpClient) do(
+	ctx context.Context,
+	method string,
+	path string,
+	query url.Values,
+	requestBody interface{},
+	out interface{}) error {
+
+	var body io.Reader
+	contentType := "application/json"
+	if requestBody != nil {
+		data, err := json.Marshal(requestBody)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		if c.gzip {
+			if data, err = compress(data); err != nil {
+				return fmt.Errorf("failed to compress request: %w", err)
+			}
+			contentType = "application/x-gzip"
+		}
+		body = bytes.NewReader(data)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if len(query) != 0 {
+		request.URL.RawQuery = query.Encode()
+	}
+	if requestBody != nil {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if c.session.jwt != "" {
+		request.AddCookie(&http.Cookie{Name: "jwt", Value: c.session.jwt})
+	}
+	if c.session.realIP != "" {
+		request.Header.Set("X-Real-IP", c.session.realIP)
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	for _, cookie := range response.Cookies() {
+		if cookie.Name == "jwt" {
+			c.session.jwt = cookie.Value
+		}
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		var apiErr apierror.Error
+		if err = json.NewDecoder(response.Body).Decode(&apiErr); err != nil || apiErr.Message == "" {
+			return fmt.Errorf("unexpected response status %s", response.Status)
+		}
+		if apiErr.Code == apierror.CodeAlreadyExists && apiErr.Details["short_url"] != "" {
+			return &errAlreadyShortened{ShortURL: apiErr.Details["short_url"]}
+		}
+		return errors.New(apiErr.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	if err = json.NewDecoder(response.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
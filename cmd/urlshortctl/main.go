@@ -0,0 +1,369 @@
+// Command urlshortctl is a command-line client of the shortener API. It speaks
+// versioned JSON API over HTTP or URL service over gRPC and keeps JWT issued by
+// the server in a config file, so commands of one user can be scripted without
+// cookie handling.
+//
+//	urlshortctl [flags] <command> [command flags] [args]
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Transports
+const (
+	transportHTTP = "http"
+	transportGRPC = "grpc"
+)
+
+// Default server addresses of transports
+var defaultServers = map[string]string{
+	transportHTTP: "http://localhost:8080",
+	transportGRPC: "localhost:3200",
+}
+
+const usage = `Usage: urlshortctl [flags] <command> [command flags] [args]
+
+Commands:
+  shorten URL       shorten URL
+  batch [FILE]      shorten URLs of FILE, one per line, stdin if FILE is - or absent
+  list              list user URLs
+  delete CODE...    delete user URLs in background
+  stats             show URLs and users count, client must be in trusted subnet
+  resolve CODE      show original URL without counting a click
+
+Flags:
+`
+
+// Command environment
+type env struct {
+	client  client
+	stdin   io.Reader
+	stderr  io.Writer
+	printer printer
+}
+
+type command func(ctx context.Context, e env, args []string) error
+
+var commands = map[string]command{
+	"shorten": shortenCommand,
+	"batch":   batchCommand,
+	"list":    listCommand,
+	"delete":  deleteCommand,
+	"stats":   statsCommand,
+	"resolve": resolveCommand,
+}
+
+// Error of command line usage
+var errUsage = errors.New("usage error")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// Run command, returns exit code: 0 on success, 1 on failed request and 2 on
+// usage error
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("urlshortctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configPath := flags.String("config", defaultConfigPath(), "config file with default server, transport and saved JWT")
+	server := flags.String("server", "", "server address, "+
+		defaultServers[transportHTTP]+" for HTTP and "+defaultServers[transportGRPC]+" for gRPC by default")
+	transport := flags.String("transport", "", "transport, http or grpc (default http)")
+	output := flags.String("output", outputTable, "output format, table or json")
+	useGzip := flags.Bool("gzip", false, "compress requests and responses")
+	realIP := flags.String("real-ip", "", "client address sent for trusted subnet check")
+	timeout := flags.Duration("timeout", 30*time.Second, "request timeout")
+	caFile := flags.String("ca", "", "CA certificate to verify gRPC server, enables TLS")
+	certFile := flags.String("cert", "", "client certificate for gRPC mutual TLS, enables TLS")
+	keyFile := flags.String("key", "", "client certificate key for gRPC mutual TLS")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "urlshortctl: unknown command %q\n", flags.Arg(0))
+		flags.Usage()
+		return 2
+	}
+	if *output != outputTable && *output != outputJSON {
+		fmt.Fprintf(stderr, "urlshortctl: unknown output format %q\n", *output)
+		return 2
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "urlshortctl:", err)
+		return 1
+	}
+	if *transport == "" {
+		*transport = config.Transport
+	}
+	if *transport == "" {
+		*transport = transportHTTP
+	}
+	if _, ok := defaultServers[*transport]; !ok {
+		fmt.Fprintf(stderr, "urlshortctl: unknown transport %q\n", *transport)
+		return 2
+	}
+	if *server == "" {
+		*server = config.Server
+	}
+	if *server == "" {
+		*server = defaultServers[*transport]
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	s := &session{jwt: config.JWT, realIP: *realIP}
+	var c client
+	if *transport == transportGRPC {
+		var tlsConfig *tls.Config
+		if *caFile != "" || *certFile != "" || *keyFile != "" {
+			tlsConfig, err = clientTLSConfig(*caFile, *certFile, *keyFile)
+			if err != nil {
+				fmt.Fprintln(stderr, "urlshortctl:", err)
+				return 1
+			}
+		}
+		c, err = newGRPCClient(ctx, *server, s, *useGzip, tlsConfig)
+		if err != nil {
+			fmt.Fprintln(stderr, "urlshortctl:", err)
+			return 1
+		}
+	} else {
+		c = newHTTPClient(*server, s, *useGzip)
+	}
+	defer c.Close()
+
+	err = cmd(ctx, env{client: c, stdin: stdin, stderr: stderr, printer: printer{w: stdout, format: *output}}, flags.Args()[1:])
+	if s.jwt != config.JWT {
+		config.JWT = s.jwt
+		if saveErr := saveConfig(*configPath, config); saveErr != nil {
+			fmt.Fprintln(stderr, "urlshortctl:", saveErr)
+		}
+	}
+	if errors.Is(err, errUsage) {
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "urlshortctl:", err)
+		return 1
+	}
+
+	return 0
+}
+
+// Flag set of command. Parse errors are reported to stderr
+func commandFlags(e env, name, argsUsage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: urlshortctl %s [flags] %s\n", name, argsUsage)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+func parseCommandFlags(flags *flag.FlagSet, args []string, minArgs, maxArgs int) error {
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() < minArgs || (maxArgs >= 0 && flags.NArg() > maxArgs) {
+		flags.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+func shortenCommand(ctx context.Context, e env, args []string) error {
+	flags := commandFlags(e, "shorten", "URL")
+	var opts shortenOptions
+	flags.StringVar(&opts.Domain, "domain", "", "short domain, the default one if empty")
+	flags.StringVar(&opts.Title, "title", "", "title")
+	flags.StringVar(&opts.Description, "description", "", "description")
+	flags.Var((*stringsFlag)(&opts.Tags), "tag", "tag, may be repeated")
+	flags.StringVar(&opts.Folder, "folder", "", "folder")
+	if err := parseCommandFlags(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	shortURL, err := e.client.Shorten(ctx, flags.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+
+	return e.printer.print(map[string]string{"short_url": shortURL}, nil, [][]string{{shortURL}})
+}
+
+func batchCommand(ctx context.Context, e env, args []string) error {
+	flags := commandFlags(e, "batch", "[FILE]")
+	domain := flags.String("domain", "", "short domain, the default one if empty")
+	if err := parseCommandFlags(flags, args, 0, 1); err != nil {
+		return err
+	}
+
+	input := e.stdin
+	if name := flags.Arg(0); name != "" && name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+	originalURLs, err := readURLs(input)
+	if err != nil {
+		return err
+	}
+	if len(originalURLs) == 0 {
+		return errors.New("no URLs to shorten")
+	}
+
+	results, err := e.client.BatchShorten(ctx, originalURLs, *domain)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(results))
+	for i, result := range results {
+		rows[i] = []string{result.CorrelationID, result.OriginalURL, result.ShortURL}
+	}
+
+	return e.printer.print(results, []string{"#", "ORIGINAL URL", "SHORT URL"}, rows)
+}
+
+func listCommand(ctx context.Context, e env, args []string) error {
+	flags := commandFlags(e, "list", "")
+	var filter listFilter
+	flags.Var((*stringsFlag)(&filter.Tags), "tag", "URLs having tag, may be repeated")
+	flags.StringVar(&filter.Folder, "folder", "", "URLs in folder")
+	flags.StringVar(&filter.Query, "q", "", "substring of original URL or code")
+	flags.IntVar(&filter.Limit, "limit", 0, "max number of newest URLs, zero means no limit")
+	if err := parseCommandFlags(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if filter.Limit != 0 {
+		// Limited list is a page of the newest URLs
+		filter.Desc = true
+	}
+
+	urls, err := e.client.List(ctx, filter)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(urls))
+	for i, u := range urls {
+		rows[i] = []string{
+			u.ShortURL,
+			u.OriginalURL,
+			u.Title,
+			strings.Join(u.Tags, ","),
+			u.Folder,
+			u.CreatedAt.Format(time.RFC3339),
+		}
+	}
+
+	return e.printer.print(urls, []string{"SHORT URL", "ORIGINAL URL", "TITLE", "TAGS", "FOLDER", "CREATED"}, rows)
+}
+
+func deleteCommand(ctx context.Context, e env, args []string) error {
+	flags := commandFlags(e, "delete", "CODE...")
+	domain := flags.String("domain", "", "short domain, the default one if empty")
+	if err := parseCommandFlags(flags, args, 1, -1); err != nil {
+		return err
+	}
+
+	if err := e.client.Delete(ctx, flags.Args(), *domain); err != nil {
+		return err
+	}
+
+	return e.printer.print(map[string][]string{"accepted": flags.Args()}, nil, nil)
+}
+
+func statsCommand(ctx context.Context, e env, args []string) error {
+	flags := commandFlags(e, "stats", "")
+	if err := parseCommandFlags(flags, args, 0, 0); err != nil {
+		return err
+	}
+
+	result, err := e.client.Stats(ctx)
+	if err != nil {
+		return err
+	}
+
+	return e.printer.print(
+		result,
+		[]string{"URLS", "USERS"},
+		[][]string{{strconv.Itoa(result.URLs), strconv.Itoa(result.Users)}},
+	)
+}
+
+func resolveCommand(ctx context.Context, e env, args []string) error {
+	flags := commandFlags(e, "resolve", "CODE")
+	domain := flags.String("domain", "", "short domain, the default one if empty")
+	if err := parseCommandFlags(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	originalURL, err := e.client.Resolve(ctx, flags.Arg(0), *domain)
+	if err != nil {
+		return err
+	}
+
+	return e.printer.print(map[string]string{"original_url": originalURL}, nil, [][]string{{originalURL}})
+}
+
+// Non empty lines, lines starting with # are comments
+func readURLs(r io.Reader) ([]string, error) {
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read URLs: %w", err)
+	}
+
+	return urls, nil
+}
+
+// Repeated string flag
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/configs"
+	"github.com/ilya-burinskiy/urlshort/internal/app/handlers"
+	pb "github.com/ilya-burinskiy/urlshort/internal/app/handlers/grpc"
+	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+var defaultConfig = configs.Config{
+	BaseURL:       "http://localhost:8080",
+	ServerAddress: "http://localhost:8080",
+	TrustedSubnet: "192.168.0.0/24",
+}
+
+// Serve versioned JSON API and gRPC URL service of one storage
+func startServers(t *testing.T, grpcOpts ...grpc.ServerOption) (string, string) {
+	store := storage.NewMapStorage(nil)
+	userAuthenticator := services.NewUserAuthenticator(store)
+	ipChecker := services.NewIPChecker(defaultConfig)
+	shortener := services.NewURLShortener(8, services.RandHexStrGenerator{}, store)
+	urlDeleter := services.NewDeferredDeleter(store)
+
+	v1 := handlers.NewV1(handlers.NewHandlers(defaultConfig, store))
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID, middlewares.GzipCompress)
+	router.Route("/api/v1", func(router chi.Router) {
+		router.Post("/urls", v1.CreateURL(shortener, userAuthenticator))
+		router.Post("/urls/batch", v1.BatchCreateURL(shortener, userAuthenticator))
+		router.Get("/urls/{id}", v1.GetURLPreview)
+		router.With(middlewares.AuthenticateV1(userAuthenticator)).Get("/user/urls", v1.GetUserURLs)
+		router.With(middlewares.AuthenticateV1(userAuthenticator)).Delete("/user/urls", v1.DeleteUserURLs(urlDeleter))
+		router.With(middlewares.OnlyTrustedIPV1(ipChecker)).Get("/internal/stats", v1.GetStats)
+	})
+	httpServer := httptest.NewServer(router)
+	t.Cleanup(httpServer.Close)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer(append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			pb.AuthenticateInterceptor(userAuthenticator),
+			pb.TrustedIPInterceptor(ipChecker, nil),
+		),
+	}, grpcOpts...)...)
+	pb.RegisterURLServiceServer(grpcServer, pb.NewURLsServer(
+		defaultConfig,
+		store,
+		userAuthenticator,
+		shortener,
+		services.NewURLUpdater(store),
+		urlDeleter,
+		services.NewURLExporter(store, defaultConfig.ShortURL),
+	))
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	return httpServer.URL, listener.Addr().String()
+}
+
+func TestRun(t *testing.T) {
+	httpURL, grpcAddress := startServers(t)
+	transports := []struct {
+		name   string
+		server string
+	}{
+		{name: transportHTTP, server: httpURL},
+		{name: transportGRPC, server: grpcAddress},
+	}
+
+	for _, transport := range transports {
+		t.Run(transport.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			ctl := func(stdin string, args ...string) (int, string, string) {
+				var stdout, stderr bytes.Buffer
+				args = append([]string{"-config", configPath, "-server", transport.server, "-transport", transport.name}, args...)
+				code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+
+				return code, stdout.String(), stderr.String()
+			}
+			// Original URLs are unique across transports as both use one storage
+			originalURL := "http://" + transport.name + ".example.com"
+
+			code, stdout, stderr := ctl("", "shorten", "-tag", "docs", "-title", "Example", originalURL)
+			require.Equal(t, 0, code, stderr)
+			shortURL := strings.TrimSpace(stdout)
+			require.True(t, strings.HasPrefix(shortURL, defaultConfig.BaseURL+"/"), shortURL)
+			shortPath := strings.TrimPrefix(shortURL, defaultConfig.BaseURL+"/")
+
+			config, err := loadConfig(configPath)
+			require.NoError(t, err)
+			require.NotEmpty(t, config.JWT)
+
+			t.Run("reports already shortened URL", func(t *testing.T) {
+				code, _, stderr := ctl("", "shorten", originalURL)
+				assert.Equal(t, 1, code)
+				assert.Equal(t, "urlshortctl: original URL is already shortened as "+shortURL+"\n", stderr)
+			})
+
+			t.Run("shortens URLs from stdin", func(t *testing.T) {
+				input := "# comment\n" + originalURL + "/a\n\n" + originalURL + "/b\n"
+				code, stdout, stderr := ctl(input, "-output", outputJSON, "batch")
+				require.Equal(t, 0, code, stderr)
+				var results []batchResult
+				require.NoError(t, json.Unmarshal([]byte(stdout), &results))
+				require.Len(t, results, 2)
+				assert.Equal(t, "1", results[0].CorrelationID)
+				assert.Equal(t, originalURL+"/a", results[0].OriginalURL)
+				assert.Equal(t, originalURL+"/b", results[1].OriginalURL)
+				assert.NotEmpty(t, results[1].ShortURL)
+			})
+
+			t.Run("shortens URLs from file with gzip", func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "urls.txt")
+				require.NoError(t, os.WriteFile(path, []byte(originalURL+"/c\n"), 0600))
+				code, stdout, stderr := ctl("", "-gzip", "batch", path)
+				require.Equal(t, 0, code, stderr)
+				lines := strings.Split(strings.TrimSpace(stdout), "\n")
+				require.Len(t, lines, 2)
+				assert.Equal(t, []string{"#", "ORIGINAL", "URL", "SHORT", "URL"}, strings.Fields(lines[0]))
+				assert.Equal(t, "1", strings.Fields(lines[1])[0])
+				assert.Equal(t, originalURL+"/c", strings.Fields(lines[1])[1])
+			})
+
+			t.Run("lists user URLs", func(t *testing.T) {
+				code, stdout, stderr := ctl("", "-output", outputJSON, "list", "-tag", "docs")
+				require.Equal(t, 0, code, stderr)
+				var urls []userURL
+				require.NoError(t, json.Unmarshal([]byte(stdout), &urls))
+				require.Len(t, urls, 1)
+				assert.Equal(t, originalURL, urls[0].OriginalURL)
+				assert.Equal(t, "Example", urls[0].Title)
+				assert.Equal(t, []string{"docs"}, urls[0].Tags)
+
+				code, stdout, stderr = ctl("", "list", "-limit", "2")
+				require.Equal(t, 0, code, stderr)
+				lines := strings.Split(strings.TrimSpace(stdout), "\n")
+				require.Len(t, lines, 3)
+				assert.True(t, strings.HasPrefix(lines[0], "SHORT URL"))
+				assert.Contains(t, lines[1], originalURL+"/c")
+			})
+
+			t.Run("resolves short URL", func(t *testing.T) {
+				code, stdout, stderr := ctl("", "resolve", shortPath)
+				require.Equal(t, 0, code, stderr)
+				assert.Equal(t, originalURL+"\n", stdout)
+
+				code, _, _ = ctl("", "resolve", "missing")
+				assert.Equal(t, 1, code)
+			})
+
+			t.Run("shows stats to trusted client only", func(t *testing.T) {
+				code, stdout, stderr := ctl("", "-real-ip", "192.168.0.10", "-output", outputJSON, "stats")
+				require.Equal(t, 0, code, stderr)
+				var result stats
+				require.NoError(t, json.Unmarshal([]byte(stdout), &result))
+				assert.Positive(t, result.URLs)
+				assert.Positive(t, result.Users)
+
+				code, _, _ = ctl("", "-real-ip", "10.0.0.1", "stats")
+				assert.Equal(t, 1, code)
+			})
+
+			t.Run("deletes user URLs", func(t *testing.T) {
+				code, stdout, stderr := ctl("", "delete", shortPath)
+				require.Equal(t, 0, code, stderr)
+				assert.Empty(t, stdout)
+			})
+
+			t.Run("reports usage errors", func(t *testing.T) {
+				code, _, _ := ctl("", "unknown")
+				assert.Equal(t, 2, code)
+				code, _, _ = ctl("", "shorten")
+				assert.Equal(t, 2, code)
+			})
+		})
+	}
+}
+
+// Generate certificate with key signed by parent, self-signed CA if parent is
+// nil. Certificate and key are written to dir as name.pem and name-key.pem
+func generateCert(t *testing.T, dir, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, name+"-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	require.NoError(t, err)
+
+	return cert, key
+}
+
+func TestRunGRPCTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := generateCert(t, dir, "ca", nil, nil)
+	generateCert(t, dir, "server", ca, caKey)
+	generateCert(t, dir, "client", ca, caKey)
+	tlsConfig, err := pb.ServerTLSConfig(
+		filepath.Join(dir, "server.pem"),
+		filepath.Join(dir, "server-key.pem"),
+		filepath.Join(dir, "ca.pem"),
+	)
+	require.NoError(t, err)
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	_, grpcAddress := startServers(t, grpc.Creds(credentials.NewTLS(tlsConfig)))
+
+	ctl := func(args ...string) (int, string) {
+		var stdout, stderr bytes.Buffer
+		args = append([]string{
+			"-config", filepath.Join(dir, "config.json"),
+			"-server", grpcAddress,
+			"-transport", transportGRPC,
+			"-timeout", "5s",
+		}, args...)
+		code := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr)
+
+		return code, stderr.String()
+	}
+
+	t.Run("shortens URL over mutual TLS", func(t *testing.T) {
+		code, stderr := ctl(
+			"-ca", filepath.Join(dir, "ca.pem"),
+			"-cert", filepath.Join(dir, "client.pem"),
+			"-key", filepath.Join(dir, "client-key.pem"),
+			"shorten", "http://tls.example.com",
+		)
+		assert.Equal(t, 0, code, stderr)
+	})
+
+	t.Run("fails without client certificate", func(t *testing.T) {
+		code, _ := ctl("-ca", filepath.Join(dir, "ca.pem"), "shorten", "http://tls.example.com/a")
+		assert.Equal(t, 1, code)
+	})
+
+	t.Run("fails without TLS", func(t *testing.T) {
+		code, _ := ctl("shorten", "http://tls.example.com/b")
+		assert.Equal(t, 1, code)
+	})
+
+	t.Run("reports client certificate without key", func(t *testing.T) {
+		code, stderr := ctl("-cert", filepath.Join(dir, "client.pem"), "shorten", "http://tls.example.com/c")
+		assert.Equal(t, 1, code)
+		assert.Equal(t, "urlshortctl: client certificate and key must be given together\n", stderr)
+	})
+}
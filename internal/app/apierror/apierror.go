@@ -0,0 +1,83 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
+)
+
+// Error codes of versioned API, each one is sent with its own HTTP status
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeUnauthenticated  = "unauthenticated"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeAlreadyExists    = "already_exists"
+	CodeGone             = "gone"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeValidationFailed = "validation_failed"
+	CodeInternal         = "internal"
+)
+
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeInvalidRequest,
+	http.StatusUnauthorized:          CodeUnauthenticated,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusConflict:              CodeAlreadyExists,
+	http.StatusGone:                  CodeGone,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnprocessableEntity:   CodeValidationFailed,
+	http.StatusInternalServerError:   CodeInternal,
+}
+
+// Error response of versioned API
+type Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Messages by request field, or other machine readable values by name
+	Details map[string]string `json:"details,omitempty"`
+	// Request ID, the same one is logged
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// New error with code of HTTP status
+func New(status int, message string) *Error {
+	code, ok := statusCodes[status]
+	if !ok {
+		status, code = http.StatusInternalServerError, CodeInternal
+	}
+
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Add detail
+func (e *Error) WithDetail(name, value string) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]string)
+	}
+	e.Details[name] = value
+
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Write error response with request ID
+func Write(w http.ResponseWriter, r *http.Request, e *Error) {
+	e.RequestID = middleware.GetReqID(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		logger.Log.Info("failed to encode response", zap.Error(err))
+	}
+}
@@ -2,27 +2,65 @@ package configs
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+)
+
+// Invalid configs errors
+var (
+	ErrInvalidRedirectCode = errors.New("redirect code must be 301, 302, 307 or 308")
+	ErrInvalidNotFoundCode = errors.New("not found code must be 4xx")
 )
 
 // Application configs
 type Config struct {
-	ServerAddress     string `json:"server_address,omitempty"`
-	GRPCServerAddress string `json:"grpc_server_address,omitempty"`
-	BaseURL           string `json:"base_url,omitempty"`
-	FileStoragePath   string `json:"file_storage_path,omitempty"`
-	DatabaseDSN       string `json:"database_dsn,omitempty"`
-	TrustedSubnet     string `json:"trusted_subnet"`
-	EnableHTTPS       bool   `json:"enable_https"`
+	ServerAddress     string   `json:"server_address,omitempty"`
+	GRPCServerAddress string   `json:"grpc_server_address,omitempty"`
+	BaseURL           string   `json:"base_url,omitempty"`
+	FileStoragePath   string   `json:"file_storage_path,omitempty"`
+	DatabaseDSN       string   `json:"database_dsn,omitempty"`
+	TrustedSubnet     string   `json:"trusted_subnet"`
+	EnableHTTPS       bool     `json:"enable_https"`
+	NotActiveMessage  string   `json:"not_active_message,omitempty"`
+	EndedMessage      string   `json:"ended_message,omitempty"`
+	CountryHeader     string   `json:"country_header,omitempty"`
+	QueryMode         string   `json:"query_mode,omitempty"`
+	RedirectCode      int      `json:"redirect_code,omitempty"`
+	NotFoundCode      int      `json:"not_found_code,omitempty"`
+	Domains           []string `json:"domains,omitempty"`
+	// Register gRPC server reflection service
+	EnableGRPCReflection bool `json:"enable_grpc_reflection"`
+	// gRPC server TLS certificate and key, gRPC listens in plaintext without them
+	GRPCCertFile string `json:"grpc_cert_file,omitempty"`
+	GRPCKeyFile  string `json:"grpc_key_file,omitempty"`
+	// CA verifying gRPC client certificates
+	GRPCClientCAFile string `json:"grpc_client_ca_file,omitempty"`
+	// Client certificate names passing trusted subnet check. Any verified
+	// client certificate passes if empty
+	GRPCTrustedClients []string `json:"grpc_trusted_clients,omitempty"`
+	// Bearer token of admin requests to metrics endpoint. Clients in trusted
+	// subnet do not need it
+	MetricsToken string `json:"metrics_token,omitempty"`
+	// Trace exporter: otlp, stdout or none. Spans are not exported if empty
+	TraceExporter string `json:"trace_exporter,omitempty"`
+	// OTLP collector gRPC endpoint, host:port or URL. Exporter default
+	// is used if empty
+	OTLPEndpoint string `json:"otlp_endpoint,omitempty"`
 }
 
 // Parse configs
 func Parse() Config {
 	flagConfigs := Config{}
 	var configFilePath string
+	var domains string
+	var trustedClients string
 	flag.StringVar(&flagConfigs.ServerAddress, "a", "", "server's address")
 	flag.StringVar(&flagConfigs.GRPCServerAddress, "ga", "", "grpc server's address")
 	flag.StringVar(&flagConfigs.BaseURL, "b", "", "base address of the resulting shortened URL")
@@ -30,8 +68,30 @@ func Parse() Config {
 	flag.StringVar(&flagConfigs.DatabaseDSN, "d", "", "database URL")
 	flag.BoolVar(&flagConfigs.EnableHTTPS, "s", false, "enable HTTPS")
 	flag.StringVar(&flagConfigs.TrustedSubnet, "t", "", "trusted subnet")
+	flag.StringVar(&flagConfigs.NotActiveMessage, "not-active-message", "", "response for links which are not active yet")
+	flag.StringVar(&flagConfigs.EndedMessage, "ended-message", "", "response for links which activity has ended")
+	flag.StringVar(&flagConfigs.CountryHeader, "country-header", "", "request header with visitor's country code")
+	flag.StringVar(&flagConfigs.QueryMode, "query-mode", "", "default query string passthrough mode: drop, merge or override")
+	flag.IntVar(&flagConfigs.RedirectCode, "redirect-code", 0, "default redirect status code: 301, 302, 307 or 308")
+	flag.IntVar(&flagConfigs.NotFoundCode, "not-found-code", 0, "4xx status code for unknown shortened URLs")
+	flag.StringVar(&domains, "domains", "", "comma separated base URLs of additional short domains")
+	flag.BoolVar(&flagConfigs.EnableGRPCReflection, "grpc-reflection", false, "enable gRPC server reflection")
+	flag.StringVar(&flagConfigs.GRPCCertFile, "grpc-cert", "", "gRPC server TLS certificate file")
+	flag.StringVar(&flagConfigs.GRPCKeyFile, "grpc-key", "", "gRPC server TLS key file")
+	flag.StringVar(&flagConfigs.GRPCClientCAFile, "grpc-client-ca", "", "CA file verifying gRPC client certificates")
+	flag.StringVar(
+		&trustedClients,
+		"grpc-trusted-clients",
+		"",
+		"comma separated gRPC client certificate names passing trusted subnet check",
+	)
+	flag.StringVar(&flagConfigs.MetricsToken, "metrics-token", "", "bearer token of admin requests to metrics endpoint")
+	flag.StringVar(&flagConfigs.TraceExporter, "trace-exporter", "", "trace exporter: otlp, stdout or none")
+	flag.StringVar(&flagConfigs.OTLPEndpoint, "otlp-endpoint", "", "OTLP collector gRPC endpoint")
 	flag.StringVar(&configFilePath, "c", "", "file path with json application configs")
 	flag.Parse()
+	flagConfigs.Domains = splitList(domains)
+	flagConfigs.GRPCTrustedClients = splitList(trustedClients)
 
 	if envConfigFilePath := os.Getenv("CONFIG"); envConfigFilePath != "" {
 		configFilePath = envConfigFilePath
@@ -41,16 +101,37 @@ func Parse() Config {
 		ServerAddress:     "localhost:8080",
 		GRPCServerAddress: ":3200",
 		BaseURL:           "http://localhost:8080",
+		NotActiveMessage:  "Link is not active yet",
+		EndedMessage:      "Link has expired",
+		CountryHeader:     "CF-IPCountry",
+		QueryMode:         "drop",
+		RedirectCode:      http.StatusTemporaryRedirect,
+		NotFoundCode:      http.StatusNotFound,
 	}
 	configs := Config{}
 	applyConfigs(&configs, defaultConfigs)
 	applyConfigs(&configs, jsonConfigs(configFilePath))
 	applyConfigs(&configs, flagConfigs)
 	applyConfigs(&configs, envConfigs())
+	if err := configs.Validate(); err != nil {
+		log.Fatalf("invalid configs: %s\n", err.Error())
+	}
 
 	return configs
 }
 
+// Validate configs
+func (c Config) Validate() error {
+	if !models.IsRedirectCode(c.RedirectCode) {
+		return ErrInvalidRedirectCode
+	}
+	if c.NotFoundCode < 400 || c.NotFoundCode > 499 {
+		return ErrInvalidNotFoundCode
+	}
+
+	return nil
+}
+
 func applyConfigs(dst *Config, src Config) {
 	if src.ServerAddress != "" {
 		dst.ServerAddress = src.ServerAddress
@@ -70,6 +151,51 @@ func applyConfigs(dst *Config, src Config) {
 	if src.TrustedSubnet != "" {
 		dst.TrustedSubnet = src.TrustedSubnet
 	}
+	if src.NotActiveMessage != "" {
+		dst.NotActiveMessage = src.NotActiveMessage
+	}
+	if src.EndedMessage != "" {
+		dst.EndedMessage = src.EndedMessage
+	}
+	if src.CountryHeader != "" {
+		dst.CountryHeader = src.CountryHeader
+	}
+	if src.QueryMode != "" {
+		dst.QueryMode = src.QueryMode
+	}
+	if src.RedirectCode != 0 {
+		dst.RedirectCode = src.RedirectCode
+	}
+	if src.NotFoundCode != 0 {
+		dst.NotFoundCode = src.NotFoundCode
+	}
+	if len(src.Domains) > 0 {
+		dst.Domains = src.Domains
+	}
+	if src.GRPCCertFile != "" {
+		dst.GRPCCertFile = src.GRPCCertFile
+	}
+	if src.GRPCKeyFile != "" {
+		dst.GRPCKeyFile = src.GRPCKeyFile
+	}
+	if src.GRPCClientCAFile != "" {
+		dst.GRPCClientCAFile = src.GRPCClientCAFile
+	}
+	if len(src.GRPCTrustedClients) > 0 {
+		dst.GRPCTrustedClients = src.GRPCTrustedClients
+	}
+	if src.MetricsToken != "" {
+		dst.MetricsToken = src.MetricsToken
+	}
+	if src.TraceExporter != "" {
+		dst.TraceExporter = src.TraceExporter
+	}
+	if src.OTLPEndpoint != "" {
+		dst.OTLPEndpoint = src.OTLPEndpoint
+	}
+	if src.EnableGRPCReflection {
+		dst.EnableGRPCReflection = true
+	}
 	dst.EnableHTTPS = src.EnableHTTPS
 }
 
@@ -95,8 +221,26 @@ func envConfigs() Config {
 		FileStoragePath:   os.Getenv("FILE_STORAGE_PATH"),
 		DatabaseDSN:       os.Getenv("DATABASE_DSN"),
 		TrustedSubnet:     os.Getenv("TRUSTED_SUBNET"),
+		NotActiveMessage:  os.Getenv("NOT_ACTIVE_MESSAGE"),
+		EndedMessage:      os.Getenv("ENDED_MESSAGE"),
+		CountryHeader:     os.Getenv("COUNTRY_HEADER"),
+		QueryMode:         os.Getenv("QUERY_MODE"),
+		Domains:           splitList(os.Getenv("DOMAINS")),
 	}
+	configs.GRPCCertFile = os.Getenv("GRPC_CERT_FILE")
+	configs.GRPCKeyFile = os.Getenv("GRPC_KEY_FILE")
+	configs.GRPCClientCAFile = os.Getenv("GRPC_CLIENT_CA_FILE")
+	configs.GRPCTrustedClients = splitList(os.Getenv("GRPC_TRUSTED_CLIENTS"))
+	configs.MetricsToken = os.Getenv("METRICS_TOKEN")
+	configs.TraceExporter = os.Getenv("TRACE_EXPORTER")
+	configs.OTLPEndpoint = os.Getenv("OTLP_ENDPOINT")
+
+	configs.RedirectCode = envStatusCode("REDIRECT_CODE")
+	configs.NotFoundCode = envStatusCode("NOT_FOUND_CODE")
 
+	if reflection, err := strconv.ParseBool(os.Getenv("GRPC_REFLECTION")); err == nil {
+		configs.EnableGRPCReflection = reflection
+	}
 	enableHTTPS, err := strconv.ParseBool(os.Getenv("ENABLE_HTTPS"))
 	if err != nil {
 		configs.EnableHTTPS = enableHTTPS
@@ -105,6 +249,31 @@ func envConfigs() Config {
 	return configs
 }
 
+// Status code of environment variable, zero if unset
+func envStatusCode(name string) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %s\n", name, err.Error())
+	}
+
+	return code
+}
+
+func splitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
 // Use database storage
 func (c Config) UseDBStorage() bool {
 	return c.DatabaseDSN != ""
@@ -119,3 +288,8 @@ func (c Config) UseFileStorage() bool {
 func (c Config) UseHTTPS() bool {
 	return c.EnableHTTPS
 }
+
+// Use TLS for gRPC server
+func (c Config) UseGRPCTLS() bool {
+	return c.GRPCCertFile != "" && c.GRPCKeyFile != ""
+}
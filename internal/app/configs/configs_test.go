@@ -1 +1,89 @@
 package configs_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/configs"
+)
+
+func TestConfigDomains(t *testing.T) {
+	config := configs.Config{
+		BaseURL: "http://localhost:8080",
+		Domains: []string{"https://sho.rt", "https://go.example.com/"},
+	}
+
+	t.Run("builds short URL on domain", func(t *testing.T) {
+		assert.Equal(t, "http://localhost:8080/123", config.ShortURL("", "123"))
+		assert.Equal(t, "https://sho.rt/123", config.ShortURL("sho.rt", "123"))
+		assert.Equal(t, "https://go.example.com/123", config.ShortURL("go.example.com", "123"))
+	})
+
+	t.Run("resolves domain by host", func(t *testing.T) {
+		assert.Equal(t, "", config.DomainByHost("localhost:8080"))
+		assert.Equal(t, "sho.rt", config.DomainByHost("SHO.RT"))
+		assert.Equal(t, "go.example.com", config.DomainByHost("go.example.com:443"))
+		assert.Equal(t, "", config.DomainByHost("unknown.com"))
+	})
+
+	t.Run("normalizes domain", func(t *testing.T) {
+		domain, ok := config.NormalizeDomain("localhost")
+		assert.True(t, ok)
+		assert.Equal(t, "", domain)
+		domain, ok = config.NormalizeDomain("sho.rt")
+		assert.True(t, ok)
+		assert.Equal(t, "sho.rt", domain)
+		_, ok = config.NormalizeDomain("unknown.com")
+		assert.False(t, ok)
+	})
+
+	assert.Equal(t, []string{"localhost", "sho.rt", "go.example.com"}, config.Hosts())
+}
+
+func TestConfigValidate(t *testing.T) {
+	testCases := []struct {
+		name         string
+		redirectCode int
+		notFoundCode int
+		want         error
+	}{
+		{
+			name:         "accepts redirect and 4xx codes",
+			redirectCode: http.StatusPermanentRedirect,
+			notFoundCode: http.StatusGone,
+		},
+		{
+			name:         "rejects success redirect code",
+			redirectCode: http.StatusOK,
+			notFoundCode: http.StatusNotFound,
+			want:         configs.ErrInvalidRedirectCode,
+		},
+		{
+			name:         "rejects unsupported redirect code",
+			redirectCode: http.StatusSeeOther,
+			notFoundCode: http.StatusNotFound,
+			want:         configs.ErrInvalidRedirectCode,
+		},
+		{
+			name:         "rejects server error not found code",
+			redirectCode: http.StatusFound,
+			notFoundCode: http.StatusInternalServerError,
+			want:         configs.ErrInvalidNotFoundCode,
+		},
+		{
+			name:         "rejects redirect not found code",
+			redirectCode: http.StatusFound,
+			notFoundCode: http.StatusMovedPermanently,
+			want:         configs.ErrInvalidNotFoundCode,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := configs.Config{RedirectCode: tc.redirectCode, NotFoundCode: tc.notFoundCode}
+			assert.Equal(t, tc.want, config.Validate())
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package configs
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Short URL of path on domain. Empty domain is the default one served on BaseURL
+func (c Config) ShortURL(domain, shortenedPath string) string {
+	if domain != "" {
+		for _, baseURL := range c.Domains {
+			if hostOf(baseURL) == domain {
+				return strings.TrimSuffix(baseURL, "/") + "/" + shortenedPath
+			}
+		}
+	}
+
+	return c.BaseURL + "/" + shortenedPath
+}
+
+// Domain served on request host. Unknown hosts are served as the default domain
+func (c Config) DomainByHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	domain, _ := c.NormalizeDomain(strings.ToLower(host))
+
+	return domain
+}
+
+// Normalize domain chosen by user. Returns false if domain is not configured
+func (c Config) NormalizeDomain(domain string) (string, bool) {
+	if domain == "" || domain == hostOf(c.BaseURL) {
+		return "", true
+	}
+	for _, baseURL := range c.Domains {
+		if hostOf(baseURL) == domain {
+			return domain, true
+		}
+	}
+
+	return "", false
+}
+
+// Hosts of all short domains
+func (c Config) Hosts() []string {
+	hosts := []string{hostOf(c.BaseURL)}
+	for _, baseURL := range c.Domains {
+		hosts = append(hosts, hostOf(baseURL))
+	}
+
+	return hosts
+}
+
+func hostOf(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/ilya-burinskiy/urlshort/internal/app/handlers"
 	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
 	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
 	"github.com/ilya-burinskiy/urlshort/internal/app/storage/mocks"
 )
 
@@ -107,6 +108,26 @@ func TestBatchCreateURLTest(t *testing.T) {
 				response:    toJSON(t, "error") + "\n",
 			},
 		},
+		{
+			name: "responses with conflict status if original URL is already shortened",
+			batchCreateResult: urlCreaterBatchCreateResult{
+				err: storage.NewErrNotUnique(models.Record{OriginalURL: "http://example0.com", UserID: 2}),
+			},
+			authOrRegisterRes: authOrRegisterResult{
+				user:   models.User{ID: 1},
+				jwtStr: "123",
+				err:    nil,
+			},
+			reqBody: toJSON(
+				t,
+				[]models.Record{{OriginalURL: "http://example0.com", CorrelationID: "1"}},
+			),
+			want: want{
+				code:        http.StatusConflict,
+				contentType: "application/json; charset=utf-8",
+				response:    toJSON(t, "original URL is already shortened") + "\n",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	swaggerFiles "github.com/swaggo/files/v2"
+	"go.uber.org/zap"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
+)
+
+// OpenAPI document of HTTP routes. Gateway routes under /api/v2 are described
+// by grpc/urls.swagger.json generated from urls.proto
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// Swagger UI page rendering OpenAPI document
+//
+//go:embed docs.html
+var docsPage []byte
+
+// Swagger UI files of docs page, embedded by swaggo/files module, so the page
+// loads no third-party resources
+var docsAssets = http.StripPrefix("/api/docs/", http.FileServer(http.FS(swaggerFiles.FS)))
+
+// Get OpenAPI document
+func (h Handlers) GetOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(openAPISpec); err != nil {
+		logger.Log.Info("failed to write response", zap.Error(err))
+	}
+}
+
+// Get interactive API documentation
+func (h Handlers) GetDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(docsPage); err != nil {
+		logger.Log.Info("failed to write response", zap.Error(err))
+	}
+}
+
+// Get Swagger UI asset of docs page
+func (h Handlers) GetDocsAsset(w http.ResponseWriter, r *http.Request) {
+	switch chi.URLParam(r, "file") {
+	case "swagger-ui.css", "swagger-ui-bundle.js":
+		docsAssets.ServeHTTP(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
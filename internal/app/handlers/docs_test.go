@@ -0,0 +1,80 @@
+package handlers_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/handlers"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage/mocks"
+)
+
+func TestGetDocsHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	handler := handlers.NewHandlers(defaultConfig, mocks.NewMockStorage(ctrl))
+	router := chi.NewRouter()
+	router.Get("/api/docs", handler.GetDocs)
+	router.Get("/api/docs/{file}", handler.GetDocsAsset)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name        string
+		path        string
+		code        int
+		contentType string
+	}{
+		{
+			name:        "responses with docs page",
+			path:        "/api/docs",
+			code:        http.StatusOK,
+			contentType: "text/html; charset=utf-8",
+		},
+		{
+			name:        "responses with Swagger UI styles",
+			path:        "/api/docs/swagger-ui.css",
+			code:        http.StatusOK,
+			contentType: "text/css; charset=utf-8",
+		},
+		{
+			name:        "responses with Swagger UI script",
+			path:        "/api/docs/swagger-ui-bundle.js",
+			code:        http.StatusOK,
+			contentType: "text/javascript; charset=utf-8",
+		},
+		{
+			name: "responses with not found status on other files",
+			path: "/api/docs/index.html",
+			code: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			response, err := testServer.Client().Get(testServer.URL + tc.path)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			body, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.code, response.StatusCode)
+			if tc.code == http.StatusOK {
+				assert.Equal(t, tc.contentType, response.Header.Get("Content-Type"))
+				assert.NotEmpty(t, body)
+			}
+			if tc.path == "/api/docs" {
+				// Page must load assets from the server itself
+				assert.NotContains(t, string(body), "https://")
+			}
+		})
+	}
+}
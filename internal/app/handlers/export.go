@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
+	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+)
+
+var exportContentTypes = map[string]string{
+	services.ExportFormatCSV:   "text/csv; charset=utf-8",
+	services.ExportFormatJSONL: "application/x-ndjson",
+}
+
+// Export user shortened URLs with click counts as CSV (default) or JSON lines.
+// Links are streamed from storage, deleted links are included if include_deleted=true
+func (h Handlers) ExportUserURLs(exporter services.URLExporter) func(http.ResponseWriter, *http.Request) {
+	return h.exportUserURLs(exporter, writeJSONError)
+}
+
+func (h Handlers) exportUserURLs(
+	exporter services.URLExporter,
+	writeError errorWriter) func(http.ResponseWriter, *http.Request) {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		format := query.Get("format")
+		if format == "" {
+			format = services.ExportFormatCSV
+		}
+		includeDeleted := false
+		encoder, err := services.NewExportEncoder(format, w)
+		if err == nil && query.Has("include_deleted") {
+			if includeDeleted, err = strconv.ParseBool(query.Get("include_deleted")); err != nil {
+				err = &fieldError{field: "include_deleted", err: err}
+			}
+		}
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", exportContentTypes[format])
+		w.Header().Set("Content-Disposition", `attachment; filename="urls.`+format+`"`)
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		exported := 0
+		err = exporter.Export(r.Context(), models.User{ID: userID}, includeDeleted, func(u services.ExportedURL) error {
+			exported++
+			return encoder.Encode(u)
+		})
+		if err != nil && exported == 0 {
+			w.Header().Del("Content-Disposition")
+			writeError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if err == nil {
+			err = encoder.Flush()
+		}
+		// Some links are already sent, so response is left truncated
+		if err != nil {
+			logger.Log.Info("failed to export urls", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package handlers_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/handlers"
+	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+func TestExportUserURLsHandler(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMapStorage(nil)
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	activeUntil := createdAt.Add(24 * time.Hour)
+	require.NoError(t, store.BatchSave(ctx, []models.Record{
+		{
+			OriginalURL:   "http://example.com",
+			ShortenedPath: "1",
+			UserID:        1,
+			Title:         "Example, Inc.",
+			Tags:          []string{"work", "docs"},
+			CreatedAt:     createdAt,
+			ActiveUntil:   &activeUntil,
+		},
+		{OriginalURL: "http://example.org", ShortenedPath: "2", UserID: 1, CreatedAt: createdAt.Add(time.Hour)},
+		{OriginalURL: "http://example.net", ShortenedPath: "3", UserID: 2, CreatedAt: createdAt},
+	}))
+	require.NoError(t, store.SaveClick(ctx, models.Click{ShortenedPath: "1"}))
+	require.NoError(t, store.SaveClick(ctx, models.Click{ShortenedPath: "1"}))
+	require.NoError(t, store.BatchDelete(ctx, []models.Record{{ShortenedPath: "2", UserID: 1}}))
+
+	user := models.User{ID: 1}
+	userAuthenticator := new(userAuthenticatorMock)
+	userAuthenticator.On("Auth", mock.Anything).Return(user, nil)
+	router := chi.NewRouter()
+	handler := handlers.NewHandlers(defaultConfig, store)
+	router.Use(middlewares.Authenticate(userAuthenticator))
+	router.Get("/api/user/urls/export", handler.ExportUserURLs(services.NewURLExporter(store, defaultConfig.ShortURL)))
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	csvHeader := "short_url,original_url,alias,domain,title,description,tags,folder,clicks,is_deleted," +
+		"created_at,updated_at,expires_at\n"
+	testCases := []struct {
+		name  string
+		query string
+		want  want
+	}{
+		{
+			name:  "responds with CSV export",
+			query: "",
+			want: want{
+				code:        http.StatusOK,
+				contentType: "text/csv; charset=utf-8",
+				response: csvHeader +
+					`http://localhost:8080/1,http://example.com,1,,"Example, Inc.",,"work,docs",,2,false,` +
+					"2024-03-01T12:00:00Z,2024-03-01T12:00:00Z,2024-03-02T12:00:00Z\n",
+			},
+		},
+		{
+			name:  "responds with JSON lines export including deleted URLs",
+			query: "?format=jsonl&include_deleted=true",
+			want: want{
+				code:        http.StatusOK,
+				contentType: "application/x-ndjson",
+				response: `{"short_url":"http://localhost:8080/1","original_url":"http://example.com","alias":"1",` +
+					`"title":"Example, Inc.","tags":["work","docs"],"clicks":2,"is_deleted":false,` +
+					`"created_at":"2024-03-01T12:00:00Z","updated_at":"2024-03-01T12:00:00Z",` +
+					`"expires_at":"2024-03-02T12:00:00Z"}` + "\n" +
+					`{"short_url":"http://localhost:8080/2","original_url":"http://example.org","alias":"2",` +
+					`"clicks":0,"is_deleted":true,` +
+					`"created_at":"2024-03-01T13:00:00Z","updated_at":"2024-03-01T13:00:00Z"}` + "\n",
+			},
+		},
+		{
+			name:  "responds with bad request status if format is unknown",
+			query: "?format=xml",
+			want: want{
+				code:        http.StatusBadRequest,
+				contentType: "application/json",
+				response:    toJSON(t, `invalid export format "xml", must be csv or jsonl`) + "\n",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, testServer.URL+"/api/user/urls/export"+tc.query, nil)
+			require.NoError(t, err)
+			request.AddCookie(generateAuthCookie(t, user))
+
+			response, err := testServer.Client().Do(request)
+			require.NoError(t, err)
+			resBody, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			assert.Equal(t, tc.want.code, response.StatusCode)
+			assert.Equal(t, tc.want.contentType, response.Header.Get("Content-Type"))
+			assert.Equal(t, tc.want.response, string(resBody))
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package handlers_test
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 
@@ -16,6 +17,7 @@ import (
 
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -24,16 +26,20 @@ import (
 func TestGetShortenedURLHandler(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	storageMock := mocks.NewMockStorage(ctrl)
+	storageMock.EXPECT().
+		SaveClick(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(nil)
 	storageMock.EXPECT().
 		CreateUser(gomock.Any()).
 		AnyTimes().
 		Return(models.User{ID: 1}, nil)
 	gomock.InOrder(
 		storageMock.EXPECT().
-			FindByShortenedPath(gomock.Any(), gomock.Any()).
+			FindByShortenedPath(gomock.Any(), "", gomock.Any()).
 			Return(models.Record{OriginalURL: "http://example.com"}, nil),
 		storageMock.EXPECT().
-			FindByShortenedPath(gomock.Any(), gomock.Any()).
+			FindByShortenedPath(gomock.Any(), "", gomock.Any()).
 			Return(models.Record{}, storage.ErrNotFound),
 	)
 
@@ -80,12 +86,12 @@ func TestGetShortenedURLHandler(t *testing.T) {
 			},
 		},
 		{
-			name:        "responses with bad request if original URL could not be found",
+			name:        "responses with not found if original URL could not be found",
 			httpMethod:  http.MethodGet,
 			path:        "/321",
 			contentType: "text/plain",
 			want: want{
-				code:        http.StatusBadRequest,
+				code:        http.StatusNotFound,
 				response:    "Original URL for \"321\" not found\n",
 				contentType: "text/plain; charset=utf-8",
 			},
@@ -120,3 +126,500 @@ func TestGetShortenedURLHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestGetShortenedURLHandlerWithMaxClicks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storageMock := mocks.NewMockStorage(ctrl)
+	storageMock.EXPECT().
+		SaveClick(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "123").
+		AnyTimes().
+		Return(models.Record{OriginalURL: "http://example.com", ShortenedPath: "123", MaxClicks: 1, RemainingClicks: 1}, nil)
+	gomock.InOrder(
+		storageMock.EXPECT().ConsumeClick(gomock.Any(), "", "123").Return(0, nil),
+		storageMock.EXPECT().ConsumeClick(gomock.Any(), "", "123").Return(0, storage.ErrClicksExhausted),
+	)
+
+	handler := handlers.NewHandlers(defaultConfig, storageMock)
+	router := chi.NewRouter()
+	router.Get("/{id}", handler.GetOriginalURL)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name string
+		code int
+	}{
+		{name: "responses with temporary redirect status while clicks left", code: http.StatusTemporaryRedirect},
+		{name: "responses with gone status when clicks exhausted", code: http.StatusGone},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, testServer.URL+"/123", nil)
+			require.NoError(t, err)
+
+			transport := http.Transport{}
+			response, err := transport.RoundTrip(request)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			assert.Equal(t, tc.code, response.StatusCode)
+		})
+	}
+}
+
+func TestGetShortenedURLHandlerWithActivityWindow(t *testing.T) {
+	now := time.Now()
+	future := now.Add(time.Hour)
+	past := now.Add(-time.Hour)
+	ctrl := gomock.NewController(t)
+	storageMock := mocks.NewMockStorage(ctrl)
+	storageMock.EXPECT().
+		SaveClick(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "1").
+		AnyTimes().
+		Return(models.Record{OriginalURL: "http://example.com", ActiveFrom: &future}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "2").
+		AnyTimes().
+		Return(models.Record{OriginalURL: "http://example.com", ActiveUntil: &past}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "3").
+		AnyTimes().
+		Return(models.Record{OriginalURL: "http://example.com", ActiveUntil: &past, FallbackURL: "http://fallback.com"}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "4").
+		AnyTimes().
+		Return(models.Record{OriginalURL: "http://example.com", ActiveFrom: &past, ActiveUntil: &future}, nil)
+
+	config := defaultConfig
+	config.NotActiveMessage = "not active"
+	config.EndedMessage = "ended"
+	handler := handlers.NewHandlers(config, storageMock)
+	router := chi.NewRouter()
+	router.Get("/{id}", handler.GetOriginalURL)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name     string
+		path     string
+		code     int
+		response string
+		location string
+	}{
+		{name: "responses with not found status before window", path: "/1", code: http.StatusNotFound, response: "not active\n"},
+		{name: "responses with gone status after window", path: "/2", code: http.StatusGone, response: "ended\n"},
+		{name: "redirects to fallback after window", path: "/3", code: http.StatusTemporaryRedirect, location: "http://fallback.com"},
+		{name: "redirects inside window", path: "/4", code: http.StatusTemporaryRedirect, location: "http://example.com"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, testServer.URL+tc.path, nil)
+			require.NoError(t, err)
+
+			transport := http.Transport{}
+			response, err := transport.RoundTrip(request)
+			require.NoError(t, err)
+			resBody, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			assert.Equal(t, tc.code, response.StatusCode)
+			assert.Equal(t, tc.location, response.Header.Get("Location"))
+			if tc.response != "" {
+				assert.Equal(t, tc.response, string(resBody))
+			}
+		})
+	}
+}
+
+func TestGetShortenedURLHandlerWithRoutingRules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storageMock := mocks.NewMockStorage(ctrl)
+	storageMock.EXPECT().
+		SaveClick(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "123").
+		AnyTimes().
+		Return(models.Record{
+			OriginalURL: "http://example.com",
+			Rules: []models.RoutingRule{
+				{Field: models.RuleFieldOS, Pattern: "ios", Destination: "http://apps.apple.com"},
+				{Field: models.RuleFieldCountry, Pattern: "DE", Destination: "http://example.de"},
+			},
+		}, nil)
+
+	config := defaultConfig
+	config.CountryHeader = "X-Country"
+	handler := handlers.NewHandlers(config, storageMock)
+	router := chi.NewRouter()
+	router.Get("/{id}", handler.GetOriginalURL)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name     string
+		headers  map[string]string
+		location string
+	}{
+		{
+			name:     "redirects iOS users by OS rule",
+			headers:  map[string]string{"User-Agent": "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)"},
+			location: "http://apps.apple.com",
+		},
+		{
+			name:     "redirects by country rule",
+			headers:  map[string]string{"X-Country": "DE"},
+			location: "http://example.de",
+		},
+		{
+			name:     "redirects to default destination",
+			headers:  map[string]string{"User-Agent": "Mozilla/5.0 (X11; Linux x86_64)"},
+			location: "http://example.com",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, testServer.URL+"/123", nil)
+			require.NoError(t, err)
+			for k, v := range tc.headers {
+				request.Header.Set(k, v)
+			}
+
+			transport := http.Transport{}
+			response, err := transport.RoundTrip(request)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			assert.Equal(t, http.StatusTemporaryRedirect, response.StatusCode)
+			assert.Equal(t, tc.location, response.Header.Get("Location"))
+		})
+	}
+}
+
+func TestGetShortenedURLHandlerWithVariants(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storageMock := mocks.NewMockStorage(ctrl)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "123").
+		AnyTimes().
+		Return(models.Record{
+			OriginalURL: "http://example.com",
+			Variants: []models.Variant{
+				{Name: "a", URL: "http://a.example.com", Weight: 0},
+				{Name: "b", URL: "http://b.example.com", Weight: 1},
+			},
+		}, nil)
+	storageMock.EXPECT().
+		SaveClick(gomock.Any(), clickMatcher{shortenedPath: "123", variant: "b"}).
+		Times(2).
+		Return(nil)
+
+	handler := handlers.NewHandlers(defaultConfig, storageMock)
+	router := chi.NewRouter()
+	router.Get("/{id}", handler.GetOriginalURL)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name   string
+		cookie *http.Cookie
+	}{
+		{name: "picks variant by weight"},
+		{name: "ignores sticky variant without weight", cookie: &http.Cookie{Name: "variant_123", Value: "a"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, testServer.URL+"/123", nil)
+			require.NoError(t, err)
+			if tc.cookie != nil {
+				request.AddCookie(tc.cookie)
+			}
+
+			transport := http.Transport{}
+			response, err := transport.RoundTrip(request)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			assert.Equal(t, http.StatusTemporaryRedirect, response.StatusCode)
+			assert.Equal(t, "http://b.example.com", response.Header.Get("Location"))
+			cookies := response.Cookies()
+			require.Len(t, cookies, 1)
+			assert.Equal(t, "variant_123", cookies[0].Name)
+			assert.Equal(t, "b", cookies[0].Value)
+			assert.Equal(t, "/123", cookies[0].Path)
+		})
+	}
+}
+
+func TestGetShortenedURLHandlerWithQueryPassthrough(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storageMock := mocks.NewMockStorage(ctrl)
+	storageMock.EXPECT().SaveClick(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "merge").
+		AnyTimes().
+		Return(models.Record{
+			OriginalURL:   "http://example.com/page?lang=en#intro",
+			ShortenedPath: "merge",
+			QueryMode:     models.QueryModeMerge,
+			UTMParams:     map[string]string{"utm_source": "short", "utm_campaign": "{code}"},
+		}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "default").
+		AnyTimes().
+		Return(models.Record{OriginalURL: "http://example.com/page?lang=en"}, nil)
+
+	config := defaultConfig
+	config.QueryMode = models.QueryModeOverride
+	handler := handlers.NewHandlers(config, storageMock)
+	router := chi.NewRouter()
+	router.Get("/{id}", handler.GetOriginalURL)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name     string
+		path     string
+		location string
+	}{
+		{
+			name:     "merges query and appends UTM params before fragment",
+			path:     "/merge?lang=de&ref=tw",
+			location: "http://example.com/page?lang=en&ref=tw&utm_campaign=merge&utm_source=short#intro",
+		},
+		{
+			name:     "uses server default query mode",
+			path:     "/default?lang=de",
+			location: "http://example.com/page?lang=de",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, testServer.URL+tc.path, nil)
+			require.NoError(t, err)
+
+			transport := http.Transport{}
+			response, err := transport.RoundTrip(request)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			assert.Equal(t, http.StatusTemporaryRedirect, response.StatusCode)
+			assert.Equal(t, tc.location, response.Header.Get("Location"))
+		})
+	}
+}
+
+func TestGetShortenedURLHandlerWithRedirectCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storageMock := mocks.NewMockStorage(ctrl)
+	activeUntil := time.Now().Add(time.Minute)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "permanent").
+		AnyTimes().
+		Return(models.Record{OriginalURL: "http://example.com", RedirectCode: http.StatusMovedPermanently}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "expiring").
+		AnyTimes().
+		Return(models.Record{
+			OriginalURL:  "http://example.com",
+			RedirectCode: http.StatusPermanentRedirect,
+			ActiveUntil:  &activeUntil,
+		}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "default").
+		AnyTimes().
+		Return(models.Record{OriginalURL: "http://example.com"}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "limited").
+		AnyTimes().
+		Return(models.Record{
+			OriginalURL:     "http://example.com",
+			RedirectCode:    http.StatusMovedPermanently,
+			MaxClicks:       1,
+			RemainingClicks: 1,
+		}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "unknown").
+		AnyTimes().
+		Return(models.Record{}, storage.ErrNotFound)
+	storageMock.EXPECT().
+		SaveClick(gomock.Any(), gomock.Any()).
+		Times(3).
+		Return(nil)
+
+	config := defaultConfig
+	config.RedirectCode = http.StatusFound
+	config.NotFoundCode = http.StatusBadRequest
+	handler := handlers.NewHandlers(config, storageMock)
+	router := chi.NewRouter()
+	router.Get("/{id}", handler.GetOriginalURL)
+	router.Head("/{id}", handler.GetOriginalURL)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		code         int
+		cacheControl string
+	}{
+		{
+			name:         "responses with link redirect code",
+			method:       http.MethodGet,
+			path:         "/permanent",
+			code:         http.StatusMovedPermanently,
+			cacheControl: "public, max-age=300",
+		},
+		{
+			name:         "caches permanent redirect until link expiry",
+			method:       http.MethodGet,
+			path:         "/expiring",
+			code:         http.StatusPermanentRedirect,
+			cacheControl: "public, max-age=59",
+		},
+		{
+			name:         "responses with server default redirect code",
+			method:       http.MethodGet,
+			path:         "/default",
+			code:         http.StatusFound,
+			cacheControl: "private, no-cache",
+		},
+		{
+			name:         "does not cache click limited link",
+			method:       http.MethodHead,
+			path:         "/limited",
+			code:         http.StatusMovedPermanently,
+			cacheControl: "private, no-cache",
+		},
+		{
+			name:   "responses with configured not found code",
+			method: http.MethodGet,
+			path:   "/unknown",
+			code:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(tc.method, testServer.URL+tc.path, nil)
+			require.NoError(t, err)
+
+			transport := http.Transport{}
+			response, err := transport.RoundTrip(request)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			assert.Equal(t, tc.code, response.StatusCode)
+			if tc.cacheControl != "" {
+				assert.Equal(t, tc.cacheControl, response.Header.Get("Cache-Control"))
+				assert.NotEmpty(t, response.Header.Get("Expires"))
+				assert.Equal(t, "http://example.com", response.Header.Get("Location"))
+			}
+		})
+	}
+}
+
+func TestGetShortenedURLHandlerWithDomains(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storageMock := mocks.NewMockStorage(ctrl)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "123").
+		Return(models.Record{OriginalURL: "http://example.com", ShortenedPath: "123"}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "sho.rt", "123").
+		Return(models.Record{OriginalURL: "http://example.org", Domain: "sho.rt", ShortenedPath: "123"}, nil)
+	storageMock.EXPECT().
+		SaveClick(gomock.Any(), gomock.Any()).
+		Times(2).
+		Return(nil)
+
+	config := defaultConfig
+	config.Domains = []string{"https://sho.rt"}
+	handler := handlers.NewHandlers(config, storageMock)
+	router := chi.NewRouter()
+	router.Get("/{id}", handler.GetOriginalURL)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name     string
+		host     string
+		location string
+	}{
+		{
+			name:     "redirects on default domain",
+			host:     "localhost:8080",
+			location: "http://example.com",
+		},
+		{
+			name:     "redirects on additional domain",
+			host:     "sho.rt",
+			location: "http://example.org",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, testServer.URL+"/123", nil)
+			require.NoError(t, err)
+			request.Host = tc.host
+
+			transport := http.Transport{}
+			response, err := transport.RoundTrip(request)
+			require.NoError(t, err)
+			err = response.Body.Close()
+			require.NoError(t, err)
+
+			assert.Equal(t, http.StatusTemporaryRedirect, response.StatusCode)
+			assert.Equal(t, tc.location, response.Header.Get("Location"))
+		})
+	}
+}
+
+type clickMatcher struct {
+	shortenedPath string
+	variant       string
+}
+
+func (m clickMatcher) Matches(x interface{}) bool {
+	click, ok := x.(models.Click)
+	return ok && click.ShortenedPath == m.shortenedPath && click.Variant == m.variant
+}
+
+func (m clickMatcher) String() string {
+	return fmt.Sprintf("click on %s with variant %q", m.shortenedPath, m.variant)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -16,6 +17,7 @@ import (
 	"github.com/ilya-burinskiy/urlshort/internal/app/handlers"
 	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
 	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
 	"github.com/ilya-burinskiy/urlshort/internal/app/storage/mocks"
 )
 
@@ -24,13 +26,21 @@ func TestGetUserURLsHandler(t *testing.T) {
 	storageMock := mocks.NewMockStorage(ctrl)
 	userAuthenticator := new(userAuthenticatorMock)
 	user := models.User{ID: 1}
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
 	storageMock.EXPECT().
-		FindByUser(gomock.Any(), user).
+		FindByUser(gomock.Any(), user, models.RecordFilter{}).
 		AnyTimes().
 		Return(
 			[]models.Record{
-				{OriginalURL: "http://example1.com", ShortenedPath: "1"},
-				{OriginalURL: "http://example2.com", ShortenedPath: "2"},
+				{OriginalURL: "http://example1.com", ShortenedPath: "1", CreatedAt: createdAt, UpdatedAt: createdAt},
+				{
+					OriginalURL:   "http://example2.com",
+					ShortenedPath: "2",
+					Title:         "Example",
+					Description:   "Second example",
+					CreatedAt:     createdAt,
+					UpdatedAt:     createdAt.Add(time.Hour),
+				},
 			},
 			nil,
 		)
@@ -63,13 +73,11 @@ func TestGetUserURLsHandler(t *testing.T) {
 			want: want{
 				code:        http.StatusOK,
 				contentType: "application/json; charset=utf-8",
-				response: toJSON(
-					t,
-					[]map[string]string{
-						{"short_url": defaultConfig.BaseURL + "/1", "original_url": "http://example1.com"},
-						{"short_url": defaultConfig.BaseURL + "/2", "original_url": "http://example2.com"},
-					},
-				) + "\n",
+				response: `[{"original_url":"http://example1.com","short_url":"http://localhost:8080/1",` +
+					`"created_at":"2024-03-01T12:00:00Z","updated_at":"2024-03-01T12:00:00Z"},` +
+					`{"original_url":"http://example2.com","short_url":"http://localhost:8080/2",` +
+					`"title":"Example","description":"Second example",` +
+					`"created_at":"2024-03-01T12:00:00Z","updated_at":"2024-03-01T13:00:00Z"}]` + "\n",
 			},
 		},
 		{
@@ -112,3 +120,162 @@ func TestGetUserURLsHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestGetUserURLsHandlerWithFilter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storageMock := mocks.NewMockStorage(ctrl)
+	user := models.User{ID: 1}
+	createdFrom := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	storageMock.EXPECT().
+		FindByUser(gomock.Any(), user, models.RecordFilter{
+			Tags:        []string{"work", "docs"},
+			Folder:      "projects",
+			Query:       "example",
+			CreatedFrom: &createdFrom,
+		}).
+		Return(
+			[]models.Record{{
+				OriginalURL:   "http://example.com",
+				ShortenedPath: "1",
+				Tags:          []string{"work", "docs"},
+				Folder:        "projects",
+			}},
+			nil,
+		)
+
+	userAuthenticator := new(userAuthenticatorMock)
+	userAuthenticator.On("Auth", mock.Anything).Return(user, nil)
+	router := chi.NewRouter()
+	handler := handlers.NewHandlers(defaultConfig, storageMock)
+	router.Use(middlewares.Authenticate(userAuthenticator))
+	router.Get("/api/user/urls", handler.GetUserURLs)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name  string
+		query string
+		want  want
+	}{
+		{
+			name:  "responses with filtered URLs",
+			query: "?tag=work&tag=docs&folder=projects&q=example&created_from=2024-03-01T00:00:00Z",
+			want: want{
+				code: http.StatusOK,
+				response: `[{"original_url":"http://example.com","short_url":"http://localhost:8080/1",` +
+					`"tags":["work","docs"],"folder":"projects",` +
+					`"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}]` + "\n",
+			},
+		},
+		{
+			name:  "responses with bad request status if created range is invalid",
+			query: "?created_to=yesterday",
+			want: want{
+				code: http.StatusBadRequest,
+				response: toJSON(t, `invalid created_to: parsing time "yesterday" as "2006-01-02T15:04:05Z07:00": `+
+					`cannot parse "yesterday" as "2006"`) + "\n",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, testServer.URL+"/api/user/urls"+tc.query, nil)
+			require.NoError(t, err)
+			request.AddCookie(generateAuthCookie(t, user))
+
+			response, err := testServer.Client().Do(request)
+			require.NoError(t, err)
+			resBody, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			assert.Equal(t, tc.want.code, response.StatusCode)
+			assert.Equal(t, tc.want.response, string(resBody))
+		})
+	}
+}
+
+func TestGetUserURLsHandlerWithPagination(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storageMock := mocks.NewMockStorage(ctrl)
+	user := models.User{ID: 1}
+	next := models.Cursor{CreatedAt: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC), ShortenedPath: "1"}
+	firstPage := models.Page{Limit: 1, SortBy: models.SortByCreatedAt, Desc: true}
+	nextCursor := services.EncodeCursor(firstPage, &next)
+	secondPage := firstPage
+	secondPage.After = &next
+	gomock.InOrder(
+		storageMock.EXPECT().
+			FindByUserPage(gomock.Any(), user, models.RecordFilter{}, firstPage).
+			Return([]models.Record{{OriginalURL: "http://example1.com", ShortenedPath: "1"}}, &next, nil),
+		storageMock.EXPECT().
+			FindByUserPage(gomock.Any(), user, models.RecordFilter{}, secondPage).
+			Return([]models.Record{}, nil, nil),
+	)
+
+	userAuthenticator := new(userAuthenticatorMock)
+	userAuthenticator.On("Auth", mock.Anything).Return(user, nil)
+	router := chi.NewRouter()
+	handler := handlers.NewHandlers(defaultConfig, storageMock)
+	router.Use(middlewares.Authenticate(userAuthenticator))
+	router.Get("/api/user/urls", handler.GetUserURLs)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name  string
+		query string
+		want  want
+	}{
+		{
+			name:  "responses with first page and next cursor",
+			query: "?limit=1&order=desc",
+			want: want{
+				code: http.StatusOK,
+				response: `{"items":[{"original_url":"http://example1.com","short_url":"http://localhost:8080/1",` +
+					`"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}],` +
+					`"next_cursor":"` + nextCursor + `"}` + "\n",
+			},
+		},
+		{
+			name:  "responses with empty last page",
+			query: "?limit=1&order=desc&cursor=" + nextCursor,
+			want: want{
+				code:     http.StatusOK,
+				response: `{"items":[]}` + "\n",
+			},
+		},
+		{
+			name:  "responses with bad request status if cursor is issued for another order",
+			query: "?limit=1&cursor=" + nextCursor,
+			want: want{
+				code:     http.StatusBadRequest,
+				response: toJSON(t, "invalid pagination parameters: cursor was issued for another sort order") + "\n",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, testServer.URL+"/api/user/urls"+tc.query, nil)
+			require.NoError(t, err)
+			request.AddCookie(generateAuthCookie(t, user))
+
+			response, err := testServer.Client().Do(request)
+			require.NoError(t, err)
+			resBody, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			assert.Equal(t, tc.want.code, response.StatusCode)
+			assert.Equal(t, tc.want.response, string(resBody))
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package graphql
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/configs"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+// Max depth of query selections
+const maxQueryDepth = 10
+
+//go:embed schema.graphql
+var schema string
+
+// GraphQL API of user links. Handler must be wrapped with authentication
+// middleware, resolvers act on behalf of user from request context. Errors are
+// sent with code of versioned API in extensions
+func NewHandler(
+	config configs.Config,
+	store storage.Storage,
+	shortener services.URLShortener,
+	urlUpdater services.URLUpdater,
+	urlDeleter services.DeferredDeleter) http.Handler {
+
+	return &relay.Handler{
+		Schema: graphql.MustParseSchema(
+			schema,
+			&resolver{
+				config:     config,
+				store:      store,
+				shortener:  shortener,
+				urlUpdater: urlUpdater,
+				urlDeleter: urlDeleter,
+			},
+			graphql.MaxDepth(maxQueryDepth),
+		),
+	}
+}
@@ -0,0 +1,246 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/auth"
+	"github.com/ilya-burinskiy/urlshort/internal/app/configs"
+	"github.com/ilya-burinskiy/urlshort/internal/app/handlers/graphql"
+	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+var defaultConfig = configs.Config{
+	BaseURL:       "http://localhost:8080",
+	ServerAddress: "http://localhost:8080",
+}
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string            `json:"message"`
+		Extensions map[string]string `json:"extensions"`
+	} `json:"errors"`
+}
+
+func TestHandler(t *testing.T) {
+	store := storage.NewMapStorage(nil)
+	userAuthenticator := services.NewUserAuthenticator(store)
+	router := chi.NewRouter()
+	router.With(middlewares.Authenticate(userAuthenticator)).Post("/api/graphql", graphql.NewHandler(
+		defaultConfig,
+		store,
+		services.NewURLShortener(8, services.RandHexStrGenerator{}, store),
+		services.NewURLUpdater(store),
+		services.NewDeferredDeleter(store),
+	).ServeHTTP)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	user, err := store.CreateUser(context.Background())
+	require.NoError(t, err)
+	otherUser, err := store.CreateUser(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, store.Save(context.Background(), models.Record{
+		OriginalURL:   "http://other.com",
+		ShortenedPath: "other",
+		UserID:        otherUser.ID,
+	}))
+
+	do := func(t *testing.T, user *models.User, query string, variables map[string]interface{}) (int, graphqlResponse) {
+		body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+		require.NoError(t, err)
+		request, err := http.NewRequest(http.MethodPost, testServer.URL+"/api/graphql", strings.NewReader(string(body)))
+		require.NoError(t, err)
+		request.Header.Set("Content-Type", "application/json")
+		if user != nil {
+			jwtStr, err := auth.BuildJWTString(*user)
+			require.NoError(t, err)
+			request.AddCookie(&http.Cookie{Name: "jwt", Value: jwtStr})
+		}
+		response, err := testServer.Client().Do(request)
+		require.NoError(t, err)
+		defer response.Body.Close()
+
+		var result graphqlResponse
+		if response.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+		}
+
+		return response.StatusCode, result
+	}
+
+	createLink := `mutation($input: CreateLinkInput!) { createLink(input: $input) { code shortUrl originalUrl tags } }`
+	code, response := do(t, &user, createLink, map[string]interface{}{
+		"input": map[string]interface{}{"originalUrl": "http://example.com", "tags": []string{"docs"}, "title": "Example"},
+	})
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, response.Errors)
+	var created struct {
+		CreateLink struct {
+			Code     string `json:"code"`
+			ShortURL string `json:"shortUrl"`
+		} `json:"createLink"`
+	}
+	require.NoError(t, json.Unmarshal(response.Data, &created))
+	shortURL := defaultConfig.BaseURL + "/" + created.CreateLink.Code
+	assert.Equal(t, shortURL, created.CreateLink.ShortURL)
+	require.NoError(t, store.SaveClick(context.Background(), models.Click{ShortenedPath: created.CreateLink.Code}))
+	require.NoError(t, store.Save(context.Background(), models.Record{
+		OriginalURL:   "http://deleted.com",
+		ShortenedPath: "deleted",
+		UserID:        user.ID,
+		Tags:          []string{"archive"},
+		IsDeleted:     true,
+	}))
+
+	t.Run("responds with unauthorized status without JWT", func(t *testing.T) {
+		code, _ := do(t, nil, `{ me { id } }`, nil)
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("resolves user with links and stats in one query", func(t *testing.T) {
+		code, response := do(t, &user, `{
+			me { linksCount tags }
+			links(filter: {tags: ["docs"]}) { items { originalUrl title tags stats { clicks } } nextCursor }
+		}`, nil)
+		require.Equal(t, http.StatusOK, code)
+		require.Empty(t, response.Errors)
+		assert.JSONEq(t, `{
+			"me": {"linksCount": 1, "tags": ["docs"]},
+			"links": {
+				"items": [{"originalUrl": "http://example.com", "title": "Example", "tags": ["docs"], "stats": {"clicks": 1}}],
+				"nextCursor": null
+			}
+		}`, string(response.Data))
+	})
+
+	t.Run("skips deleted links", func(t *testing.T) {
+		code, response := do(t, &user, `{
+			me { tags }
+			links { items { originalUrl } }
+			page: links(first: 10) { items { originalUrl } }
+		}`, nil)
+		require.Equal(t, http.StatusOK, code)
+		require.Empty(t, response.Errors)
+		assert.JSONEq(t, `{
+			"me": {"tags": ["docs"]},
+			"links": {"items": [{"originalUrl": "http://example.com"}]},
+			"page": {"items": [{"originalUrl": "http://example.com"}]}
+		}`, string(response.Data))
+	})
+
+	t.Run("resolves link of user only", func(t *testing.T) {
+		query := `query($code: String!) { link(code: $code) { originalUrl } }`
+		_, response := do(t, &user, query, map[string]interface{}{"code": created.CreateLink.Code})
+		assert.JSONEq(t, `{"link": {"originalUrl": "http://example.com"}}`, string(response.Data))
+
+		_, response = do(t, &user, query, map[string]interface{}{"code": "other"})
+		assert.JSONEq(t, `{"link": null}`, string(response.Data))
+	})
+
+	t.Run("updates link", func(t *testing.T) {
+		_, response := do(
+			t, &user,
+			`mutation($code: String!) { updateLink(code: $code, input: {folder: "work"}) { folder title } }`,
+			map[string]interface{}{"code": created.CreateLink.Code},
+		)
+		require.Empty(t, response.Errors)
+		assert.JSONEq(t, `{"updateLink": {"folder": "work", "title": "Example"}}`, string(response.Data))
+
+		_, response = do(t, &user, `mutation { updateLink(code: "other", input: {folder: "work"}) { folder } }`, nil)
+		require.Len(t, response.Errors, 1)
+		assert.Equal(t, "link not found", response.Errors[0].Message)
+		assert.Equal(t, map[string]string{"code": "not_found"}, response.Errors[0].Extensions)
+	})
+
+	t.Run("clears activity window and fallback URL", func(t *testing.T) {
+		variables := map[string]interface{}{"code": created.CreateLink.Code}
+		_, response := do(
+			t, &user,
+			`mutation($code: String!) {
+				updateLink(code: $code, input: {activeFrom: "2024-01-01T00:00:00Z", fallbackUrl: "http://fallback.com"}) {
+					activeFrom fallbackUrl
+				}
+			}`,
+			variables,
+		)
+		require.Empty(t, response.Errors)
+		assert.JSONEq(t, `{"updateLink": {"activeFrom": "2024-01-01T00:00:00Z", "fallbackUrl": "http://fallback.com"}}`, string(response.Data))
+
+		_, response = do(
+			t, &user,
+			`mutation($code: String!) {
+				updateLink(code: $code, input: {clearActiveFrom: true, clearFallbackUrl: true}) { activeFrom fallbackUrl }
+			}`,
+			variables,
+		)
+		require.Empty(t, response.Errors)
+		assert.JSONEq(t, `{"updateLink": {"activeFrom": null, "fallbackUrl": null}}`, string(response.Data))
+	})
+
+	t.Run("reports conflict with existing short URL", func(t *testing.T) {
+		_, response := do(t, &user, createLink, map[string]interface{}{
+			"input": map[string]interface{}{"originalUrl": "http://example.com"},
+		})
+		require.Len(t, response.Errors, 1)
+		assert.Equal(t, "original URL is already shortened", response.Errors[0].Message)
+		assert.Equal(t, map[string]string{"code": "already_exists", "short_url": shortURL}, response.Errors[0].Extensions)
+	})
+
+	t.Run("reports invalid link settings", func(t *testing.T) {
+		_, response := do(t, &user, createLink, map[string]interface{}{
+			"input": map[string]interface{}{"originalUrl": "http://example.org", "maxClicks": -1},
+		})
+		require.Len(t, response.Errors, 1)
+		assert.Equal(t, services.ErrInvalidMaxClicks.Error(), response.Errors[0].Message)
+		assert.Equal(t, map[string]string{"code": "validation_failed"}, response.Errors[0].Extensions)
+	})
+
+	t.Run("paginates links", func(t *testing.T) {
+		_, response := do(t, &user, createLink, map[string]interface{}{
+			"input": map[string]interface{}{"originalUrl": "http://example.org"},
+		})
+		require.Empty(t, response.Errors)
+
+		query := `query($after: String) { links(first: 1, after: $after, order: DESC) { items { originalUrl } nextCursor } }`
+		var page struct {
+			Links struct {
+				Items []struct {
+					OriginalURL string `json:"originalUrl"`
+				} `json:"items"`
+				NextCursor *string `json:"nextCursor"`
+			} `json:"links"`
+		}
+		_, response = do(t, &user, query, nil)
+		require.Empty(t, response.Errors)
+		require.NoError(t, json.Unmarshal(response.Data, &page))
+		require.Len(t, page.Links.Items, 1)
+		assert.Equal(t, "http://example.org", page.Links.Items[0].OriginalURL)
+		require.NotNil(t, page.Links.NextCursor)
+
+		_, response = do(t, &user, query, map[string]interface{}{"after": *page.Links.NextCursor})
+		require.Empty(t, response.Errors)
+		require.NoError(t, json.Unmarshal(response.Data, &page))
+		require.Len(t, page.Links.Items, 1)
+		assert.Equal(t, "http://example.com", page.Links.Items[0].OriginalURL)
+		assert.Nil(t, page.Links.NextCursor)
+	})
+
+	t.Run("deletes links in background", func(t *testing.T) {
+		_, response := do(t, &user, `mutation { deleteLinks(codes: ["abc"]) }`, nil)
+		require.Empty(t, response.Errors)
+		assert.JSONEq(t, `{"deleteLinks": true}`, string(response.Data))
+	})
+}
@@ -0,0 +1,294 @@
+package graphql
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+)
+
+// User link
+type linkResolver struct {
+	*resolver
+	record models.Record
+}
+
+func (l *linkResolver) Code() string {
+	return l.record.ShortenedPath
+}
+
+func (l *linkResolver) Domain() *string {
+	return optional(l.record.Domain)
+}
+
+func (l *linkResolver) ShortURL() string {
+	return l.config.ShortURL(l.record.Domain, l.record.ShortenedPath)
+}
+
+func (l *linkResolver) OriginalURL() string {
+	return l.record.OriginalURL
+}
+
+func (l *linkResolver) Title() *string {
+	return optional(l.record.Title)
+}
+
+func (l *linkResolver) Description() *string {
+	return optional(l.record.Description)
+}
+
+func (l *linkResolver) Tags() []string {
+	if l.record.Tags == nil {
+		return []string{}
+	}
+
+	return l.record.Tags
+}
+
+func (l *linkResolver) Folder() *string {
+	return optional(l.record.Folder)
+}
+
+func (l *linkResolver) MaxClicks() *int32 {
+	if !l.record.IsClickLimited() {
+		return nil
+	}
+	maxClicks := int32(l.record.MaxClicks)
+
+	return &maxClicks
+}
+
+func (l *linkResolver) RemainingClicks() *int32 {
+	if !l.record.IsClickLimited() {
+		return nil
+	}
+	remainingClicks := int32(l.record.RemainingClicks)
+
+	return &remainingClicks
+}
+
+func (l *linkResolver) ActiveFrom() *graphql.Time {
+	return timeToGraphQL(l.record.ActiveFrom)
+}
+
+func (l *linkResolver) ActiveUntil() *graphql.Time {
+	return timeToGraphQL(l.record.ActiveUntil)
+}
+
+func (l *linkResolver) FallbackURL() *string {
+	return optional(l.record.FallbackURL)
+}
+
+func (l *linkResolver) Rules() []*routingRuleResolver {
+	result := make([]*routingRuleResolver, len(l.record.Rules))
+	for i := range l.record.Rules {
+		result[i] = &routingRuleResolver{rule: l.record.Rules[i]}
+	}
+
+	return result
+}
+
+func (l *linkResolver) Variants() []*variantResolver {
+	result := make([]*variantResolver, len(l.record.Variants))
+	for i := range l.record.Variants {
+		result[i] = &variantResolver{variant: l.record.Variants[i]}
+	}
+
+	return result
+}
+
+// UTM parameters in alphabetical order
+func (l *linkResolver) UTMParams() []*utmParamResolver {
+	result := make([]*utmParamResolver, 0, len(l.record.UTMParams))
+	for name, value := range l.record.UTMParams {
+		result = append(result, &utmParamResolver{name: name, value: value})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+
+	return result
+}
+
+func (l *linkResolver) QueryMode() *string {
+	return optional(l.record.QueryMode)
+}
+
+func (l *linkResolver) RedirectCode() *int32 {
+	if l.record.RedirectCode == 0 {
+		return nil
+	}
+	redirectCode := int32(l.record.RedirectCode)
+
+	return &redirectCode
+}
+
+func (l *linkResolver) Interstitial() bool {
+	return l.record.Interstitial
+}
+
+func (l *linkResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: l.record.CreatedAt}
+}
+
+func (l *linkResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: l.record.UpdatedAt}
+}
+
+func (l *linkResolver) Stats(ctx context.Context) (*clickStatsResolver, error) {
+	stats, err := l.store.ClickStats(ctx, l.record.Domain, l.record.ShortenedPath)
+	if err != nil {
+		return nil, l.serviceError(err)
+	}
+
+	return &clickStatsResolver{stats: stats}, nil
+}
+
+type routingRuleResolver struct {
+	rule models.RoutingRule
+}
+
+func (r *routingRuleResolver) Field() string {
+	return r.rule.Field
+}
+
+func (r *routingRuleResolver) Pattern() string {
+	return r.rule.Pattern
+}
+
+func (r *routingRuleResolver) Destination() string {
+	return r.rule.Destination
+}
+
+type variantResolver struct {
+	variant models.Variant
+}
+
+func (v *variantResolver) Name() string {
+	return v.variant.Name
+}
+
+func (v *variantResolver) URL() string {
+	return v.variant.URL
+}
+
+func (v *variantResolver) Weight() int32 {
+	return int32(v.variant.Weight)
+}
+
+type utmParamResolver struct {
+	name  string
+	value string
+}
+
+func (p *utmParamResolver) Name() string {
+	return p.name
+}
+
+func (p *utmParamResolver) Value() string {
+	return p.value
+}
+
+type clickStatsResolver struct {
+	stats models.ClickStats
+}
+
+func (s *clickStatsResolver) Clicks() int32 {
+	return int32(s.stats.Total)
+}
+
+// Clicks by variant in alphabetical order of variant names
+func (s *clickStatsResolver) Variants() []*variantClicksResolver {
+	result := make([]*variantClicksResolver, 0, len(s.stats.ByVariant))
+	for name, clicks := range s.stats.ByVariant {
+		result = append(result, &variantClicksResolver{name: name, clicks: clicks})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+
+	return result
+}
+
+type variantClicksResolver struct {
+	name   string
+	clicks int
+}
+
+func (v *variantClicksResolver) Name() string {
+	return v.name
+}
+
+func (v *variantClicksResolver) Clicks() int32 {
+	return int32(v.clicks)
+}
+
+func rulesFromInput(inputs []routingRuleInput) []models.RoutingRule {
+	if inputs == nil {
+		return nil
+	}
+	rules := make([]models.RoutingRule, len(inputs))
+	for i, input := range inputs {
+		rules[i] = models.RoutingRule{Field: input.Field, Pattern: input.Pattern, Destination: input.Destination}
+	}
+
+	return rules
+}
+
+func variantsFromInput(inputs []variantInput) []models.Variant {
+	if inputs == nil {
+		return nil
+	}
+	variants := make([]models.Variant, len(inputs))
+	for i, input := range inputs {
+		variants[i] = models.Variant{Name: input.Name, URL: input.URL, Weight: int(input.Weight)}
+	}
+
+	return variants
+}
+
+func utmParamsFromInput(inputs []utmParamInput) map[string]string {
+	if inputs == nil {
+		return nil
+	}
+	params := make(map[string]string, len(inputs))
+	for _, input := range inputs {
+		params[input.Name] = input.Value
+	}
+
+	return params
+}
+
+func timeFromGraphQL(t *graphql.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	return &t.Time
+}
+
+func timeToGraphQL(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+
+	return &graphql.Time{Time: *t}
+}
+
+// Empty string is null
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+// Zero value of absent argument
+func deref[T any](p *T) T {
+	var value T
+	if p != nil {
+		value = *p
+	}
+
+	return value
+}
@@ -0,0 +1,437 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/graph-gophers/graphql-go"
+	"go.uber.org/zap"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/apierror"
+	"github.com/ilya-burinskiy/urlshort/internal/app/configs"
+	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
+	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+// Root resolver of queries and mutations
+type resolver struct {
+	config     configs.Config
+	store      storage.Storage
+	shortener  services.URLShortener
+	urlUpdater services.URLUpdater
+	urlDeleter services.DeferredDeleter
+}
+
+// Errors reported by services for invalid link settings
+var validationErrors = []error{
+	services.ErrInvalidMaxClicks,
+	services.ErrInvalidActivityWindow,
+	services.ErrInvalidTitle,
+	services.ErrInvalidDescription,
+	services.ErrInvalidRoutingRule,
+	services.ErrInvalidVariant,
+	services.ErrInvalidQueryParams,
+	services.ErrInvalidRedirectCode,
+	services.ErrInvalidTags,
+	services.ErrInvalidFolder,
+	services.ErrInvalidPage,
+}
+
+// Resolver error. Code of versioned API and details are sent as extensions
+type resolverError struct {
+	err *apierror.Error
+}
+
+func (e resolverError) Error() string {
+	return e.err.Message
+}
+
+func (e resolverError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.err.Code}
+	for name, value := range e.err.Details {
+		extensions[name] = value
+	}
+
+	return extensions
+}
+
+func newError(status int, message string) resolverError {
+	return resolverError{err: apierror.New(status, message)}
+}
+
+// Error of service call. Already shortened URL is reported with short URL of
+// existing link, internal errors are logged and not disclosed
+func (r *resolver) serviceError(err error) error {
+	var notUniqErr *storage.ErrNotUnique
+	switch {
+	case errors.As(err, &notUniqErr):
+		shortURL := r.config.ShortURL(notUniqErr.Record.Domain, notUniqErr.Record.ShortenedPath)
+		return resolverError{
+			err: apierror.New(http.StatusConflict, "original URL is already shortened").WithDetail("short_url", shortURL),
+		}
+	case errors.Is(err, storage.ErrNotFound):
+		return newError(http.StatusNotFound, "link not found")
+	}
+	for _, validationErr := range validationErrors {
+		if errors.Is(err, validationErr) {
+			return newError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+
+	logger.Log.Info("graphql resolver error", zap.Error(err))
+	return newError(http.StatusInternalServerError, "internal error")
+}
+
+func userFromContext(ctx context.Context) models.User {
+	userID, _ := middlewares.UserIDFromContext(ctx)
+	return models.User{ID: userID}
+}
+
+func (r *resolver) Me(ctx context.Context) *userResolver {
+	return &userResolver{resolver: r, user: userFromContext(ctx)}
+}
+
+type linksArgs struct {
+	Filter *linkFilter
+	First  *int32
+	After  *string
+	Sort   *string
+	Order  *string
+}
+
+type linkFilter struct {
+	Tags        *[]string
+	Folder      *string
+	Q           *string
+	CreatedFrom *graphql.Time
+	CreatedTo   *graphql.Time
+}
+
+func (r *resolver) Links(ctx context.Context, args linksArgs) (*linkPageResolver, error) {
+	filter := models.RecordFilter{ExcludeDeleted: true}
+	if args.Filter != nil {
+		filter = models.RecordFilter{
+			Tags:           deref(args.Filter.Tags),
+			Folder:         deref(args.Filter.Folder),
+			Query:          deref(args.Filter.Q),
+			CreatedFrom:    timeFromGraphQL(args.Filter.CreatedFrom),
+			CreatedTo:      timeFromGraphQL(args.Filter.CreatedTo),
+			ExcludeDeleted: true,
+		}
+	}
+
+	user := userFromContext(ctx)
+	if args.First == nil && args.After == nil && args.Sort == nil && args.Order == nil {
+		records, err := r.store.FindByUser(ctx, user, filter)
+		if err != nil {
+			return nil, r.serviceError(err)
+		}
+		return &linkPageResolver{items: r.links(records)}, nil
+	}
+
+	var limit int
+	if args.First != nil {
+		limit = int(*args.First)
+	}
+	page, err := services.NewPage(
+		limit,
+		deref(args.After),
+		strings.ToLower(deref(args.Sort)),
+		strings.ToLower(deref(args.Order)),
+	)
+	if err != nil {
+		return nil, r.serviceError(err)
+	}
+	records, next, err := r.store.FindByUserPage(ctx, user, filter, page)
+	if err != nil {
+		return nil, r.serviceError(err)
+	}
+
+	return &linkPageResolver{items: r.links(records), nextCursor: services.EncodeCursor(page, next)}, nil
+}
+
+type linkArgs struct {
+	Code   string
+	Domain *string
+}
+
+func (r *resolver) Link(ctx context.Context, args linkArgs) (*linkResolver, error) {
+	domain, ok := r.config.NormalizeDomain(deref(args.Domain))
+	if !ok {
+		return nil, nil
+	}
+	record, err := r.store.FindByShortenedPath(ctx, domain, args.Code)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, r.serviceError(err)
+	}
+	if record.UserID != userFromContext(ctx).ID || record.IsDeleted {
+		return nil, nil
+	}
+
+	return r.link(record), nil
+}
+
+type createLinkArgs struct {
+	Input struct {
+		OriginalURL  string
+		Domain       *string
+		Title        *string
+		Description  *string
+		Tags         *[]string
+		Folder       *string
+		MaxClicks    *int32
+		ActiveFrom   *graphql.Time
+		ActiveUntil  *graphql.Time
+		FallbackURL  *string
+		Rules        *[]routingRuleInput
+		Variants     *[]variantInput
+		UTMParams    *[]utmParamInput
+		QueryMode    *string
+		RedirectCode *int32
+		Interstitial *bool
+	}
+}
+
+type routingRuleInput struct {
+	Field       string
+	Pattern     string
+	Destination string
+}
+
+type variantInput struct {
+	Name   string
+	URL    string
+	Weight int32
+}
+
+type utmParamInput struct {
+	Name  string
+	Value string
+}
+
+func (r *resolver) CreateLink(ctx context.Context, args createLinkArgs) (*linkResolver, error) {
+	input := args.Input
+	u, err := url.Parse(input.OriginalURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, newError(http.StatusUnprocessableEntity, "original URL must be absolute http or https URL")
+	}
+	domain, ok := r.config.NormalizeDomain(deref(input.Domain))
+	if !ok {
+		return nil, newError(http.StatusUnprocessableEntity, "unknown domain")
+	}
+
+	record, err := r.shortener.Shortify(
+		ctx,
+		models.Record{
+			OriginalURL:  input.OriginalURL,
+			Domain:       domain,
+			MaxClicks:    int(deref(input.MaxClicks)),
+			ActiveFrom:   timeFromGraphQL(input.ActiveFrom),
+			ActiveUntil:  timeFromGraphQL(input.ActiveUntil),
+			FallbackURL:  deref(input.FallbackURL),
+			Rules:        rulesFromInput(deref(input.Rules)),
+			Variants:     variantsFromInput(deref(input.Variants)),
+			UTMParams:    utmParamsFromInput(deref(input.UTMParams)),
+			QueryMode:    deref(input.QueryMode),
+			RedirectCode: int(deref(input.RedirectCode)),
+			Title:        deref(input.Title),
+			Description:  deref(input.Description),
+			Interstitial: deref(input.Interstitial),
+			Tags:         deref(input.Tags),
+			Folder:       deref(input.Folder),
+		},
+		userFromContext(ctx),
+	)
+	if err != nil {
+		return nil, r.serviceError(err)
+	}
+
+	return r.link(record), nil
+}
+
+type updateLinkArgs struct {
+	Code   string
+	Domain *string
+	Input  struct {
+		Title        *string
+		Description  *string
+		Tags         *[]string
+		Folder       *string
+		ActiveFrom   *graphql.Time
+		ActiveUntil  *graphql.Time
+		FallbackURL  *string
+		Rules        *[]routingRuleInput
+		Variants     *[]variantInput
+		UTMParams    *[]utmParamInput
+		QueryMode    *string
+		RedirectCode *int32
+		Interstitial *bool
+
+		ClearActiveFrom  *bool
+		ClearActiveUntil *bool
+		ClearFallbackURL *bool
+	}
+}
+
+func (r *resolver) UpdateLink(ctx context.Context, args updateLinkArgs) (*linkResolver, error) {
+	input := args.Input
+	patch := services.RecordPatch{
+		ActiveFrom:   timeFromGraphQL(input.ActiveFrom),
+		ActiveUntil:  timeFromGraphQL(input.ActiveUntil),
+		FallbackURL:  input.FallbackURL,
+		QueryMode:    input.QueryMode,
+		Title:        input.Title,
+		Description:  input.Description,
+		Interstitial: input.Interstitial,
+		Tags:         input.Tags,
+		Folder:       input.Folder,
+
+		ClearActiveFrom:  deref(input.ClearActiveFrom),
+		ClearActiveUntil: deref(input.ClearActiveUntil),
+		ClearFallbackURL: deref(input.ClearFallbackURL),
+	}
+	if input.Rules != nil {
+		rules := rulesFromInput(*input.Rules)
+		patch.Rules = &rules
+	}
+	if input.Variants != nil {
+		variants := variantsFromInput(*input.Variants)
+		patch.Variants = &variants
+	}
+	if input.UTMParams != nil {
+		utmParams := utmParamsFromInput(*input.UTMParams)
+		patch.UTMParams = &utmParams
+	}
+	if input.RedirectCode != nil {
+		redirectCode := int(*input.RedirectCode)
+		patch.RedirectCode = &redirectCode
+	}
+
+	// Links on unknown domains are not found
+	domain, ok := r.config.NormalizeDomain(deref(args.Domain))
+	if !ok {
+		return nil, newError(http.StatusNotFound, "link not found")
+	}
+	record, err := r.urlUpdater.Update(ctx, domain, args.Code, patch, userFromContext(ctx))
+	if err != nil {
+		return nil, r.serviceError(err)
+	}
+
+	return r.link(record), nil
+}
+
+type deleteLinksArgs struct {
+	Codes  []string
+	Domain *string
+}
+
+func (r *resolver) DeleteLinks(ctx context.Context, args deleteLinksArgs) (bool, error) {
+	domain, ok := r.config.NormalizeDomain(deref(args.Domain))
+	if !ok {
+		return false, newError(http.StatusUnprocessableEntity, "unknown domain")
+	}
+	user := userFromContext(ctx)
+	for _, code := range args.Codes {
+		r.urlDeleter.Enqueue(models.Record{
+			Domain:        domain,
+			ShortenedPath: code,
+			UserID:        user.ID,
+		})
+	}
+
+	return true, nil
+}
+
+func (r *resolver) link(record models.Record) *linkResolver {
+	return &linkResolver{resolver: r, record: record}
+}
+
+func (r *resolver) links(records []models.Record) []*linkResolver {
+	result := make([]*linkResolver, len(records))
+	for i, record := range records {
+		result[i] = r.link(record)
+	}
+
+	return result
+}
+
+// Authenticated user. Links are loaded once for all fields
+type userResolver struct {
+	*resolver
+	user    models.User
+	once    sync.Once
+	records []models.Record
+	err     error
+}
+
+func (u *userResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(u.user.ID))
+}
+
+func (u *userResolver) LinksCount(ctx context.Context) (int32, error) {
+	records, err := u.load(ctx)
+	return int32(len(records)), err
+}
+
+func (u *userResolver) Tags(ctx context.Context) ([]string, error) {
+	records, err := u.load(ctx)
+	return distinct(records, func(r models.Record) []string { return r.Tags }), err
+}
+
+func (u *userResolver) Folders(ctx context.Context) ([]string, error) {
+	records, err := u.load(ctx)
+	return distinct(records, func(r models.Record) []string { return []string{r.Folder} }), err
+}
+
+func (u *userResolver) load(ctx context.Context) ([]models.Record, error) {
+	u.once.Do(func() {
+		u.records, u.err = u.store.FindByUser(ctx, u.user, models.RecordFilter{ExcludeDeleted: true})
+		if u.err != nil {
+			u.err = u.serviceError(u.err)
+		}
+	})
+
+	return u.records, u.err
+}
+
+// Distinct non empty values of records in alphabetical order
+func distinct(records []models.Record, values func(models.Record) []string) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0)
+	for _, record := range records {
+		for _, value := range values(record) {
+			if value != "" && !seen[value] {
+				seen[value] = true
+				result = append(result, value)
+			}
+		}
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+type linkPageResolver struct {
+	items      []*linkResolver
+	nextCursor string
+}
+
+func (p *linkPageResolver) Items() []*linkResolver {
+	return p.items
+}
+
+func (p *linkPageResolver) NextCursor() *string {
+	return optional(p.nextCursor)
+}
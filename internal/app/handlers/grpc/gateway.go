@@ -0,0 +1,177 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/auth"
+	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
+	"github.com/ilya-burinskiy/urlshort/internal/app/tracing"
+)
+
+const gatewayBufferSize = 1024 * 1024
+
+// JSON API generated from HTTP bindings of URL service. Requests are proxied
+// to gRPC server over in-memory connection, so they pass the same interceptors
+// as requests of gRPC clients. Trace context of HTTP request is sent along
+type Gateway struct {
+	http.Handler
+	conn *grpc.ClientConn
+}
+
+// Serve srv over in-memory connection and proxy HTTP requests to it. Caller
+// stops srv, gateway connection is closed with Close
+func NewGateway(ctx context.Context, srv *grpc.Server) (*Gateway, error) {
+	listener := bufconn.Listen(gatewayBufferSize)
+	go func() {
+		if err := srv.Serve(listener); err != nil {
+			logger.Log.Info("gateway grpc server stopped", zap.Error(err))
+		}
+	}()
+
+	conn, err := grpc.DialContext(
+		ctx,
+		"bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(tracing.UnaryClientInterceptor),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial gateway grpc server: %w", err)
+	}
+
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithMetadata(jwtFromCookie),
+		runtime.WithIncomingHeaderMatcher(incomingHeaderMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithForwardResponseOption(func(ctx context.Context, w http.ResponseWriter, _ proto.Message) error {
+			setJWTCookie(ctx, w)
+			return nil
+		}),
+		runtime.WithErrorHandler(func(
+			ctx context.Context,
+			mux *runtime.ServeMux,
+			marshaler runtime.Marshaler,
+			w http.ResponseWriter,
+			r *http.Request,
+			err error) {
+
+			setJWTCookie(ctx, w)
+			if code, ok := linkErrorStatuses[linkErrorReason(err)]; ok {
+				err = &runtime.HTTPStatusError{HTTPStatus: code, Err: err}
+			}
+			runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+		}),
+	)
+	if err = RegisterURLServiceHandler(ctx, mux, conn); err != nil {
+		if closeErr := conn.Close(); closeErr != nil {
+			logger.Log.Info("failed to close gateway connection", zap.Error(closeErr))
+		}
+		return nil, fmt.Errorf("failed to register gateway handler: %w", err)
+	}
+
+	return &Gateway{Handler: mux, conn: conn}, nil
+}
+
+// Close gateway connection
+func (g *Gateway) Close() error {
+	return g.conn.Close()
+}
+
+// HTTP statuses of links which can not be followed, same as in HTTP API.
+// Not yet active links keep default status of failed precondition
+var linkErrorStatuses = map[string]int{
+	reasonLinkDeleted:     http.StatusGone,
+	reasonLinkEnded:       http.StatusGone,
+	reasonClicksExhausted: http.StatusGone,
+}
+
+func linkErrorReason(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == errorDomain {
+			return info.Reason
+		}
+	}
+
+	return ""
+}
+
+// JWT cookie is passed as "jwt" metadata, as gRPC clients do
+func jwtFromCookie(_ context.Context, r *http.Request) metadata.MD {
+	cookie, err := r.Cookie("jwt")
+	if err != nil {
+		return nil
+	}
+
+	return metadata.Pairs("jwt", cookie.Value)
+}
+
+// Only listed headers are forwarded as metadata. X-Real-IP is needed by
+// trusted subnet check, Accept-Language by routing rules. Other headers,
+// including Grpc-Metadata- ones, are dropped, so clients can not send
+// metadata set by the gateway and interceptors
+func incomingHeaderMatcher(key string) (string, bool) {
+	switch textproto.CanonicalMIMEHeaderKey(key) {
+	case "X-Real-Ip":
+		return "x-real-ip", true
+	case "Accept-Language":
+		return "accept-language", true
+	default:
+		return "", false
+	}
+}
+
+// JWT is sent as cookie only
+func outgoingHeaderMatcher(key string) (string, bool) {
+	if key == "jwt" {
+		return "", false
+	}
+
+	return fmt.Sprintf("%s%s", runtime.MetadataHeaderPrefix, key), true
+}
+
+func setJWTCookie(ctx context.Context, w http.ResponseWriter) {
+	md, ok := runtime.ServerMetadataFromContext(ctx)
+	if !ok {
+		return
+	}
+	token := firstMetadataValue(md.HeaderMD, "jwt")
+	if token == "" {
+		return
+	}
+
+	http.SetCookie(
+		w,
+		&http.Cookie{
+			Name:     "jwt",
+			Value:    token,
+			MaxAge:   int(auth.TokenExp / time.Second),
+			HttpOnly: true,
+		},
+	)
+}
@@ -0,0 +1,240 @@
+package grpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	pb "github.com/ilya-burinskiy/urlshort/internal/app/handlers/grpc"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+func TestGateway(t *testing.T) {
+	config := defaultConfig
+	config.TrustedSubnet = "192.168.0.0/24"
+	store := storage.NewMapStorage(nil)
+	userAuthenticator := services.NewUserAuthenticator(store)
+	ipChecker := services.NewIPChecker(config)
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			pb.AuthenticateInterceptor(userAuthenticator),
+			pb.TrustedIPInterceptor(ipChecker, nil),
+		),
+		grpc.ChainStreamInterceptor(
+			pb.StreamAuthenticateInterceptor(userAuthenticator),
+			pb.StreamTrustedIPInterceptor(ipChecker, nil),
+		),
+	)
+	pb.RegisterURLServiceServer(srv, pb.NewURLsServer(
+		config,
+		store,
+		userAuthenticator,
+		services.NewURLShortener(8, services.RandHexStrGenerator{}, store),
+		services.NewURLUpdater(store),
+		services.NewDeferredDeleter(store),
+		services.NewURLExporter(store, config.ShortURL),
+	))
+	defer srv.Stop()
+	gateway, err := pb.NewGateway(context.Background(), srv)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, gateway.Close())
+	}()
+	testServer := httptest.NewServer(gateway)
+	defer testServer.Close()
+
+	do := func(t *testing.T, method, path, body string, setup func(*http.Request)) (*http.Response, string) {
+		request, err := http.NewRequest(method, testServer.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if setup != nil {
+			setup(request)
+		}
+		response, err := testServer.Client().Do(request)
+		require.NoError(t, err)
+		resBody, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		require.NoError(t, response.Body.Close())
+
+		return response, string(resBody)
+	}
+
+	response, body := do(t, http.MethodPost, "/api/v2/urls", `{"original_url":"http://example.com","tags":["docs"]}`, nil)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	var jwtCookie *http.Cookie
+	for _, cookie := range response.Cookies() {
+		if cookie.Name == "jwt" {
+			jwtCookie = cookie
+		}
+	}
+	require.NotNil(t, jwtCookie)
+	record, err := store.FindByOriginalURL(context.Background(), "http://example.com")
+	require.NoError(t, err)
+	shortURL := config.ShortURL(record.Domain, record.ShortenedPath)
+	assert.Equal(t, `{"short_url":"`+shortURL+`"}`, body)
+	assert.Empty(t, response.Header.Values("Grpc-Metadata-Jwt"))
+	withJWT := func(r *http.Request) { r.AddCookie(jwtCookie) }
+
+	t.Run("responds with conflict status and existing short URL", func(t *testing.T) {
+		response, body := do(t, http.MethodPost, "/api/v2/urls", `{"original_url":"http://example.com"}`, withJWT)
+		assert.Equal(t, http.StatusConflict, response.StatusCode)
+		assert.JSONEq(t, `{
+			"code": 6,
+			"message": "original URL is already shortened",
+			"details": [{
+				"@type": "type.googleapis.com/google.rpc.ResourceInfo",
+				"resource_type": "short_url",
+				"resource_name": "`+shortURL+`"
+			}]
+		}`, body)
+	})
+
+	t.Run("responds with bad request status on invalid argument", func(t *testing.T) {
+		response, body := do(t, http.MethodPost, "/api/v2/urls", `{"original_url":"http://example.org","domain":"unknown.ru"}`, nil)
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		assert.JSONEq(t, `{"code":3,"message":"unknown domain \"unknown.ru\""}`, body)
+	})
+
+	t.Run("responds with not found status", func(t *testing.T) {
+		response, body := do(t, http.MethodGet, "/api/v2/urls/unknown", "", nil)
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+		assert.JSONEq(t, `{"code":5,"message":"original URL for \"unknown\" not found"}`, body)
+	})
+
+	t.Run("responds with user URLs", func(t *testing.T) {
+		response, body := do(t, http.MethodGet, "/api/v2/user/urls?tags=docs", "", withJWT)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Contains(t, body, `"short_url":"`+shortURL+`"`)
+		assert.Contains(t, body, `"original_url":"http://example.com"`)
+	})
+
+	t.Run("responds with unauthenticated status without jwt", func(t *testing.T) {
+		response, _ := do(t, http.MethodGet, "/api/v2/user/urls", "", nil)
+		assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+	})
+
+	t.Run("streams exported URLs as JSON lines", func(t *testing.T) {
+		response, body := do(t, http.MethodGet, "/api/v2/user/urls/export", "", withJWT)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		lines := strings.Split(strings.TrimSpace(body), "\n")
+		require.Len(t, lines, 1)
+		assert.Contains(t, lines[0], `{"result":{"short_url":"`+shortURL+`"`)
+	})
+
+	t.Run("checks trusted subnet by X-Real-IP header", func(t *testing.T) {
+		response, _ := do(t, http.MethodGet, "/api/v2/internal/stats", "", func(r *http.Request) {
+			withJWT(r)
+			r.Header.Set("X-Real-IP", "10.0.0.1")
+		})
+		assert.Equal(t, http.StatusForbidden, response.StatusCode)
+
+		response, body := do(t, http.MethodGet, "/api/v2/internal/stats", "", func(r *http.Request) {
+			withJWT(r)
+			r.Header.Set("X-Real-IP", "192.168.0.10")
+		})
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.JSONEq(t, `{"urls":"1","users":"2"}`, body)
+	})
+
+	t.Run("streams result of every created item", func(t *testing.T) {
+		items := `{"item":{"original_url":"http://example.com/stream","correlation_id":"a"}}` + "\n" +
+			`{"item":{"original_url":"http://example.com/stream2","domain":"unknown.ru"}}` + "\n"
+		response, body := do(t, http.MethodPost, "/api/v2/urls/stream", items, withJWT)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		lines := strings.Split(strings.TrimSpace(body), "\n")
+		require.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"correlation_id":"a","short_url":"`)
+		assert.Contains(t, lines[1], `"index":1`)
+		assert.Contains(t, lines[1], `"error":"unknown domain \"unknown.ru\""`)
+	})
+
+	t.Run("does not let client metadata override authenticated user", func(t *testing.T) {
+		response, _ := do(t, http.MethodPost, "/api/v2/urls", `{"original_url":"http://example.net"}`, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var otherJWT *http.Cookie
+		for _, cookie := range response.Cookies() {
+			if cookie.Name == "jwt" {
+				otherJWT = cookie
+			}
+		}
+		require.NotNil(t, otherJWT)
+
+		response, body := do(t, http.MethodGet, "/api/v2/user/urls", "", func(r *http.Request) {
+			r.AddCookie(otherJWT)
+			r.Header.Set("Grpc-Metadata-User_id", strconv.Itoa(record.UserID))
+			r.Header.Set("Grpc-Metadata-Jwt", jwtCookie.Value)
+		})
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Contains(t, body, `"original_url":"http://example.net"`)
+		assert.NotContains(t, body, shortURL)
+	})
+
+	t.Run("responds with gone status when clicks exhausted", func(t *testing.T) {
+		response, body := do(t, http.MethodPost, "/api/v2/urls", `{"original_url":"http://example.com/once","max_clicks":1}`, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var created struct {
+			ShortURL string `json:"short_url"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(body), &created))
+		code := created.ShortURL[strings.LastIndex(created.ShortURL, "/")+1:]
+
+		response, _ = do(t, http.MethodGet, "/api/v2/urls/"+code, "", nil)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		response, body = do(t, http.MethodGet, "/api/v2/urls/"+code, "", nil)
+		assert.Equal(t, http.StatusGone, response.StatusCode)
+		var st struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(body), &st))
+		assert.Equal(t, int(codes.FailedPrecondition), st.Code)
+		assert.Equal(t, "clicks exhausted", st.Message)
+	})
+
+	t.Run("responds with HTTP API statuses of links out of activity window", func(t *testing.T) {
+		testCases := []struct {
+			name string
+			body string
+			code int
+		}{
+			{
+				name: "not yet active",
+				body: `{"original_url":"http://example.com/soon","active_from":"` +
+					time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`,
+				code: http.StatusBadRequest,
+			},
+			{
+				name: "ended",
+				body: `{"original_url":"http://example.com/ended","active_until":"` +
+					time.Now().Add(-time.Hour).Format(time.RFC3339) + `"}`,
+				code: http.StatusGone,
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				response, body := do(t, http.MethodPost, "/api/v2/urls", tc.body, nil)
+				require.Equal(t, http.StatusOK, response.StatusCode, body)
+				var created struct {
+					ShortURL string `json:"short_url"`
+				}
+				require.NoError(t, json.Unmarshal([]byte(body), &created))
+				code := created.ShortURL[strings.LastIndex(created.ShortURL, "/")+1:]
+
+				response, _ = do(t, http.MethodGet, "/api/v2/urls/"+code, "", nil)
+				assert.Equal(t, tc.code, response.StatusCode)
+			})
+		}
+	})
+}
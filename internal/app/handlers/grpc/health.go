@@ -0,0 +1,50 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
+)
+
+// Storage reachability check
+type StoragePinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Services reported by health server, empty name is the whole server
+var healthServices = []string{"", URLService_ServiceDesc.ServiceName}
+
+// Update health server status by storage reachability every interval until
+// ctx is done. Storage is checked right away, so status is known on start
+func WatchStorageHealth(ctx context.Context, healthServer *health.Server, store StoragePinger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		checkStorageHealth(ctx, healthServer, store, interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func checkStorageHealth(ctx context.Context, healthServer *health.Server, store StoragePinger, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	servingStatus := healthpb.HealthCheckResponse_SERVING
+	if err := store.Ping(ctx); err != nil {
+		logger.Log.Info("storage is unreachable", zap.Error(err))
+		servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	for _, service := range healthServices {
+		healthServer.SetServingStatus(service, servingStatus)
+	}
+}
@@ -4,29 +4,47 @@ import (
 	"context"
 	"errors"
 	"net"
-	"strconv"
 
 	"github.com/ilya-burinskiy/urlshort/internal/app/services"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionpbalpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
 	"google.golang.org/grpc/status"
 )
 
 var ingnoreAuthMethods = []string{
 	URLService_CreateURL_FullMethodName,
 	URLService_GetOriginalURL_FullMethodName,
+	URLService_GetURLPreview_FullMethodName,
+	URLService_GetQRCode_FullMethodName,
 	URLService_BatchCreateURL_FullMethodName,
 	URLService_PingDB_FullMethodName,
+	healthpb.Health_Check_FullMethodName,
+	healthpb.Health_Watch_FullMethodName,
+	reflectionpb.ServerReflection_ServerReflectionInfo_FullMethodName,
+	reflectionpbalpha.ServerReflection_ServerReflectionInfo_FullMethodName,
 }
 
 var ingoreIPCheckMethods = []string{
 	URLService_CreateURL_FullMethodName,
 	URLService_GetOriginalURL_FullMethodName,
+	URLService_GetURLPreview_FullMethodName,
+	URLService_GetQRCode_FullMethodName,
 	URLService_BatchCreateURL_FullMethodName,
+	URLService_StreamCreateURLs_FullMethodName,
 	URLService_GetUserURLs_FullMethodName,
+	URLService_StreamUserURLs_FullMethodName,
+	URLService_ExportUserURLs_FullMethodName,
+	URLService_UpdateUserURL_FullMethodName,
 	URLService_DeleteUserURLs_FullMethodName,
 	URLService_PingDB_FullMethodName,
+	healthpb.Health_Check_FullMethodName,
+	healthpb.Health_Watch_FullMethodName,
+	reflectionpb.ServerReflection_ServerReflectionInfo_FullMethodName,
+	reflectionpbalpha.ServerReflection_ServerReflectionInfo_FullMethodName,
 }
 
 // AuthenticateInterceptor
@@ -44,28 +62,18 @@ func AuthenticateInterceptor(userAuthenticator services.UserAuthenticator) func(
 			}
 		}
 
-		meta, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, status.Error(codes.Unauthenticated, "missing token")
+		ctx, err := authenticate(ctx, userAuthenticator)
+		if err != nil {
+			return nil, err
 		}
-		values := meta.Get("jwt")
-		if len(values) == 0 {
-			return nil, status.Error(codes.Unauthenticated, "missing token")
-		}
-
-		user, err := userAuthenticator.Auth(values[0])
-		if errors.Is(err, services.ErrInvalidJWT) {
-			return nil, status.Error(codes.Unauthenticated, "invalid jwt")
-		}
-		meta.Append("user_id", strconv.Itoa(user.ID))
-		ctx = metadata.NewIncomingContext(ctx, meta)
 
 		return handler(ctx, req)
 	}
 }
 
-// TrustedIPInterceptor
-func TrustedIPInterceptor(ipChecker services.IPChecker) func(
+// TrustedIPInterceptor. Peers with trusted client certificate pass the check
+// without "x-real-ip"
+func TrustedIPInterceptor(ipChecker services.IPChecker, trustedClients []string) func(
 	context.Context,
 	interface{},
 	*grpc.UnaryServerInfo,
@@ -78,19 +86,115 @@ func TrustedIPInterceptor(ipChecker services.IPChecker) func(
 				return handler(ctx, req)
 			}
 		}
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, status.Error(codes.PermissionDenied, "missing \"x-real-ip\"")
+		if err := checkTrustedIP(ctx, ipChecker, trustedClients); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthenticateInterceptor
+func StreamAuthenticateInterceptor(userAuthenticator services.UserAuthenticator) func(
+	interface{},
+	grpc.ServerStream,
+	*grpc.StreamServerInfo,
+	grpc.StreamHandler) error {
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		for _, imethod := range ingnoreAuthMethods {
+			if info.FullMethod == imethod {
+				return handler(srv, ss)
+			}
 		}
-		values := md.Get("x-real-ip")
-		if len(values) == 0 {
-			return nil, status.Error(codes.PermissionDenied, "missing \"x-real-ip\"")
+
+		ctx, err := authenticate(ss.Context(), userAuthenticator)
+		if err != nil {
+			return err
 		}
 
-		if !ipChecker.InTrustedSubnet(net.ParseIP(values[0])) {
-			return nil, status.Error(codes.PermissionDenied, "forbidden")
+		return handler(srv, contextServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// StreamTrustedIPInterceptor
+func StreamTrustedIPInterceptor(ipChecker services.IPChecker, trustedClients []string) func(
+	interface{},
+	grpc.ServerStream,
+	*grpc.StreamServerInfo,
+	grpc.StreamHandler) error {
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		for _, imethod := range ingoreIPCheckMethods {
+			if info.FullMethod == imethod {
+				return handler(srv, ss)
+			}
+		}
+		if err := checkTrustedIP(ss.Context(), ipChecker, trustedClients); err != nil {
+			return err
 		}
 
-		return handler(ctx, req)
+		return handler(srv, ss)
 	}
 }
+
+// Server stream with context replaced by interceptor
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+type contextKey string
+
+const userIDKey contextKey = "user_id"
+
+// Store authenticated user id in context. It is not kept in metadata, so
+// clients can not send it themselves
+func authenticate(ctx context.Context, userAuthenticator services.UserAuthenticator) (context.Context, error) {
+	meta, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing token")
+	}
+	values := meta.Get("jwt")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing token")
+	}
+
+	user, err := userAuthenticator.Auth(ctx, values[0])
+	if errors.Is(err, services.ErrInvalidJWT) {
+		return nil, status.Error(codes.Unauthenticated, "invalid jwt")
+	}
+
+	return context.WithValue(ctx, userIDKey, user.ID), nil
+}
+
+// Id of user authenticated by interceptor
+func userIDFromContext(ctx context.Context) int {
+	userID, _ := ctx.Value(userIDKey).(int)
+	return userID
+}
+
+func checkTrustedIP(ctx context.Context, ipChecker services.IPChecker, trustedClients []string) error {
+	if trustedClientCert(ctx, trustedClients) {
+		return nil
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.PermissionDenied, "missing \"x-real-ip\"")
+	}
+	values := md.Get("x-real-ip")
+	if len(values) == 0 {
+		return status.Error(codes.PermissionDenied, "missing \"x-real-ip\"")
+	}
+
+	if !ipChecker.InTrustedSubnet(net.ParseIP(values[0])) {
+		return status.Error(codes.PermissionDenied, "forbidden")
+	}
+
+	return nil
+}
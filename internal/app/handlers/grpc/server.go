@@ -3,19 +3,37 @@ package grpc
 import (
 	"context"
 	"errors"
-	"strconv"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/ilya-burinskiy/urlshort/internal/app/configs"
 	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
+	"github.com/ilya-burinskiy/urlshort/internal/app/metrics"
 	"github.com/ilya-burinskiy/urlshort/internal/app/models"
 	"github.com/ilya-burinskiy/urlshort/internal/app/services"
 	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
 	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Number of records read from storage at once by streaming RPCs
+const streamPageSize = 100
+
+// Error info of links which can not be followed
+const (
+	errorDomain           = "urlshort"
+	reasonLinkDeleted     = "LINK_DELETED"
+	reasonLinkNotActive   = "LINK_NOT_ACTIVE"
+	reasonLinkEnded       = "LINK_ENDED"
+	reasonClicksExhausted = "CLICKS_EXHAUSTED"
 )
 
 // URLsServer
@@ -25,7 +43,9 @@ type URLsServer struct {
 	store             storage.Storage
 	userAuthenticator services.UserAuthenticator
 	shortener  services.URLShortener
+	urlUpdater        services.URLUpdater
 	urlDeleter        services.DeferredDeleter
+	urlExporter       services.URLExporter
 }
 
 // NewURLsServer
@@ -34,14 +54,18 @@ func NewURLsServer(
 	store storage.Storage,
 	userAuthenticator services.UserAuthenticator,
 	shortener services.URLShortener,
-	urlDeleter services.DeferredDeleter) URLsServer {
+	urlUpdater services.URLUpdater,
+	urlDeleter services.DeferredDeleter,
+	urlExporter services.URLExporter) URLsServer {
 
 	return URLsServer{
 		config:            config,
 		store:             store,
 		userAuthenticator: userAuthenticator,
 		shortener:  shortener,
+		urlUpdater:        urlUpdater,
 		urlDeleter:        urlDeleter,
+		urlExporter:       urlExporter,
 	}
 }
 
@@ -56,29 +80,210 @@ func (s URLsServer) CreateURL(ctx context.Context, in *CreateURLRequest) (*Creat
 		return nil, status.Error(codes.Internal, "failed to set JWT")
 	}
 
-	record, err := s.shortener.Shortify(in.OriginalUrl, user)
+	domain, ok := s.config.NormalizeDomain(in.Domain)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown domain \"%s\"", in.Domain)
+	}
+	record, err := s.shortener.Shortify(
+		ctx,
+		models.Record{
+			OriginalURL:  in.OriginalUrl,
+			Domain:       domain,
+			MaxClicks:    int(in.MaxClicks),
+			ActiveFrom:   timeFromPb(in.ActiveFrom),
+			ActiveUntil:  timeFromPb(in.ActiveUntil),
+			FallbackURL:  in.FallbackUrl,
+			Rules:        rulesFromPb(in.Rules),
+			Variants:     variantsFromPb(in.Variants),
+			UTMParams:    utmParamsFromPb(in.UtmParams),
+			QueryMode:    in.QueryMode,
+			RedirectCode: int(in.RedirectCode),
+			Title:        in.Title,
+			Interstitial: in.Interstitial,
+			Tags:         in.Tags,
+			Folder:       in.Folder,
+			Description:  in.Description,
+		},
+		user,
+	)
 	if err != nil {
 		var notUniqErr *storage.ErrNotUnique
 		if errors.As(err, &notUniqErr) {
-			return nil, status.Errorf(codes.AlreadyExists, "")
+			return nil, s.alreadyExistsError(notUniqErr.Record)
 		}
-		return nil, status.Error(codes.Internal, "failed to create url")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	return &CreateURLResponse{ShortUrl: s.config.BaseURL + "/" + record.ShortenedPath}, nil
+	return &CreateURLResponse{ShortUrl: s.config.ShortURL(record.Domain, record.ShortenedPath)}, nil
 }
 
 // GetOriginalURL
 func (s URLsServer) GetOriginalURL(ctx context.Context, in *GetOriginalURLRequest) (*GetOriginalURLResponse, error) {
-	record, err := s.store.FindByShortenedPath(ctx, in.ShortUrl)
+	record, err := s.findRecord(ctx, in.Domain, in.ShortUrl)
 	if errors.Is(err, storage.ErrNotFound) {
+		metrics.LinkNotFound(metrics.TransportGRPC)
 		return nil, status.Errorf(codes.NotFound, "original URL for \"%s\" not found", in.ShortUrl)
 	}
-	if record.IsDeleted {
-		return nil, status.Error(codes.NotFound, "deleted")
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	now := time.Now()
+	target, err := services.LinkTarget(record, now)
+	if err != nil {
+		return nil, s.linkStatusError(err)
+	}
+	if record.ActivityAt(now) == models.Ended {
+		metrics.RedirectServed(metrics.TransportGRPC)
+		return &GetOriginalURLResponse{
+			OriginalUrl:  target,
+			RedirectCode: http.StatusTemporaryRedirect,
+		}, nil
+	}
+	if record.IsClickLimited() {
+		_, err = s.store.ConsumeClick(ctx, record.Domain, in.ShortUrl)
+		if errors.Is(err, storage.ErrClicksExhausted) {
+			return nil, s.linkStatusError(err)
+		}
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	destination := services.ResolveDestination(record, services.Visitor{
+		UserAgent:      firstMetadataValue(md, "user-agent"),
+		AcceptLanguage: firstMetadataValue(md, "accept-language"),
+		Country:        firstMetadataValue(md, s.config.CountryHeader),
+		Variant:        firstMetadataValue(md, "variant"),
+	})
+	err = s.store.SaveClick(ctx, models.Click{
+		Domain:        record.Domain,
+		ShortenedPath: in.ShortUrl,
+		Variant:       destination.Variant,
+		CreatedAt:     time.Now(),
+	})
+	if err != nil {
+		logger.Log.Info("failed to save click", zap.Error(err))
+	}
+
+	query, err := url.ParseQuery(in.Query)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid query")
+	}
+	redirectURL, err := services.BuildRedirectURL(destination.URL, record, query, s.config.QueryMode)
+	if err != nil {
+		logger.Log.Info("failed to build redirect URL", zap.Error(err))
+		redirectURL = destination.URL
+	}
+
+	metrics.RedirectServed(metrics.TransportGRPC)
+	return &GetOriginalURLResponse{
+		OriginalUrl:  redirectURL,
+		Variant:      destination.Variant,
+		RedirectCode: int32(services.RedirectCode(record, s.config.RedirectCode)),
+		Interstitial: record.Interstitial,
+	}, nil
+}
+
+// GetURLPreview
+func (s URLsServer) GetURLPreview(ctx context.Context, in *GetURLPreviewRequest) (*GetURLPreviewResponse, error) {
+	record, err := s.findRecord(ctx, in.Domain, in.ShortUrl)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "URL \"%s\" not found", in.ShortUrl)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	target, err := services.LinkTarget(record, time.Now())
+	if err != nil {
+		return nil, s.linkStatusError(err)
+	}
+
+	return &GetURLPreviewResponse{
+		OriginalUrl:  target,
+		ShortUrl:     s.config.ShortURL(record.Domain, record.ShortenedPath),
+		Title:        record.Title,
+		CreatedAt:    timeToPb(&record.CreatedAt),
+		Interstitial: record.Interstitial,
+		Description:  record.Description,
+	}, nil
+}
+
+// Status of original URL which is already shortened. Short URL of existing
+// record is in details, as in versioned HTTP API
+func (s URLsServer) alreadyExistsError(existing models.Record) error {
+	st, err := status.New(codes.AlreadyExists, "original URL is already shortened").
+		WithDetails(&errdetails.ResourceInfo{
+			ResourceType: "short_url",
+			ResourceName: s.config.ShortURL(existing.Domain, existing.ShortenedPath),
+		})
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return st.Err()
+}
+
+// Status of link which can not be followed. Reason is in error info details,
+// gateway responds with the same HTTP status as HTTP API by it
+func (s URLsServer) linkStatusError(err error) error {
+	var st *status.Status
+	var reason string
+	switch {
+	case errors.Is(err, services.ErrLinkDeleted):
+		st, reason = status.New(codes.NotFound, "deleted"), reasonLinkDeleted
+	case errors.Is(err, services.ErrLinkNotActive):
+		st, reason = status.New(codes.FailedPrecondition, s.config.NotActiveMessage), reasonLinkNotActive
+	case errors.Is(err, services.ErrLinkEnded):
+		st, reason = status.New(codes.NotFound, s.config.EndedMessage), reasonLinkEnded
+	case errors.Is(err, storage.ErrClicksExhausted):
+		// Link exists but can not be followed anymore, as not yet active link
+		st, reason = status.New(codes.FailedPrecondition, "clicks exhausted"), reasonClicksExhausted
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	st, err = st.WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: errorDomain})
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return st.Err()
+}
+
+// GetQRCode
+func (s URLsServer) GetQRCode(ctx context.Context, in *GetQRCodeRequest) (*GetQRCodeResponse, error) {
+	record, err := s.findRecord(ctx, in.Domain, in.ShortUrl)
+	if err == nil && record.IsDeleted {
+		err = storage.ErrNotFound
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "URL \"%s\" not found", in.ShortUrl)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	opts := services.DefaultQROptions()
+	if in.Format != "" {
+		opts.Format = in.Format
+	}
+	if in.Size != 0 {
+		opts.Size = int(in.Size)
+	}
+	if in.Level != "" {
+		opts.Level = in.Level
+	}
+	if in.Margin != 0 || in.NoMargin {
+		opts.Margin = int(in.Margin)
+	}
+	img, err := services.RenderQR(s.config.ShortURL(record.Domain, record.ShortenedPath), opts)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQROptions) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	return &GetOriginalURLResponse{OriginalUrl: record.OriginalURL}, nil
+	return &GetQRCodeResponse{Image: img.Data, ContentType: img.ContentType}, nil
 }
 
 // BatchCreateURL
@@ -94,10 +299,18 @@ func (s URLsServer) BatchCreateURL(ctx context.Context, in *BatchCreateURLReques
 
 	records := make([]models.Record, len(in.Items))
 	for i, item := range in.Items {
-		records[i] = models.Record{OriginalURL: item.OriginalUrl, CorrelationID: item.CorrelationId}
+		record, err := s.batchItemToRecord(item)
+		if err != nil {
+			return nil, err
+		}
+		records[i] = record
 	}
-	savedRecords, err := s.shortener.BatchShortify(records, user)
+	savedRecords, err := s.shortener.BatchShortify(ctx, records, user)
 	if err != nil {
+		var notUniqErr *storage.ErrNotUnique
+		if errors.As(err, &notUniqErr) {
+			return nil, s.alreadyExistsError(notUniqErr.Record)
+		}
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
@@ -105,39 +318,245 @@ func (s URLsServer) BatchCreateURL(ctx context.Context, in *BatchCreateURLReques
 	for i, record := range savedRecords {
 		responseItems[i] = &BatchCreateURLResponse_Item{
 			CorrelationId: record.CorrelationID,
-			ShortUrl:      s.config.BaseURL + "/" + record.ShortenedPath,
+			ShortUrl:      s.config.ShortURL(record.Domain, record.ShortenedPath),
 		}
 	}
 
 	return &BatchCreateURLResponse{Items: responseItems}, nil
 }
 
+// StreamCreateURLs. User must be authenticated. Items are created one by one
+// and result of every item is sent right away, so failed items do not stop
+// the stream and results are not kept in memory
+func (s URLsServer) StreamCreateURLs(stream URLService_StreamCreateURLsServer) error {
+	userID := userIDFromContext(stream.Context())
+	for index := int32(0); ; index++ {
+		in, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		result := &StreamCreateURLsResponse{Index: index, CorrelationId: in.GetItem().GetCorrelationId()}
+		record, err := s.batchItemToRecord(in.GetItem())
+		if err == nil {
+			record, err = s.shortener.Shortify(stream.Context(), record, models.User{ID: userID})
+		}
+		if err != nil {
+			result.Error = err.Error()
+			if st, ok := status.FromError(err); ok {
+				result.Error = st.Message()
+			}
+		} else {
+			result.ShortUrl = s.config.ShortURL(record.Domain, record.ShortenedPath)
+		}
+		if err = stream.Send(result); err != nil {
+			return err
+		}
+	}
+}
+
 // GetUserURLs. User must be authenticated
 func (s URLsServer) GetUserURLs(ctx context.Context, in *GetUserURLsRequest) (*GetUserURLsResponse, error) {
-	md, _ := metadata.FromIncomingContext(ctx)
-	userID, _ := strconv.Atoi(md.Get("user_id")[0])
-	records, err := s.store.FindByUser(ctx, models.User{ID: userID})
+	userID := userIDFromContext(ctx)
+	filter := models.RecordFilter{
+		Tags:        in.Tags,
+		Folder:      in.Folder,
+		Query:       in.Query,
+		CreatedFrom: timeFromPb(in.CreatedFrom),
+		CreatedTo:   timeFromPb(in.CreatedTo),
+	}
+	var records []models.Record
+	var next *models.Cursor
+	var page models.Page
+	var err error
+	if in.Limit != 0 || in.Cursor != "" || in.Sort != "" || in.Order != "" {
+		page, err = services.NewPage(int(in.Limit), in.Cursor, in.Sort, in.Order)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		records, next, err = s.store.FindByUserPage(ctx, models.User{ID: userID}, filter, page)
+	} else {
+		records, err = s.store.FindByUser(ctx, models.User{ID: userID}, filter)
+	}
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	responseItems := make([]*GetUserURLsResponse_Item, len(records))
 	for i, record := range records {
-		responseItems[i] = &GetUserURLsResponse_Item{
-			OriginalUrl: record.OriginalURL,
-			ShortUrl:    s.config.BaseURL + "/" + record.ShortenedPath,
+		responseItems[i] = s.userURLToPb(record)
+	}
+
+	return &GetUserURLsResponse{Items: responseItems, NextCursor: services.EncodeCursor(page, next)}, nil
+}
+
+// StreamUserURLs. User must be authenticated
+func (s URLsServer) StreamUserURLs(in *StreamUserURLsRequest, stream URLService_StreamUserURLsServer) error {
+	userID := userIDFromContext(stream.Context())
+	filter := models.RecordFilter{
+		Tags:        in.Tags,
+		Folder:      in.Folder,
+		Query:       in.Query,
+		CreatedFrom: timeFromPb(in.CreatedFrom),
+		CreatedTo:   timeFromPb(in.CreatedTo),
+	}
+	page, err := services.NewPage(streamPageSize, "", in.Sort, in.Order)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	for {
+		records, next, err := s.store.FindByUserPage(stream.Context(), models.User{ID: userID}, filter, page)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
 		}
+		for _, record := range records {
+			if err := stream.Send(s.userURLToPb(record)); err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		page.After = next
 	}
+}
 
-	return &GetUserURLsResponse{Items: responseItems}, nil
+// ExportUserURLs. User must be authenticated
+func (s URLsServer) ExportUserURLs(in *ExportUserURLsRequest, stream URLService_ExportUserURLsServer) error {
+	userID := userIDFromContext(stream.Context())
+	err := s.urlExporter.Export(stream.Context(), models.User{ID: userID}, in.IncludeDeleted, func(u services.ExportedURL) error {
+		return stream.Send(&ExportUserURLsResponse{
+			ShortUrl:    u.ShortURL,
+			OriginalUrl: u.OriginalURL,
+			Alias:       u.Alias,
+			Domain:      u.Domain,
+			Title:       u.Title,
+			Description: u.Description,
+			Tags:        u.Tags,
+			Folder:      u.Folder,
+			Clicks:      int64(u.Clicks),
+			IsDeleted:   u.IsDeleted,
+			CreatedAt:   timeToPb(&u.CreatedAt),
+			UpdatedAt:   timeToPb(&u.UpdatedAt),
+			ExpiresAt:   timeToPb(u.ExpiresAt),
+		})
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+// UpdateUserURL. User must be authenticated
+func (s URLsServer) UpdateUserURL(ctx context.Context, in *UpdateUserURLRequest) (*UpdateUserURLResponse, error) {
+	userID := userIDFromContext(ctx)
+	patch := services.RecordPatch{
+		ActiveFrom:       timeFromPb(in.ActiveFrom),
+		ActiveUntil:      timeFromPb(in.ActiveUntil),
+		ClearActiveFrom:  in.ClearActiveFrom,
+		ClearActiveUntil: in.ClearActiveUntil,
+		ClearFallbackURL: in.ClearFallbackUrl,
+	}
+	if in.FallbackUrl != "" {
+		patch.FallbackURL = &in.FallbackUrl
+	}
+	if in.ReplaceRules {
+		rules := rulesFromPb(in.Rules)
+		patch.Rules = &rules
+	}
+	if in.ReplaceVariants {
+		variants := variantsFromPb(in.Variants)
+		patch.Variants = &variants
+	}
+	if in.ReplaceUtmParams {
+		utmParams := utmParamsFromPb(in.UtmParams)
+		patch.UTMParams = &utmParams
+	}
+	if in.QueryMode != "" {
+		patch.QueryMode = &in.QueryMode
+	}
+	if in.RedirectCode != 0 {
+		redirectCode := int(in.RedirectCode)
+		patch.RedirectCode = &redirectCode
+	}
+	if in.Title != "" {
+		patch.Title = &in.Title
+	}
+	if in.ReplaceInterstitial {
+		patch.Interstitial = &in.Interstitial
+	}
+	if in.ReplaceTags {
+		tags := in.Tags
+		patch.Tags = &tags
+	}
+	if in.Folder != "" {
+		patch.Folder = &in.Folder
+	}
+	if in.Description != "" {
+		patch.Description = &in.Description
+	}
+
+	domain, ok := s.config.NormalizeDomain(in.Domain)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "URL \"%s\" not found", in.ShortUrl)
+	}
+	record, err := s.urlUpdater.Update(ctx, domain, in.ShortUrl, patch, models.User{ID: userID})
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, status.Errorf(codes.NotFound, "URL \"%s\" not found", in.ShortUrl)
+		case errors.Is(err, services.ErrInvalidActivityWindow),
+			errors.Is(err, services.ErrInvalidRoutingRule),
+			errors.Is(err, services.ErrInvalidVariant),
+			errors.Is(err, services.ErrInvalidQueryParams),
+			errors.Is(err, services.ErrInvalidRedirectCode),
+			errors.Is(err, services.ErrInvalidTitle),
+			errors.Is(err, services.ErrInvalidDescription),
+			errors.Is(err, services.ErrInvalidTags),
+			errors.Is(err, services.ErrInvalidFolder):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &UpdateUserURLResponse{
+		OriginalUrl:  record.OriginalURL,
+		ShortUrl:     s.config.ShortURL(record.Domain, record.ShortenedPath),
+		ActiveFrom:   timeToPb(record.ActiveFrom),
+		ActiveUntil:  timeToPb(record.ActiveUntil),
+		FallbackUrl:  record.FallbackURL,
+		Rules:        rulesToPb(record.Rules),
+		Variants:     variantsToPb(record.Variants),
+		UtmParams:    record.UTMParams,
+		QueryMode:    record.QueryMode,
+		RedirectCode: int32(record.RedirectCode),
+		Title:        record.Title,
+		Interstitial: record.Interstitial,
+		Tags:         record.Tags,
+		Folder:       record.Folder,
+		Description:  record.Description,
+		UpdatedAt:    timeToPb(&record.UpdatedAt),
+	}, nil
 }
 
 // DeleteUserURLs. User must be authenticated
 func (s URLsServer) DeleteUserURLs(ctx context.Context, in *DeleteUserURLsRequest) (*DeleteUserURLsResponse, error) {
-	md, _ := metadata.FromIncomingContext(ctx)
-	userID, _ := strconv.Atoi(md.Get("user_id")[0])
+	userID := userIDFromContext(ctx)
+	domain, ok := s.config.NormalizeDomain(in.Domain)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown domain \"%s\"", in.Domain)
+	}
 	for _, shortPath := range in.ShortUrls {
 		s.urlDeleter.Enqueue(models.Record{
+			Domain:        domain,
 			ShortenedPath: shortPath,
 			UserID:        userID,
 		})
@@ -176,16 +595,166 @@ func (s URLsServer) PingDB(ctx context.Context, in *PingDBRequest) (*PingDBRespo
 	return &PingDBResponse{}, nil
 }
 
+// Find record on domain, records on unknown domains are not found
+func (s URLsServer) batchItemToRecord(item *BatchCreateURLRequest_Item) (models.Record, error) {
+	if item == nil {
+		return models.Record{}, status.Error(codes.InvalidArgument, "missing item")
+	}
+	domain, ok := s.config.NormalizeDomain(item.Domain)
+	if !ok {
+		return models.Record{}, status.Errorf(codes.InvalidArgument, "unknown domain \"%s\"", item.Domain)
+	}
+
+	return models.Record{
+		OriginalURL:   item.OriginalUrl,
+		Domain:        domain,
+		CorrelationID: item.CorrelationId,
+		MaxClicks:     int(item.MaxClicks),
+		ActiveFrom:    timeFromPb(item.ActiveFrom),
+		ActiveUntil:   timeFromPb(item.ActiveUntil),
+		FallbackURL:   item.FallbackUrl,
+		Rules:         rulesFromPb(item.Rules),
+		Variants:      variantsFromPb(item.Variants),
+		UTMParams:     utmParamsFromPb(item.UtmParams),
+		QueryMode:     item.QueryMode,
+		RedirectCode:  int(item.RedirectCode),
+		Title:         item.Title,
+		Interstitial:  item.Interstitial,
+		Tags:          item.Tags,
+		Folder:        item.Folder,
+		Description:   item.Description,
+	}, nil
+}
+
+func (s URLsServer) userURLToPb(record models.Record) *GetUserURLsResponse_Item {
+	return &GetUserURLsResponse_Item{
+		OriginalUrl: record.OriginalURL,
+		ShortUrl:    s.config.ShortURL(record.Domain, record.ShortenedPath),
+		Rules:       rulesToPb(record.Rules),
+		Variants:    variantsToPb(record.Variants),
+		UtmParams:   record.UTMParams,
+		QueryMode:   record.QueryMode,
+		Tags:        record.Tags,
+		Folder:      record.Folder,
+		Title:       record.Title,
+		Description: record.Description,
+		CreatedAt:   timeToPb(&record.CreatedAt),
+		UpdatedAt:   timeToPb(&record.UpdatedAt),
+	}
+}
+
+func (s URLsServer) findRecord(ctx context.Context, domain, shortenedPath string) (models.Record, error) {
+	domain, ok := s.config.NormalizeDomain(domain)
+	if !ok {
+		return models.Record{}, storage.ErrNotFound
+	}
+
+	return s.store.FindByShortenedPath(ctx, domain, shortenedPath)
+}
+
 func getJWT(ctx context.Context) string {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		return ""
 	}
 
-	values := md.Get("jwt")
+	return firstMetadataValue(md, "jwt")
+}
+
+func firstMetadataValue(md metadata.MD, key string) string {
+	values := md.Get(key)
 	if len(values) == 0 {
 		return ""
 	}
 
 	return values[0]
 }
+
+func timeFromPb(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+
+	return &t
+}
+
+func timeToPb(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+
+	return timestamppb.New(*t)
+}
+
+func rulesFromPb(pbRules []*RoutingRule) []models.RoutingRule {
+	if len(pbRules) == 0 {
+		return nil
+	}
+	rules := make([]models.RoutingRule, len(pbRules))
+	for i, rule := range pbRules {
+		rules[i] = models.RoutingRule{
+			Field:       rule.Field,
+			Pattern:     rule.Pattern,
+			Destination: rule.Destination,
+		}
+	}
+
+	return rules
+}
+
+func rulesToPb(rules []models.RoutingRule) []*RoutingRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	pbRules := make([]*RoutingRule, len(rules))
+	for i, rule := range rules {
+		pbRules[i] = &RoutingRule{
+			Field:       rule.Field,
+			Pattern:     rule.Pattern,
+			Destination: rule.Destination,
+		}
+	}
+
+	return pbRules
+}
+
+func variantsFromPb(pbVariants []*Variant) []models.Variant {
+	if len(pbVariants) == 0 {
+		return nil
+	}
+	variants := make([]models.Variant, len(pbVariants))
+	for i, variant := range pbVariants {
+		variants[i] = models.Variant{
+			Name:   variant.Name,
+			URL:    variant.Url,
+			Weight: int(variant.Weight),
+		}
+	}
+
+	return variants
+}
+
+func variantsToPb(variants []models.Variant) []*Variant {
+	if len(variants) == 0 {
+		return nil
+	}
+	pbVariants := make([]*Variant, len(variants))
+	for i, variant := range variants {
+		pbVariants[i] = &Variant{
+			Name:   variant.Name,
+			Url:    variant.URL,
+			Weight: int32(variant.Weight),
+		}
+	}
+
+	return pbVariants
+}
+
+func utmParamsFromPb(pbParams map[string]string) map[string]string {
+	if len(pbParams) == 0 {
+		return nil
+	}
+
+	return pbParams
+}
@@ -3,8 +3,12 @@ package grpc_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net"
+	"sync"
+	"time"
 
 	"testing"
 
@@ -17,11 +21,16 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/ilya-burinskiy/urlshort/internal/app/configs"
 	pb "github.com/ilya-burinskiy/urlshort/internal/app/handlers/grpc"
@@ -35,12 +44,12 @@ var defaultConfig = configs.Config{
 
 type urlShortenerMock struct{ mock.Mock }
 
-func (m *urlShortenerMock) Shortify(origURL string, user models.User) (models.Record, error) {
-	args := m.Called(origURL, user)
+func (m *urlShortenerMock) Shortify(ctx context.Context, record models.Record, user models.User) (models.Record, error) {
+	args := m.Called(record, user)
 	return args.Get(0).(models.Record), args.Error(1)
 }
 
-func (m *urlShortenerMock) BatchShortify(records []models.Record, user models.User) ([]models.Record, error) {
+func (m *urlShortenerMock) BatchShortify(ctx context.Context, records []models.Record, user models.User) ([]models.Record, error) {
 	args := m.Called(records, user)
 	return args.Get(0).([]models.Record), args.Error(1)
 }
@@ -52,7 +61,7 @@ func (m *userAuthenticatorMock) AuthOrRegister(ctx context.Context, jwtStr strin
 	return args.Get(0).(models.User), args.String(1), args.Error(2)
 }
 
-func (m *userAuthenticatorMock) Auth(jwtStr string) (models.User, error) {
+func (m *userAuthenticatorMock) Auth(ctx context.Context, jwtStr string) (models.User, error) {
 	args := m.Called(jwtStr)
 	return args.Get(0).(models.User), args.Error(1)
 }
@@ -70,12 +79,19 @@ func TestCreateURL(t *testing.T) {
 		user, nil,
 	)
 	urlDeleter := services.NewDeferredDeleter(store)
-	srvCloser := startServer(defaultConfig, store, userAuthenticator, urlCreateService, urlDeleter)
+	srvCloser := startServer(defaultConfig, store, userAuthenticator, urlCreateService, services.NewURLUpdater(store), urlDeleter)
 	defer srvCloser()
 
 	client, closer := getClient()
 	defer closer()
 
+	alreadyExists, err := status.New(codes.AlreadyExists, "original URL is already shortened").
+		WithDetails(&errdetails.ResourceInfo{
+			ResourceType: "short_url",
+			ResourceName: defaultConfig.BaseURL + "/" + "123",
+		})
+	require.NoError(t, err)
+
 	type want struct {
 		out *pb.CreateURLResponse
 		err error
@@ -112,7 +128,7 @@ func TestCreateURL(t *testing.T) {
 			},
 			want: want{
 				out: &pb.CreateURLResponse{ShortUrl: defaultConfig.BaseURL + "/" + "123"},
-				err: status.Error(codes.AlreadyExists, ""),
+				err: alreadyExists.Err(),
 			},
 		},
 	}
@@ -132,7 +148,7 @@ func TestCreateURL(t *testing.T) {
 				actualErrStatus, ok := status.FromError(err)
 				require.True(t, ok)
 
-				assert.Equal(t, expectedErrStatus, actualErrStatus)
+				assert.True(t, proto.Equal(expectedErrStatus.Proto(), actualErrStatus.Proto()), actualErrStatus.Proto())
 				assert.Equal(t, tc.want.err.Error(), err.Error())
 			}
 			if out != nil {
@@ -145,21 +161,38 @@ func TestCreateURL(t *testing.T) {
 func TestGetOriginalURL(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	store := mocks.NewMockStorage(ctrl)
-	store.EXPECT().FindByShortenedPath(gomock.Any(), gomock.Any()).Return(models.Record{OriginalURL: "http://example.com"}, nil)
-	store.EXPECT().FindByShortenedPath(gomock.Any(), gomock.Any()).Return(models.Record{}, storage.ErrNotFound)
-	store.EXPECT().FindByShortenedPath(gomock.Any(), gomock.Any()).Return(models.Record{IsDeleted: true}, nil)
+	store.EXPECT().SaveClick(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", gomock.Any()).Return(models.Record{OriginalURL: "http://example.com"}, nil)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", gomock.Any()).Return(models.Record{}, storage.ErrNotFound)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", gomock.Any()).Return(models.Record{IsDeleted: true}, nil)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", gomock.Any()).
+		Return(models.Record{OriginalURL: "http://example.com", MaxClicks: 1, RemainingClicks: 1}, nil)
+	store.EXPECT().ConsumeClick(gomock.Any(), "", "123").Return(0, storage.ErrClicksExhausted)
+	activeFrom := time.Now().Add(time.Hour)
+	activeUntil := time.Now().Add(-time.Hour)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", gomock.Any()).
+		Return(models.Record{OriginalURL: "http://example.com", ActiveFrom: &activeFrom}, nil)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", gomock.Any()).
+		Return(models.Record{OriginalURL: "http://example.com", ActiveUntil: &activeUntil}, nil)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", gomock.Any()).Return(models.Record{}, errors.New("error"))
 	urlCreateService := new(urlShortenerMock)
 	userAuthenticator := new(userAuthenticatorMock)
 	userAuthenticator.On("AuthOrRegister", mock.Anything, mock.Anything).Return(
 		models.User{ID: 1}, "123", nil,
 	)
 	urlDeleter := services.NewDeferredDeleter(store)
-	srvCloser := startServer(defaultConfig, store, userAuthenticator, urlCreateService, urlDeleter)
+	srvCloser := startServer(defaultConfig, store, userAuthenticator, urlCreateService, services.NewURLUpdater(store), urlDeleter)
 	defer srvCloser()
 
 	client, closer := getClient()
 	defer closer()
 
+	linkStatus := func(code codes.Code, msg, reason string) error {
+		st, err := status.New(code, msg).WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: "urlshort"})
+		require.NoError(t, err)
+		return st.Err()
+	}
+
 	type want struct {
 		out *pb.GetOriginalURLResponse
 		err error
@@ -187,7 +220,35 @@ func TestGetOriginalURL(t *testing.T) {
 			name: "responds with not found status if URL is deleted",
 			in:   &pb.GetOriginalURLRequest{ShortUrl: "123"},
 			want: want{
-				err: status.Error(codes.NotFound, "deleted"),
+				err: linkStatus(codes.NotFound, "deleted", "LINK_DELETED"),
+			},
+		},
+		{
+			name: "responds with failed precondition status if clicks exhausted",
+			in:   &pb.GetOriginalURLRequest{ShortUrl: "123"},
+			want: want{
+				err: linkStatus(codes.FailedPrecondition, "clicks exhausted", "CLICKS_EXHAUSTED"),
+			},
+		},
+		{
+			name: "responds with failed precondition status if URL is not active yet",
+			in:   &pb.GetOriginalURLRequest{ShortUrl: "123"},
+			want: want{
+				err: linkStatus(codes.FailedPrecondition, defaultConfig.NotActiveMessage, "LINK_NOT_ACTIVE"),
+			},
+		},
+		{
+			name: "responds with not found status if URL activity window has ended",
+			in:   &pb.GetOriginalURLRequest{ShortUrl: "123"},
+			want: want{
+				err: linkStatus(codes.NotFound, defaultConfig.EndedMessage, "LINK_ENDED"),
+			},
+		},
+		{
+			name: "responds with internal status if URL can not be found",
+			in:   &pb.GetOriginalURLRequest{ShortUrl: "123"},
+			want: want{
+				err: status.Error(codes.Internal, "error"),
 			},
 		},
 	}
@@ -202,7 +263,7 @@ func TestGetOriginalURL(t *testing.T) {
 				actualErrStatus, ok := status.FromError(err)
 				require.True(t, ok)
 
-				assert.Equal(t, expectedErrStatus, actualErrStatus)
+				assert.True(t, proto.Equal(expectedErrStatus.Proto(), actualErrStatus.Proto()), actualErrStatus.Proto())
 				assert.Equal(t, tc.want.err.Error(), err.Error())
 			}
 			if out != nil {
@@ -212,6 +273,92 @@ func TestGetOriginalURL(t *testing.T) {
 	}
 }
 
+func TestGetURLPreview(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mocks.NewMockStorage(ctrl)
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", "123").Return(models.Record{
+		OriginalURL:   "http://example.com",
+		ShortenedPath: "123",
+		Title:         "Example",
+		Interstitial:  true,
+		CreatedAt:     createdAt,
+	}, nil)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", "321").Return(models.Record{}, storage.ErrNotFound)
+	activeUntil := time.Now().Add(-time.Hour)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", "ended").Return(models.Record{
+		OriginalURL:   "http://example.com",
+		ShortenedPath: "ended",
+		ActiveUntil:   &activeUntil,
+		FallbackURL:   "http://fallback.example.com",
+	}, nil)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", "exhausted").Return(models.Record{
+		OriginalURL:   "http://example.com",
+		ShortenedPath: "exhausted",
+		MaxClicks:     1,
+	}, nil)
+	urlCreateService := new(urlShortenerMock)
+	userAuthenticator := new(userAuthenticatorMock)
+	urlDeleter := services.NewDeferredDeleter(store)
+	srvCloser := startServer(defaultConfig, store, userAuthenticator, urlCreateService, services.NewURLUpdater(store), urlDeleter)
+	defer srvCloser()
+
+	client, closer := getClient()
+	defer closer()
+
+	out, err := client.GetURLPreview(context.Background(), &pb.GetURLPreviewRequest{ShortUrl: "123"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com", out.OriginalUrl)
+	assert.Equal(t, defaultConfig.BaseURL+"/123", out.ShortUrl)
+	assert.Equal(t, "Example", out.Title)
+	assert.True(t, out.Interstitial)
+	assert.Equal(t, createdAt, out.CreatedAt.AsTime())
+
+	_, err = client.GetURLPreview(context.Background(), &pb.GetURLPreviewRequest{ShortUrl: "321"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	out, err = client.GetURLPreview(context.Background(), &pb.GetURLPreviewRequest{ShortUrl: "ended"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://fallback.example.com", out.OriginalUrl)
+
+	_, err = client.GetURLPreview(context.Background(), &pb.GetURLPreviewRequest{ShortUrl: "exhausted"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestGetQRCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mocks.NewMockStorage(ctrl)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", "123").AnyTimes().Return(
+		models.Record{OriginalURL: "http://example.com", ShortenedPath: "123"}, nil,
+	)
+	urlCreateService := new(urlShortenerMock)
+	userAuthenticator := new(userAuthenticatorMock)
+	urlDeleter := services.NewDeferredDeleter(store)
+	srvCloser := startServer(defaultConfig, store, userAuthenticator, urlCreateService, services.NewURLUpdater(store), urlDeleter)
+	defer srvCloser()
+
+	client, closer := getClient()
+	defer closer()
+
+	opts := services.DefaultQROptions()
+	opts.Format = services.QRFormatSVG
+	opts.Margin = 0
+	img, err := services.RenderQR(defaultConfig.BaseURL+"/123", opts)
+	require.NoError(t, err)
+
+	out, err := client.GetQRCode(context.Background(), &pb.GetQRCodeRequest{
+		ShortUrl: "123",
+		Format:   services.QRFormatSVG,
+		NoMargin: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, img.Data, out.Image)
+	assert.Equal(t, "image/svg+xml", out.ContentType)
+
+	_, err = client.GetQRCode(context.Background(), &pb.GetQRCodeRequest{ShortUrl: "123", Level: "X"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
 func TestBatchCreateURL(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	store := mocks.NewMockStorage(ctrl)
@@ -222,12 +369,19 @@ func TestBatchCreateURL(t *testing.T) {
 		models.User{ID: 1}, "123", nil,
 	)
 	urlDeleter := services.NewDeferredDeleter(store)
-	srvCloser := startServer(defaultConfig, store, userAuthenticator, urlCreateService, urlDeleter)
+	srvCloser := startServer(defaultConfig, store, userAuthenticator, urlCreateService, services.NewURLUpdater(store), urlDeleter)
 	defer srvCloser()
 
 	client, closer := getClient()
 	defer closer()
 
+	alreadyExists, err := status.New(codes.AlreadyExists, "original URL is already shortened").
+		WithDetails(&errdetails.ResourceInfo{
+			ResourceType: "short_url",
+			ResourceName: defaultConfig.BaseURL + "/" + "123",
+		})
+	require.NoError(t, err)
+
 	type want struct {
 		out *pb.BatchCreateURLResponse
 		err error
@@ -278,6 +432,16 @@ func TestBatchCreateURL(t *testing.T) {
 				err: status.Error(codes.InvalidArgument, "error"),
 			},
 		},
+		{
+			name: "responds with already exists status",
+			in: &pb.BatchCreateURLRequest{
+				Items: []*pb.BatchCreateURLRequest_Item{{OriginalUrl: "http://example0.com", CorrelationId: "1"}},
+			},
+			createRes: batchCreateURLResult{
+				err: storage.NewErrNotUnique(models.Record{OriginalURL: "http://example0.com", ShortenedPath: "123"}),
+			},
+			want: want{err: alreadyExists.Err()},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -295,7 +459,7 @@ func TestBatchCreateURL(t *testing.T) {
 				actualErrStatus, ok := status.FromError(err)
 				require.True(t, ok)
 
-				assert.Equal(t, expectedErrStatus, actualErrStatus)
+				assert.True(t, proto.Equal(expectedErrStatus.Proto(), actualErrStatus.Proto()), actualErrStatus.Proto())
 				assert.Equal(t, tc.want.err.Error(), err.Error())
 			}
 			if out != nil {
@@ -305,6 +469,130 @@ func TestBatchCreateURL(t *testing.T) {
 	}
 }
 
+func TestStreamCreateURLs(t *testing.T) {
+	store := storage.NewMapStorage(nil)
+	urlCreateService := new(urlShortenerMock)
+	user := models.User{ID: 1}
+	urlCreateService.On("Shortify", mock.MatchedBy(func(r models.Record) bool {
+		return r.OriginalURL == "http://example0.com"
+	}), user).Return(models.Record{OriginalURL: "http://example0.com", ShortenedPath: "1"}, nil)
+	urlCreateService.On("Shortify", mock.Anything, user).Return(models.Record{}, errors.New("invalid url"))
+	userAuthenticator := new(userAuthenticatorMock)
+	userAuthenticator.On("Auth", mock.Anything).Return(user, nil)
+	urlDeleter := services.NewDeferredDeleter(store)
+	srvCloser := startServer(defaultConfig, store, userAuthenticator, urlCreateService, services.NewURLUpdater(store), urlDeleter)
+	defer srvCloser()
+
+	client, closer := getClient()
+	defer closer()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "jwt", "valid")
+	stream, err := client.StreamCreateURLs(ctx)
+	require.NoError(t, err)
+	items := []*pb.BatchCreateURLRequest_Item{
+		{OriginalUrl: "http://example0.com", CorrelationId: "a"},
+		{OriginalUrl: "example1", CorrelationId: "b"},
+		{OriginalUrl: "http://example2.com", Domain: "unknown.com"},
+	}
+	// Result of every item is received before the next item is sent
+	var results []*pb.StreamCreateURLsResponse
+	for _, item := range items {
+		require.NoError(t, stream.Send(&pb.StreamCreateURLsRequest{Item: item}))
+		result, err := stream.Recv()
+		require.NoError(t, err)
+		results = append(results, result)
+	}
+	require.NoError(t, stream.CloseSend())
+	_, err = stream.Recv()
+	require.ErrorIs(t, err, io.EOF)
+
+	require.Len(t, results, 3)
+	assert.True(t, proto.Equal(&pb.StreamCreateURLsResponse{
+		Index:         0,
+		CorrelationId: "a",
+		ShortUrl:      defaultConfig.BaseURL + "/1",
+	}, results[0]))
+	assert.True(t, proto.Equal(&pb.StreamCreateURLsResponse{
+		Index:         1,
+		CorrelationId: "b",
+		Error:         "invalid url",
+	}, results[1]))
+	assert.True(t, proto.Equal(&pb.StreamCreateURLsResponse{
+		Index: 2,
+		Error: `unknown domain "unknown.com"`,
+	}, results[2]))
+}
+
+func TestStreamUserURLs(t *testing.T) {
+	store := storage.NewMapStorage(nil)
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	records := make([]models.Record, 250)
+	for i := range records {
+		records[i] = models.Record{
+			OriginalURL:   fmt.Sprintf("http://example%d.com", i),
+			ShortenedPath: fmt.Sprintf("%03d", i),
+			UserID:        1,
+			CreatedAt:     createdAt.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	records = append(records, models.Record{OriginalURL: "http://other.com", ShortenedPath: "other", UserID: 2})
+	require.NoError(t, store.BatchSave(context.Background(), records))
+
+	userAuthenticator := new(userAuthenticatorMock)
+	userAuthenticator.On("Auth", "valid").Return(models.User{ID: 1}, nil)
+	userAuthenticator.On("Auth", mock.Anything).Return(models.User{}, services.ErrInvalidJWT)
+	urlDeleter := services.NewDeferredDeleter(store)
+	srvCloser := startServer(
+		defaultConfig, store, userAuthenticator, new(urlShortenerMock), services.NewURLUpdater(store), urlDeleter,
+	)
+	defer srvCloser()
+
+	client, closer := getClient()
+	defer closer()
+
+	recv := func(t *testing.T, jwt string, in *pb.StreamUserURLsRequest) ([]*pb.GetUserURLsResponse_Item, error) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "jwt", jwt)
+		stream, err := client.StreamUserURLs(ctx, in)
+		require.NoError(t, err)
+		var out []*pb.GetUserURLsResponse_Item
+		for {
+			item, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return out, nil
+			}
+			if err != nil {
+				return out, err
+			}
+			out = append(out, item)
+		}
+	}
+
+	t.Run("streams every user URL", func(t *testing.T) {
+		out, err := recv(t, "valid", &pb.StreamUserURLsRequest{Order: "desc"})
+		require.NoError(t, err)
+		require.Len(t, out, 250)
+		assert.Equal(t, defaultConfig.BaseURL+"/249", out[0].ShortUrl)
+		assert.Equal(t, defaultConfig.BaseURL+"/000", out[249].ShortUrl)
+	})
+
+	t.Run("streams filtered user URLs", func(t *testing.T) {
+		out, err := recv(t, "valid", &pb.StreamUserURLsRequest{Query: "example12"})
+		require.NoError(t, err)
+		require.Len(t, out, 11)
+		assert.Equal(t, "http://example12.com", out[0].OriginalUrl)
+	})
+
+	t.Run("responds with invalid argument status", func(t *testing.T) {
+		_, err := recv(t, "valid", &pb.StreamUserURLsRequest{Sort: "title"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("responds with unauthenticated status", func(t *testing.T) {
+		_, err := recv(t, "invalid", &pb.StreamUserURLsRequest{})
+		assert.Equal(t, status.Error(codes.Unauthenticated, "invalid jwt").Error(), err.Error())
+	})
+}
+
 func TestGetUserURLS(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	store := mocks.NewMockStorage(ctrl)
@@ -318,19 +606,26 @@ func TestGetUserURLS(t *testing.T) {
 	userAuthenticator.On("Auth", mock.Anything).Return(
 		user, nil,
 	)
-	srvCloser := startServer(defaultConfig, store, userAuthenticator, urlCreateService, urlDeleter)
+	srvCloser := startServer(defaultConfig, store, userAuthenticator, urlCreateService, services.NewURLUpdater(store), urlDeleter)
 	defer srvCloser()
 
 	userID := 1
 	client, closer := getClient(authInterceptor(userID))
 	defer closer()
 
-	store.EXPECT().FindByUser(gomock.Any(), gomock.Any()).
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	store.EXPECT().FindByUser(gomock.Any(), gomock.Any(), gomock.Any()).
 		Return([]models.Record{
-			{OriginalURL: "http://example0.com", ShortenedPath: "1"},
-			{OriginalURL: "http://example1.com", ShortenedPath: "2"},
+			{OriginalURL: "http://example0.com", ShortenedPath: "1", CreatedAt: createdAt, UpdatedAt: createdAt},
+			{
+				OriginalURL:   "http://example1.com",
+				ShortenedPath: "2",
+				Description:   "Second example",
+				CreatedAt:     createdAt,
+				UpdatedAt:     createdAt,
+			},
 		}, nil)
-	store.EXPECT().FindByUser(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
+	store.EXPECT().FindByUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
 
 	type want struct {
 		out *pb.GetUserURLsResponse
@@ -347,8 +642,19 @@ func TestGetUserURLS(t *testing.T) {
 			want: want{
 				out: &pb.GetUserURLsResponse{
 					Items: []*pb.GetUserURLsResponse_Item{
-						{OriginalUrl: "http://example0.com", ShortUrl: defaultConfig.BaseURL + "/1"},
-						{OriginalUrl: "http://example1.com", ShortUrl: defaultConfig.BaseURL + "/2"},
+						{
+							OriginalUrl: "http://example0.com",
+							ShortUrl:    defaultConfig.BaseURL + "/1",
+							CreatedAt:   timestamppb.New(createdAt),
+							UpdatedAt:   timestamppb.New(createdAt),
+						},
+						{
+							OriginalUrl: "http://example1.com",
+							ShortUrl:    defaultConfig.BaseURL + "/2",
+							Description: "Second example",
+							CreatedAt:   timestamppb.New(createdAt),
+							UpdatedAt:   timestamppb.New(createdAt),
+						},
 					},
 				},
 			},
@@ -382,6 +688,214 @@ func TestGetUserURLS(t *testing.T) {
 	}
 }
 
+func TestExportUserURLs(t *testing.T) {
+	store := storage.NewMapStorage(nil)
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	require.NoError(t, store.BatchSave(ctx, []models.Record{
+		{OriginalURL: "http://example0.com", ShortenedPath: "1", UserID: 1, CreatedAt: createdAt, Tags: []string{"work"}},
+		{OriginalURL: "http://example1.com", ShortenedPath: "2", UserID: 1, CreatedAt: createdAt.Add(time.Hour)},
+		{OriginalURL: "http://example2.com", ShortenedPath: "3", UserID: 2, CreatedAt: createdAt},
+	}))
+	require.NoError(t, store.SaveClick(ctx, models.Click{ShortenedPath: "1"}))
+	require.NoError(t, store.BatchDelete(ctx, []models.Record{{ShortenedPath: "2", UserID: 1}}))
+
+	userAuthenticator := new(userAuthenticatorMock)
+	userAuthenticator.On("Auth", "valid").Return(models.User{ID: 1}, nil)
+	userAuthenticator.On("Auth", mock.Anything).Return(models.User{}, services.ErrInvalidJWT)
+	urlDeleter := services.NewDeferredDeleter(store)
+	srvCloser := startServer(
+		defaultConfig, store, userAuthenticator, new(urlShortenerMock), services.NewURLUpdater(store), urlDeleter,
+	)
+	defer srvCloser()
+
+	client, closer := getClient()
+	defer closer()
+
+	exported := &pb.ExportUserURLsResponse{
+		ShortUrl:    defaultConfig.BaseURL + "/1",
+		OriginalUrl: "http://example0.com",
+		Alias:       "1",
+		Tags:        []string{"work"},
+		Clicks:      1,
+		CreatedAt:   timestamppb.New(createdAt),
+		UpdatedAt:   timestamppb.New(createdAt),
+	}
+	deleted := &pb.ExportUserURLsResponse{
+		ShortUrl:    defaultConfig.BaseURL + "/2",
+		OriginalUrl: "http://example1.com",
+		Alias:       "2",
+		IsDeleted:   true,
+		CreatedAt:   timestamppb.New(createdAt.Add(time.Hour)),
+		UpdatedAt:   timestamppb.New(createdAt.Add(time.Hour)),
+	}
+	testCases := []struct {
+		name string
+		jwt  string
+		in   *pb.ExportUserURLsRequest
+		want []*pb.ExportUserURLsResponse
+		err  error
+	}{
+		{
+			name: "streams user URLs",
+			jwt:  "valid",
+			in:   &pb.ExportUserURLsRequest{},
+			want: []*pb.ExportUserURLsResponse{exported},
+		},
+		{
+			name: "streams deleted user URLs if requested",
+			jwt:  "valid",
+			in:   &pb.ExportUserURLsRequest{IncludeDeleted: true},
+			want: []*pb.ExportUserURLsResponse{exported, deleted},
+		},
+		{
+			name: "responds with unauthenticated status",
+			jwt:  "invalid",
+			in:   &pb.ExportUserURLsRequest{},
+			err:  status.Error(codes.Unauthenticated, "invalid jwt"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "jwt", tc.jwt)
+			stream, err := client.ExportUserURLs(ctx, tc.in)
+			require.NoError(t, err)
+
+			var out []*pb.ExportUserURLsResponse
+			for {
+				item, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if tc.err != nil {
+					assert.Equal(t, tc.err.Error(), err.Error())
+					return
+				}
+				require.NoError(t, err)
+				out = append(out, item)
+			}
+			require.Equal(t, len(tc.want), len(out))
+			for i := range tc.want {
+				assert.True(t, proto.Equal(tc.want[i], out[i]), "%v != %v", tc.want[i], out[i])
+			}
+		})
+	}
+}
+
+func TestUpdateUserURL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mocks.NewMockStorage(ctrl)
+	urlCreateService := new(urlShortenerMock)
+	userAuthenticator := new(userAuthenticatorMock)
+	user := models.User{ID: 1}
+	userAuthenticator.On("Auth", mock.Anything).Return(user, nil)
+	urlDeleter := services.NewDeferredDeleter(store)
+	srvCloser := startServer(defaultConfig, store, userAuthenticator, urlCreateService, services.NewURLUpdater(store), urlDeleter)
+	defer srvCloser()
+
+	client, closer := getClient(authInterceptor(user.ID))
+	defer closer()
+
+	activeFrom := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	activeUntil := activeFrom.Add(24 * time.Hour)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", "1").AnyTimes().
+		Return(models.Record{OriginalURL: "http://example.com", ShortenedPath: "1", UserID: 1}, nil)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", "2").AnyTimes().
+		Return(models.Record{OriginalURL: "http://example.com", ShortenedPath: "2", UserID: 2}, nil)
+	store.EXPECT().FindByShortenedPath(gomock.Any(), "", "3").AnyTimes().
+		Return(models.Record{
+			OriginalURL:   "http://example.com",
+			ShortenedPath: "3",
+			UserID:        1,
+			ActiveFrom:    &activeFrom,
+			ActiveUntil:   &activeUntil,
+			FallbackURL:   "http://fallback.com",
+		}, nil)
+	store.EXPECT().Update(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+
+	type want struct {
+		out *pb.UpdateUserURLResponse
+		err error
+	}
+	testCases := []struct {
+		name string
+		in   *pb.UpdateUserURLRequest
+		want want
+	}{
+		{
+			name: "responds with ok status",
+			in: &pb.UpdateUserURLRequest{
+				ShortUrl:    "1",
+				ActiveFrom:  timestamppb.New(activeFrom),
+				ActiveUntil: timestamppb.New(activeUntil),
+				FallbackUrl: "http://fallback.com",
+			},
+			want: want{
+				out: &pb.UpdateUserURLResponse{
+					OriginalUrl: "http://example.com",
+					ShortUrl:    defaultConfig.BaseURL + "/1",
+					ActiveFrom:  timestamppb.New(activeFrom),
+					ActiveUntil: timestamppb.New(activeUntil),
+					FallbackUrl: "http://fallback.com",
+				},
+			},
+		},
+		{
+			name: "clears activity window bounds and fallback URL",
+			in: &pb.UpdateUserURLRequest{
+				ShortUrl:         "3",
+				ActiveFrom:       timestamppb.New(activeFrom),
+				ClearActiveFrom:  true,
+				ClearFallbackUrl: true,
+			},
+			want: want{
+				out: &pb.UpdateUserURLResponse{
+					OriginalUrl: "http://example.com",
+					ShortUrl:    defaultConfig.BaseURL + "/3",
+					ActiveUntil: timestamppb.New(activeUntil),
+				},
+			},
+		},
+		{
+			name: "responds with invalid argument status if window is invalid",
+			in: &pb.UpdateUserURLRequest{
+				ShortUrl:    "1",
+				ActiveFrom:  timestamppb.New(activeUntil),
+				ActiveUntil: timestamppb.New(activeFrom),
+			},
+			want: want{
+				err: status.Error(codes.InvalidArgument, services.ErrInvalidActivityWindow.Error()),
+			},
+		},
+		{
+			name: "responds with not found status if user does not own URL",
+			in:   &pb.UpdateUserURLRequest{ShortUrl: "2"},
+			want: want{
+				err: status.Error(codes.NotFound, "URL \"2\" not found"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			out, err := client.UpdateUserURL(ctx, tc.in)
+			if tc.want.err != nil {
+				assert.Equal(t, tc.want.err.Error(), err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want.out.ShortUrl, out.ShortUrl)
+			assert.Equal(t, tc.want.out.FallbackUrl, out.FallbackUrl)
+			assert.Equal(t, tc.want.out.ActiveFrom == nil, out.ActiveFrom == nil)
+			assert.True(t, tc.want.out.ActiveFrom.AsTime().Equal(out.ActiveFrom.AsTime()))
+			assert.Equal(t, tc.want.out.ActiveUntil == nil, out.ActiveUntil == nil)
+			assert.True(t, tc.want.out.ActiveUntil.AsTime().Equal(out.ActiveUntil.AsTime()))
+		})
+	}
+}
+
 func TestDeleteUserURLs(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	store := mocks.NewMockStorage(ctrl)
@@ -395,7 +909,7 @@ func TestDeleteUserURLs(t *testing.T) {
 		user, nil,
 	)
 	urlDeleter := services.NewDeferredDeleter(store)
-	srvCloser := startServer(defaultConfig, store, userAuthenticator, urlCreateService, urlDeleter)
+	srvCloser := startServer(defaultConfig, store, userAuthenticator, urlCreateService, services.NewURLUpdater(store), urlDeleter)
 	defer srvCloser()
 
 	userID := 1
@@ -444,6 +958,7 @@ func startServer(
 	store storage.Storage,
 	userAuthenticator services.UserAuthenticator,
 	urlCreateService services.URLShortener,
+	urlUpdater services.URLUpdater,
 	urlDeleter services.DeferredDeleter) func() {
 
 	listen, err := net.Listen("tcp", ":3200")
@@ -453,13 +968,16 @@ func startServer(
 
 	srv := grpc.NewServer(
 		grpc.UnaryInterceptor(pb.AuthenticateInterceptor(userAuthenticator)),
+		grpc.StreamInterceptor(pb.StreamAuthenticateInterceptor(userAuthenticator)),
 	)
 	pb.RegisterURLServiceServer(srv, pb.NewURLsServer(
 		config,
 		store,
 		userAuthenticator,
 		urlCreateService,
+		urlUpdater,
 		urlDeleter,
+		services.NewURLExporter(store, config.ShortURL),
 	))
 	go func() {
 		if err := srv.Serve(listen); err != nil {
@@ -522,3 +1040,102 @@ func authInterceptor(userID int) func(
 		)
 	}
 }
+
+type serverStreamStub struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s serverStreamStub) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamTrustedIPInterceptor(t *testing.T) {
+	config := defaultConfig
+	config.TrustedSubnet = "192.168.0.0/24"
+	interceptor := pb.StreamTrustedIPInterceptor(services.NewIPChecker(config), nil)
+	handler := func(srv interface{}, stream grpc.ServerStream) error { return nil }
+	// Stream RPCs of URL service do not require trusted ip
+	checkedMethod := "/urlshort.Admin/Watch"
+
+	testCases := []struct {
+		name   string
+		method string
+		md     metadata.MD
+		want   error
+	}{
+		{
+			name:   "calls handler if ip is trusted",
+			method: checkedMethod,
+			md:     metadata.Pairs("x-real-ip", "192.168.0.10"),
+		},
+		{
+			name:   "responds with permission denied status if ip is not trusted",
+			method: checkedMethod,
+			md:     metadata.Pairs("x-real-ip", "10.0.0.1"),
+			want:   status.Error(codes.PermissionDenied, "forbidden"),
+		},
+		{
+			name:   "responds with permission denied status if ip is missing",
+			method: checkedMethod,
+			md:     metadata.MD{},
+			want:   status.Error(codes.PermissionDenied, `missing "x-real-ip"`),
+		},
+		{
+			name:   "calls handler of ignored method",
+			method: pb.URLService_StreamCreateURLs_FullMethodName,
+			md:     metadata.MD{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stream := serverStreamStub{ctx: metadata.NewIncomingContext(context.Background(), tc.md)}
+			err := interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: tc.method}, handler)
+			assert.Equal(t, tc.want, err)
+		})
+	}
+}
+
+type storagePingerStub struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (s *storagePingerStub) Ping(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *storagePingerStub) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func TestWatchStorageHealth(t *testing.T) {
+	healthServer := health.NewServer()
+	store := &storagePingerStub{err: errors.New("connection refused")}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pb.WatchStorageHealth(ctx, healthServer, store, 10*time.Millisecond)
+
+	servingStatus := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		out, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return healthpb.HealthCheckResponse_UNKNOWN
+		}
+		return out.Status
+	}
+
+	assert.Eventually(t, func() bool {
+		return servingStatus("URLService") == healthpb.HealthCheckResponse_NOT_SERVING
+	}, time.Second, 5*time.Millisecond)
+
+	store.setErr(nil)
+	assert.Eventually(t, func() bool {
+		return servingStatus("") == healthpb.HealthCheckResponse_SERVING &&
+			servingStatus("URLService") == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, 5*time.Millisecond)
+}
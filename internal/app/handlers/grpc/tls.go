@@ -0,0 +1,70 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Server TLS config. If clientCAFile is given, client certificates are verified
+// against it, clients without certificate are still accepted
+func ServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile == "" {
+		return config, nil
+	}
+
+	caPEM, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("failed to parse client CA: no certificates found")
+	}
+	config.ClientCAs = clientCAs
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+
+	return config, nil
+}
+
+// Client certificate of peer is verified and its common name or one of DNS
+// names is trusted. Any verified certificate is trusted if trustedClients is empty
+func trustedClientCert(ctx context.Context, trustedClients []string) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return false
+	}
+	if len(trustedClients) == 0 {
+		return true
+	}
+
+	cert := tlsInfo.State.VerifiedChains[0][0]
+	names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	for _, name := range names {
+		for _, trusted := range trustedClients {
+			if name != "" && name == trusted {
+				return true
+			}
+		}
+	}
+
+	return false
+}
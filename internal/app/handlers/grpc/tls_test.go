@@ -0,0 +1,161 @@
+package grpc_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/ilya-burinskiy/urlshort/internal/app/handlers/grpc"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func (c testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key, Leaf: c.cert}
+}
+
+// Generate certificate signed by parent, self-signed CA if parent is nil
+func generateCert(t *testing.T, commonName string, parent *testCert) testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{commonName},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return testCert{cert: cert, key: key, der: der}
+}
+
+func writePEM(t *testing.T, path, blockType string, data []byte) {
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0600)
+	require.NoError(t, err)
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := generateCert(t, "urlshort CA", nil)
+	otherCA := generateCert(t, "other CA", nil)
+	serverCert := generateCert(t, "localhost", &ca)
+
+	dir := t.TempDir()
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.der)
+	writePEM(t, filepath.Join(dir, "server.pem"), "CERTIFICATE", serverCert.der)
+	keyDER, err := x509.MarshalECPrivateKey(serverCert.key)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, "server-key.pem"), "EC PRIVATE KEY", keyDER)
+
+	tlsConfig, err := pb.ServerTLSConfig(
+		filepath.Join(dir, "server.pem"),
+		filepath.Join(dir, "server-key.pem"),
+		filepath.Join(dir, "ca.pem"),
+	)
+	require.NoError(t, err)
+
+	store := storage.NewMapStorage(nil)
+	ipChecker := services.NewIPChecker(defaultConfig)
+	srv := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(tlsConfig)),
+		grpc.UnaryInterceptor(pb.TrustedIPInterceptor(ipChecker, []string{"internal-service"})),
+	)
+	pb.RegisterURLServiceServer(srv, pb.NewURLsServer(
+		defaultConfig,
+		store,
+		new(userAuthenticatorMock),
+		new(urlShortenerMock),
+		services.NewURLUpdater(store),
+		services.NewDeferredDeleter(store),
+		services.NewURLExporter(store, defaultConfig.ShortURL),
+	))
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		if err := srv.Serve(listen); err != nil {
+			t.Error(err)
+		}
+	}()
+	defer srv.Stop()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ca.cert)
+	getStats := func(clientCerts ...tls.Certificate) error {
+		creds := credentials.NewTLS(&tls.Config{
+			RootCAs:      rootCAs,
+			Certificates: clientCerts,
+			ServerName:   "localhost",
+			MinVersion:   tls.VersionTLS12,
+		})
+		conn, err := grpc.Dial(listen.Addr().String(), grpc.WithTransportCredentials(creds))
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, conn.Close())
+		}()
+
+		_, err = pb.NewURLServiceClient(conn).GetStats(context.Background(), &pb.GetStatsRequest{})
+		return err
+	}
+
+	t.Run("trusts client certificate with trusted name", func(t *testing.T) {
+		err := getStats(generateCert(t, "internal-service", &ca).tlsCertificate())
+		assert.NoError(t, err)
+	})
+
+	t.Run("does not trust client certificate with other name", func(t *testing.T) {
+		err := getStats(generateCert(t, "public-client", &ca).tlsCertificate())
+		assert.Equal(t, status.Error(codes.PermissionDenied, `missing "x-real-ip"`), err)
+	})
+
+	t.Run("does not trust client without certificate", func(t *testing.T) {
+		err := getStats()
+		assert.Equal(t, status.Error(codes.PermissionDenied, `missing "x-real-ip"`), err)
+	})
+
+	t.Run("does not trust client certificate signed by unknown CA", func(t *testing.T) {
+		err := getStats(generateCert(t, "internal-service", &otherCA).tlsCertificate())
+		assert.Equal(t, status.Error(codes.PermissionDenied, `missing "x-real-ip"`), err)
+	})
+}
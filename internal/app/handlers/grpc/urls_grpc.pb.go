@@ -19,13 +19,19 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	URLService_CreateURL_FullMethodName      = "/URLService/CreateURL"
-	URLService_GetOriginalURL_FullMethodName = "/URLService/GetOriginalURL"
-	URLService_BatchCreateURL_FullMethodName = "/URLService/BatchCreateURL"
-	URLService_GetUserURLs_FullMethodName    = "/URLService/GetUserURLs"
-	URLService_DeleteUserURLs_FullMethodName = "/URLService/DeleteUserURLs"
-	URLService_GetStats_FullMethodName       = "/URLService/GetStats"
-	URLService_PingDB_FullMethodName         = "/URLService/PingDB"
+	URLService_CreateURL_FullMethodName        = "/URLService/CreateURL"
+	URLService_GetOriginalURL_FullMethodName   = "/URLService/GetOriginalURL"
+	URLService_GetURLPreview_FullMethodName    = "/URLService/GetURLPreview"
+	URLService_GetQRCode_FullMethodName        = "/URLService/GetQRCode"
+	URLService_BatchCreateURL_FullMethodName   = "/URLService/BatchCreateURL"
+	URLService_StreamCreateURLs_FullMethodName = "/URLService/StreamCreateURLs"
+	URLService_GetUserURLs_FullMethodName      = "/URLService/GetUserURLs"
+	URLService_StreamUserURLs_FullMethodName   = "/URLService/StreamUserURLs"
+	URLService_ExportUserURLs_FullMethodName   = "/URLService/ExportUserURLs"
+	URLService_UpdateUserURL_FullMethodName    = "/URLService/UpdateUserURL"
+	URLService_DeleteUserURLs_FullMethodName   = "/URLService/DeleteUserURLs"
+	URLService_GetStats_FullMethodName         = "/URLService/GetStats"
+	URLService_PingDB_FullMethodName           = "/URLService/PingDB"
 )
 
 // URLServiceClient is the client API for URLService service.
@@ -34,8 +40,18 @@ const (
 type URLServiceClient interface {
 	CreateURL(ctx context.Context, in *CreateURLRequest, opts ...grpc.CallOption) (*CreateURLResponse, error)
 	GetOriginalURL(ctx context.Context, in *GetOriginalURLRequest, opts ...grpc.CallOption) (*GetOriginalURLResponse, error)
+	GetURLPreview(ctx context.Context, in *GetURLPreviewRequest, opts ...grpc.CallOption) (*GetURLPreviewResponse, error)
+	GetQRCode(ctx context.Context, in *GetQRCodeRequest, opts ...grpc.CallOption) (*GetQRCodeResponse, error)
 	BatchCreateURL(ctx context.Context, in *BatchCreateURLRequest, opts ...grpc.CallOption) (*BatchCreateURLResponse, error)
+	// Create URLs one by one as items arrive and stream result of every item,
+	// failed items do not stop the stream
+	StreamCreateURLs(ctx context.Context, opts ...grpc.CallOption) (URLService_StreamCreateURLsClient, error)
 	GetUserURLs(ctx context.Context, in *GetUserURLsRequest, opts ...grpc.CallOption) (*GetUserURLsResponse, error)
+	// Stream sorted user URLs, storage is read page by page
+	StreamUserURLs(ctx context.Context, in *StreamUserURLsRequest, opts ...grpc.CallOption) (URLService_StreamUserURLsClient, error)
+	// Stream user URLs with click counts in creation order
+	ExportUserURLs(ctx context.Context, in *ExportUserURLsRequest, opts ...grpc.CallOption) (URLService_ExportUserURLsClient, error)
+	UpdateUserURL(ctx context.Context, in *UpdateUserURLRequest, opts ...grpc.CallOption) (*UpdateUserURLResponse, error)
 	DeleteUserURLs(ctx context.Context, in *DeleteUserURLsRequest, opts ...grpc.CallOption) (*DeleteUserURLsResponse, error)
 	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
 	PingDB(ctx context.Context, in *PingDBRequest, opts ...grpc.CallOption) (*PingDBResponse, error)
@@ -67,6 +83,24 @@ func (c *uRLServiceClient) GetOriginalURL(ctx context.Context, in *GetOriginalUR
 	return out, nil
 }
 
+func (c *uRLServiceClient) GetURLPreview(ctx context.Context, in *GetURLPreviewRequest, opts ...grpc.CallOption) (*GetURLPreviewResponse, error) {
+	out := new(GetURLPreviewResponse)
+	err := c.cc.Invoke(ctx, URLService_GetURLPreview_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLServiceClient) GetQRCode(ctx context.Context, in *GetQRCodeRequest, opts ...grpc.CallOption) (*GetQRCodeResponse, error) {
+	out := new(GetQRCodeResponse)
+	err := c.cc.Invoke(ctx, URLService_GetQRCode_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *uRLServiceClient) BatchCreateURL(ctx context.Context, in *BatchCreateURLRequest, opts ...grpc.CallOption) (*BatchCreateURLResponse, error) {
 	out := new(BatchCreateURLResponse)
 	err := c.cc.Invoke(ctx, URLService_BatchCreateURL_FullMethodName, in, out, opts...)
@@ -76,6 +110,37 @@ func (c *uRLServiceClient) BatchCreateURL(ctx context.Context, in *BatchCreateUR
 	return out, nil
 }
 
+func (c *uRLServiceClient) StreamCreateURLs(ctx context.Context, opts ...grpc.CallOption) (URLService_StreamCreateURLsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &URLService_ServiceDesc.Streams[0], URLService_StreamCreateURLs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &uRLServiceStreamCreateURLsClient{stream}
+	return x, nil
+}
+
+type URLService_StreamCreateURLsClient interface {
+	Send(*StreamCreateURLsRequest) error
+	Recv() (*StreamCreateURLsResponse, error)
+	grpc.ClientStream
+}
+
+type uRLServiceStreamCreateURLsClient struct {
+	grpc.ClientStream
+}
+
+func (x *uRLServiceStreamCreateURLsClient) Send(m *StreamCreateURLsRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *uRLServiceStreamCreateURLsClient) Recv() (*StreamCreateURLsResponse, error) {
+	m := new(StreamCreateURLsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *uRLServiceClient) GetUserURLs(ctx context.Context, in *GetUserURLsRequest, opts ...grpc.CallOption) (*GetUserURLsResponse, error) {
 	out := new(GetUserURLsResponse)
 	err := c.cc.Invoke(ctx, URLService_GetUserURLs_FullMethodName, in, out, opts...)
@@ -85,6 +150,79 @@ func (c *uRLServiceClient) GetUserURLs(ctx context.Context, in *GetUserURLsReque
 	return out, nil
 }
 
+func (c *uRLServiceClient) StreamUserURLs(ctx context.Context, in *StreamUserURLsRequest, opts ...grpc.CallOption) (URLService_StreamUserURLsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &URLService_ServiceDesc.Streams[1], URLService_StreamUserURLs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &uRLServiceStreamUserURLsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type URLService_StreamUserURLsClient interface {
+	Recv() (*GetUserURLsResponse_Item, error)
+	grpc.ClientStream
+}
+
+type uRLServiceStreamUserURLsClient struct {
+	grpc.ClientStream
+}
+
+func (x *uRLServiceStreamUserURLsClient) Recv() (*GetUserURLsResponse_Item, error) {
+	m := new(GetUserURLsResponse_Item)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *uRLServiceClient) ExportUserURLs(ctx context.Context, in *ExportUserURLsRequest, opts ...grpc.CallOption) (URLService_ExportUserURLsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &URLService_ServiceDesc.Streams[2], URLService_ExportUserURLs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &uRLServiceExportUserURLsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type URLService_ExportUserURLsClient interface {
+	Recv() (*ExportUserURLsResponse, error)
+	grpc.ClientStream
+}
+
+type uRLServiceExportUserURLsClient struct {
+	grpc.ClientStream
+}
+
+func (x *uRLServiceExportUserURLsClient) Recv() (*ExportUserURLsResponse, error) {
+	m := new(ExportUserURLsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *uRLServiceClient) UpdateUserURL(ctx context.Context, in *UpdateUserURLRequest, opts ...grpc.CallOption) (*UpdateUserURLResponse, error) {
+	out := new(UpdateUserURLResponse)
+	err := c.cc.Invoke(ctx, URLService_UpdateUserURL_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *uRLServiceClient) DeleteUserURLs(ctx context.Context, in *DeleteUserURLsRequest, opts ...grpc.CallOption) (*DeleteUserURLsResponse, error) {
 	out := new(DeleteUserURLsResponse)
 	err := c.cc.Invoke(ctx, URLService_DeleteUserURLs_FullMethodName, in, out, opts...)
@@ -118,8 +256,18 @@ func (c *uRLServiceClient) PingDB(ctx context.Context, in *PingDBRequest, opts .
 type URLServiceServer interface {
 	CreateURL(context.Context, *CreateURLRequest) (*CreateURLResponse, error)
 	GetOriginalURL(context.Context, *GetOriginalURLRequest) (*GetOriginalURLResponse, error)
+	GetURLPreview(context.Context, *GetURLPreviewRequest) (*GetURLPreviewResponse, error)
+	GetQRCode(context.Context, *GetQRCodeRequest) (*GetQRCodeResponse, error)
 	BatchCreateURL(context.Context, *BatchCreateURLRequest) (*BatchCreateURLResponse, error)
+	// Create URLs one by one as items arrive and stream result of every item,
+	// failed items do not stop the stream
+	StreamCreateURLs(URLService_StreamCreateURLsServer) error
 	GetUserURLs(context.Context, *GetUserURLsRequest) (*GetUserURLsResponse, error)
+	// Stream sorted user URLs, storage is read page by page
+	StreamUserURLs(*StreamUserURLsRequest, URLService_StreamUserURLsServer) error
+	// Stream user URLs with click counts in creation order
+	ExportUserURLs(*ExportUserURLsRequest, URLService_ExportUserURLsServer) error
+	UpdateUserURL(context.Context, *UpdateUserURLRequest) (*UpdateUserURLResponse, error)
 	DeleteUserURLs(context.Context, *DeleteUserURLsRequest) (*DeleteUserURLsResponse, error)
 	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
 	PingDB(context.Context, *PingDBRequest) (*PingDBResponse, error)
@@ -136,12 +284,30 @@ func (UnimplementedURLServiceServer) CreateURL(context.Context, *CreateURLReques
 func (UnimplementedURLServiceServer) GetOriginalURL(context.Context, *GetOriginalURLRequest) (*GetOriginalURLResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetOriginalURL not implemented")
 }
+func (UnimplementedURLServiceServer) GetURLPreview(context.Context, *GetURLPreviewRequest) (*GetURLPreviewResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetURLPreview not implemented")
+}
+func (UnimplementedURLServiceServer) GetQRCode(context.Context, *GetQRCodeRequest) (*GetQRCodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQRCode not implemented")
+}
 func (UnimplementedURLServiceServer) BatchCreateURL(context.Context, *BatchCreateURLRequest) (*BatchCreateURLResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method BatchCreateURL not implemented")
 }
+func (UnimplementedURLServiceServer) StreamCreateURLs(URLService_StreamCreateURLsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamCreateURLs not implemented")
+}
 func (UnimplementedURLServiceServer) GetUserURLs(context.Context, *GetUserURLsRequest) (*GetUserURLsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserURLs not implemented")
 }
+func (UnimplementedURLServiceServer) StreamUserURLs(*StreamUserURLsRequest, URLService_StreamUserURLsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamUserURLs not implemented")
+}
+func (UnimplementedURLServiceServer) ExportUserURLs(*ExportUserURLsRequest, URLService_ExportUserURLsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportUserURLs not implemented")
+}
+func (UnimplementedURLServiceServer) UpdateUserURL(context.Context, *UpdateUserURLRequest) (*UpdateUserURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateUserURL not implemented")
+}
 func (UnimplementedURLServiceServer) DeleteUserURLs(context.Context, *DeleteUserURLsRequest) (*DeleteUserURLsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteUserURLs not implemented")
 }
@@ -200,6 +366,42 @@ func _URLService_GetOriginalURL_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLService_GetURLPreview_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetURLPreviewRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLServiceServer).GetURLPreview(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLService_GetURLPreview_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLServiceServer).GetURLPreview(ctx, req.(*GetURLPreviewRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLService_GetQRCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetQRCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLServiceServer).GetQRCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLService_GetQRCode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLServiceServer).GetQRCode(ctx, req.(*GetQRCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _URLService_BatchCreateURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(BatchCreateURLRequest)
 	if err := dec(in); err != nil {
@@ -218,6 +420,32 @@ func _URLService_BatchCreateURL_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLService_StreamCreateURLs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(URLServiceServer).StreamCreateURLs(&uRLServiceStreamCreateURLsServer{stream})
+}
+
+type URLService_StreamCreateURLsServer interface {
+	Send(*StreamCreateURLsResponse) error
+	Recv() (*StreamCreateURLsRequest, error)
+	grpc.ServerStream
+}
+
+type uRLServiceStreamCreateURLsServer struct {
+	grpc.ServerStream
+}
+
+func (x *uRLServiceStreamCreateURLsServer) Send(m *StreamCreateURLsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *uRLServiceStreamCreateURLsServer) Recv() (*StreamCreateURLsRequest, error) {
+	m := new(StreamCreateURLsRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _URLService_GetUserURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetUserURLsRequest)
 	if err := dec(in); err != nil {
@@ -236,6 +464,66 @@ func _URLService_GetUserURLs_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLService_StreamUserURLs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamUserURLsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(URLServiceServer).StreamUserURLs(m, &uRLServiceStreamUserURLsServer{stream})
+}
+
+type URLService_StreamUserURLsServer interface {
+	Send(*GetUserURLsResponse_Item) error
+	grpc.ServerStream
+}
+
+type uRLServiceStreamUserURLsServer struct {
+	grpc.ServerStream
+}
+
+func (x *uRLServiceStreamUserURLsServer) Send(m *GetUserURLsResponse_Item) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _URLService_ExportUserURLs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportUserURLsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(URLServiceServer).ExportUserURLs(m, &uRLServiceExportUserURLsServer{stream})
+}
+
+type URLService_ExportUserURLsServer interface {
+	Send(*ExportUserURLsResponse) error
+	grpc.ServerStream
+}
+
+type uRLServiceExportUserURLsServer struct {
+	grpc.ServerStream
+}
+
+func (x *uRLServiceExportUserURLsServer) Send(m *ExportUserURLsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _URLService_UpdateUserURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateUserURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLServiceServer).UpdateUserURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLService_UpdateUserURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLServiceServer).UpdateUserURL(ctx, req.(*UpdateUserURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _URLService_DeleteUserURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeleteUserURLsRequest)
 	if err := dec(in); err != nil {
@@ -305,6 +593,14 @@ var URLService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetOriginalURL",
 			Handler:    _URLService_GetOriginalURL_Handler,
 		},
+		{
+			MethodName: "GetURLPreview",
+			Handler:    _URLService_GetURLPreview_Handler,
+		},
+		{
+			MethodName: "GetQRCode",
+			Handler:    _URLService_GetQRCode_Handler,
+		},
 		{
 			MethodName: "BatchCreateURL",
 			Handler:    _URLService_BatchCreateURL_Handler,
@@ -313,6 +609,10 @@ var URLService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUserURLs",
 			Handler:    _URLService_GetUserURLs_Handler,
 		},
+		{
+			MethodName: "UpdateUserURL",
+			Handler:    _URLService_UpdateUserURL_Handler,
+		},
 		{
 			MethodName: "DeleteUserURLs",
 			Handler:    _URLService_DeleteUserURLs_Handler,
@@ -326,6 +626,23 @@ var URLService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _URLService_PingDB_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamCreateURLs",
+			Handler:       _URLService_StreamCreateURLs_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "StreamUserURLs",
+			Handler:       _URLService_StreamUserURLs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportUserURLs",
+			Handler:       _URLService_ExportUserURLs_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "internal/app/handlers/grpc/urls.proto",
 }
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
@@ -12,9 +13,17 @@ import (
 	"github.com/ilya-burinskiy/urlshort/internal/app/auth"
 	"github.com/ilya-burinskiy/urlshort/internal/app/configs"
 	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
 	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
 )
 
+// Split test variant cookie expiration time
+const variantCookieExp = 30 * 24 * time.Hour
+
+// Unknown short domain error
+var errUnknownDomain = errors.New("unknown domain")
+
 // Handlers
 type Handlers struct {
 	store  storage.Storage
@@ -33,6 +42,31 @@ func NewHandlers(
 
 }
 
+func (h Handlers) stats(r *http.Request) (statsResponse, error) {
+	usersCount, err := h.store.UsersCount(r.Context())
+	if err != nil {
+		return statsResponse{}, err
+	}
+	urlsCount, err := h.store.URLsCount(r.Context())
+	if err != nil {
+		return statsResponse{}, err
+	}
+
+	return statsResponse{URLs: urlsCount, Users: usersCount}, nil
+}
+
+// Error response writer. Legacy routes and versioned API write errors differently
+type errorWriter func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+// Write error message as JSON string
+func writeJSONError(w http.ResponseWriter, _ *http.Request, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err = json.NewEncoder(w).Encode(err.Error()); err != nil {
+		logger.Log.Info("failed to encode response", zap.Error(err))
+	}
+}
+
 // Ping database
 func (h Handlers) PingDB(w http.ResponseWriter, r *http.Request) {
 	conn, err := pgx.Connect(context.Background(), h.config.DatabaseDSN)
@@ -53,25 +87,22 @@ func (h Handlers) PingDB(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// URLs and users count
+type statsResponse struct {
+	URLs  int `json:"urls"`
+	Users int `json:"users"`
+}
+
 // GetStats
 func (h Handlers) GetStats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	ctx := r.Context()
-	usersCount, err := h.store.UsersCount(ctx)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	urlsCount, err := h.store.URLsCount(ctx)
+	stats, err := h.stats(r)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	response, err := json.Marshal(map[string]int{
-		"urls":  urlsCount,
-		"users": usersCount,
-	})
+	response, err := json.Marshal(stats)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		logger.Log.Info("failed to encode response", zap.Error(err))
@@ -104,3 +135,60 @@ func setJWTCookie(w http.ResponseWriter, token string) {
 		},
 	)
 }
+
+func (h Handlers) saveClick(ctx context.Context, click models.Click) {
+	if err := h.store.SaveClick(ctx, click); err != nil {
+		logger.Log.Info("failed to save click", zap.Error(err))
+	}
+}
+
+func getVariant(r *http.Request, shortenedPath string) string {
+	cookie, err := r.Cookie(variantCookieName(shortenedPath))
+	if err != nil {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+func setVariantCookie(w http.ResponseWriter, shortenedPath, variant string) {
+	http.SetCookie(
+		w,
+		&http.Cookie{
+			Name:     variantCookieName(shortenedPath),
+			Value:    variant,
+			Path:     "/" + shortenedPath,
+			MaxAge:   int(variantCookieExp / time.Second),
+			HttpOnly: true,
+		},
+	)
+}
+
+func variantCookieName(shortenedPath string) string {
+	return "variant_" + shortenedPath
+}
+
+func setCacheHeaders(w http.ResponseWriter, headers services.CacheHeaders) {
+	w.Header().Set("Cache-Control", headers.CacheControl)
+	w.Header().Set("Expires", headers.Expires.UTC().Format(http.TimeFormat))
+}
+
+// Validate domain chosen by user, empty domain is the default one
+func (h Handlers) normalizeDomain(domain string) (string, error) {
+	normalized, ok := h.config.NormalizeDomain(domain)
+	if !ok {
+		return "", errUnknownDomain
+	}
+
+	return normalized, nil
+}
+
+// Domain of user shortened URL from "domain" query parameter. URLs on unknown domains are not found
+func (h Handlers) queryDomain(r *http.Request) (string, error) {
+	domain, err := h.normalizeDomain(r.URL.Query().Get("domain"))
+	if err != nil {
+		return "", storage.ErrNotFound
+	}
+
+	return domain, nil
+}
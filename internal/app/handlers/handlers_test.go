@@ -30,6 +30,8 @@ var defaultConfig = configs.Config{
 	BaseURL:         "http://localhost:8080",
 	ServerAddress:   "http://localhost:8080",
 	FileStoragePath: "storage",
+	RedirectCode:    http.StatusTemporaryRedirect,
+	NotFoundCode:    http.StatusNotFound,
 }
 
 func toJSON(t require.TestingT, v interface{}) string {
@@ -41,12 +43,12 @@ func toJSON(t require.TestingT, v interface{}) string {
 
 type urlShortenerMock struct{ mock.Mock }
 
-func (m *urlShortenerMock) Shortify(originalURL string, user models.User) (models.Record, error) {
-	args := m.Called(originalURL, user)
+func (m *urlShortenerMock) Shortify(ctx context.Context, record models.Record, user models.User) (models.Record, error) {
+	args := m.Called(record, user)
 	return args.Get(0).(models.Record), args.Error(1)
 }
 
-func (m *urlShortenerMock) BatchShortify(records []models.Record, user models.User) ([]models.Record, error) {
+func (m *urlShortenerMock) BatchShortify(ctx context.Context, records []models.Record, user models.User) ([]models.Record, error) {
 	args := m.Called(records, user)
 	return args.Get(0).([]models.Record), args.Error(1)
 }
@@ -63,7 +65,7 @@ func (m *userAuthenticatorMock) AuthOrRegister(ctx context.Context, jwtStr strin
 	return args.Get(0).(models.User), args.String(1), args.Error(2)
 }
 
-func (m *userAuthenticatorMock) Auth(jwtStr string) (models.User, error) {
+func (m *userAuthenticatorMock) Auth(ctx context.Context, jwtStr string) (models.User, error) {
 	args := m.Called(jwtStr)
 	return args.Get(0).(models.User), args.Error(1)
 }
@@ -86,7 +86,7 @@ func BenchmarkGetOriginalURLHandler(b *testing.B) {
 	ctrl := gomock.NewController(b)
 	storageMock := mocks.NewMockStorage(ctrl)
 	storageMock.EXPECT().
-		FindByShortenedPath(gomock.Any(), gomock.Any()).
+		FindByShortenedPath(gomock.Any(), "", gomock.Any()).
 		AnyTimes().
 		Return(models.Record{OriginalURL: "http://example.com"}, nil)
 
@@ -142,7 +142,7 @@ func BenchmarkGetUserURLs(b *testing.B) {
 	storageMock := mocks.NewMockStorage(ctrl)
 	userRecords := make([]models.Record, 100)
 	storageMock.EXPECT().
-		FindByUser(gomock.Any(), gomock.Any()).
+		FindByUser(gomock.Any(), gomock.Any(), gomock.Any()).
 		AnyTimes().
 		Return(userRecords, nil)
 	handler := http.HandlerFunc(handlers.NewHandlers(defaultConfig, storageMock).GetUserURLs)
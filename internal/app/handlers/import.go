@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
+	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+// Import file size limits. Larger files are imported in background
+const (
+	maxImportSize     = 32 << 20
+	maxSyncImportSize = 1 << 20
+)
+
+// Import user shortened URLs from CSV. Responds with per-row report, or starts
+// background import job if async=true is given or file is larger than 1 MiB
+func (h Handlers) ImportURLs(
+	importer services.URLImporter,
+	importJobs *services.ImportJobs) func(http.ResponseWriter, *http.Request) {
+
+	return h.importURLs(importer, importJobs, "/api/user/import/", writeJSONError)
+}
+
+// Import job location is jobsPath followed by job ID
+func (h Handlers) importURLs(
+	importer services.URLImporter,
+	importJobs *services.ImportJobs,
+	jobsPath string,
+	writeError errorWriter) func(http.ResponseWriter, *http.Request) {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		user := models.User{ID: userID}
+		body := http.MaxBytesReader(w, r.Body, maxImportSize)
+		async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+
+		var report services.ImportReport
+		var job services.ImportJob
+		var err error
+		if async || r.ContentLength > maxSyncImportSize {
+			var file *importFile
+			file, err = spoolImport(body)
+			if err == nil {
+				job, err = importJobs.Start(file, user)
+				if err != nil {
+					if closeErr := file.Close(); closeErr != nil {
+						logger.Log.Info("failed to close import file", zap.Error(closeErr))
+					}
+				}
+			}
+		} else {
+			report, err = importer.Import(r.Context(), body, user, nil)
+		}
+		if err != nil {
+			code := http.StatusInternalServerError
+			var maxBytesErr *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesErr):
+				code = http.StatusRequestEntityTooLarge
+			case errors.Is(err, services.ErrInvalidImport):
+				code = http.StatusUnprocessableEntity
+			}
+			writeError(w, r, code, err)
+			return
+		}
+
+		var response interface{} = report
+		if job.ID != "" {
+			w.Header().Set("Location", jobsPath+job.ID)
+			w.WriteHeader(http.StatusAccepted)
+			response = job
+		}
+		if err = json.NewEncoder(w).Encode(response); err != nil {
+			logger.Log.Info("failed to encode response", zap.Error(err))
+		}
+	}
+}
+
+// Get user import job status
+func (h Handlers) GetImportJob(importJobs *services.ImportJobs) func(http.ResponseWriter, *http.Request) {
+	return h.getImportJob(importJobs, writeJSONError)
+}
+
+func (h Handlers) getImportJob(
+	importJobs *services.ImportJobs,
+	writeError errorWriter) func(http.ResponseWriter, *http.Request) {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		job, err := importJobs.Get(chi.URLParam(r, "id"), models.User{ID: userID})
+		if err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, storage.ErrNotFound) {
+				code = http.StatusNotFound
+			}
+			writeError(w, r, code, err)
+			return
+		}
+
+		if err = json.NewEncoder(w).Encode(job); err != nil {
+			logger.Log.Info("failed to encode response", zap.Error(err))
+		}
+	}
+}
+
+// Temporary copy of uploaded import file, removed on close
+type importFile struct {
+	*os.File
+}
+
+func (f *importFile) Close() error {
+	err := f.File.Close()
+	if rmErr := os.Remove(f.Name()); err == nil {
+		err = rmErr
+	}
+
+	return err
+}
+
+// Background import outlives request, so upload is copied to temporary file
+func spoolImport(body io.Reader) (*importFile, error) {
+	file, err := os.CreateTemp("", "urlshort-import-*.csv")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import file: %w", err)
+	}
+	f := &importFile{File: file}
+	if _, err = io.Copy(f, body); err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		if closeErr := f.Close(); closeErr != nil {
+			logger.Log.Info("failed to close import file", zap.Error(closeErr))
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
@@ -0,0 +1,134 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/handlers"
+	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+func TestImportURLsHandler(t *testing.T) {
+	store := storage.NewMapStorage(nil)
+	strGen := new(randHexStrGeneratorMock)
+	strGen.On("Gen", 8).Return("generated", nil)
+	strGen.On("Gen", 16).Return("job", nil)
+	importer := services.NewURLImporter(8, strGen, store, defaultConfig.ShortURL)
+	importJobs := services.NewImportJobs(importer, strGen)
+
+	user := models.User{ID: 1}
+	userAuthenticator := new(userAuthenticatorMock)
+	userAuthenticator.On("Auth", mock.Anything).Return(user, nil)
+	router := chi.NewRouter()
+	handler := handlers.NewHandlers(defaultConfig, store)
+	router.Use(middlewares.Authenticate(userAuthenticator))
+	router.Post("/api/user/import", handler.ImportURLs(importer, importJobs))
+	router.Get("/api/user/import/{id}", handler.GetImportJob(importJobs))
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name  string
+		query string
+		body  string
+		want  want
+	}{
+		{
+			name:  "responses with import report",
+			query: "",
+			body:  "original_url,alias\nhttp://example.com\nhttp://example.org,my-link\nexample.net\n",
+			want: want{
+				code: http.StatusOK,
+				response: `{"created":2,"conflicts":0,"invalid":1,"rows":[` +
+					`{"row":2,"status":"created","short_url":"http://localhost:8080/generated"},` +
+					`{"row":3,"status":"created","short_url":"http://localhost:8080/my-link"},` +
+					`{"row":4,"status":"invalid","error":"original_url must be an absolute http or https URL"}]}` + "\n",
+			},
+		},
+		{
+			name:  "responses with unprocessable entity status if header is invalid",
+			query: "",
+			body:  "url\nhttp://example.com\n",
+			want: want{
+				code:     http.StatusUnprocessableEntity,
+				response: toJSON(t, "invalid import file: missing original_url column") + "\n",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(
+				http.MethodPost,
+				testServer.URL+"/api/user/import"+tc.query,
+				strings.NewReader(tc.body),
+			)
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "text/csv")
+			request.AddCookie(generateAuthCookie(t, user))
+
+			response, err := testServer.Client().Do(request)
+			require.NoError(t, err)
+			resBody, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			assert.Equal(t, tc.want.code, response.StatusCode)
+			assert.Equal(t, tc.want.response, string(resBody))
+		})
+	}
+
+	t.Run("starts background import job", func(t *testing.T) {
+		request, err := http.NewRequest(
+			http.MethodPost,
+			testServer.URL+"/api/user/import?async=true",
+			strings.NewReader("original_url,alias\nhttp://example.io,io\nhttp://example.com\n"),
+		)
+		require.NoError(t, err)
+		request.Header.Set("Content-Type", "text/csv")
+		request.AddCookie(generateAuthCookie(t, user))
+
+		response, err := testServer.Client().Do(request)
+		require.NoError(t, err)
+		require.NoError(t, response.Body.Close())
+		assert.Equal(t, http.StatusAccepted, response.StatusCode)
+		require.Equal(t, "/api/user/import/job", response.Header.Get("Location"))
+
+		var job services.ImportJob
+		require.Eventually(t, func() bool {
+			request, err := http.NewRequest(http.MethodGet, testServer.URL+"/api/user/import/job", nil)
+			require.NoError(t, err)
+			request.AddCookie(generateAuthCookie(t, user))
+			response, err := testServer.Client().Do(request)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&job))
+
+			return job.Status != services.ImportJobRunning
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, services.ImportJobDone, job.Status)
+		assert.Equal(t, 1, job.Report.Created)
+		assert.Equal(t, 1, job.Report.Conflicts)
+	})
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/apierror"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+)
+
+// Types encoded or decoded by handlers, by OpenAPI schema name
+var openAPISchemaTypes = map[string]reflect.Type{
+	"RoutingRule":       reflect.TypeOf(models.RoutingRule{}),
+	"Variant":           reflect.TypeOf(models.Variant{}),
+	"CreateURLRequest":  reflect.TypeOf(createURLRequest{}),
+	"ShortenResult":     reflect.TypeOf(shortenResponse{}),
+	"ShortURL":          reflect.TypeOf(shortURLResponse{}),
+	"BatchCreateItem":   reflect.TypeOf(models.Record{}),
+	"BatchCreateResult": reflect.TypeOf(batchCreateResponseItem{}),
+	"URLPreview":        reflect.TypeOf(urlPreview{}),
+	"UserURL":           reflect.TypeOf(userURL{}),
+	"UserURLsPage":      reflect.TypeOf(userURLsPage{}),
+	"RecordPatch":       reflect.TypeOf(services.RecordPatch{}),
+	"UpdatedURL":        reflect.TypeOf(updatedURL{}),
+	"ClickStats":        reflect.TypeOf(models.ClickStats{}),
+	"ExportedURL":       reflect.TypeOf(services.ExportedURL{}),
+	"ImportRow":         reflect.TypeOf(services.ImportRow{}),
+	"ImportReport":      reflect.TypeOf(services.ImportReport{}),
+	"ImportJob":         reflect.TypeOf(services.ImportJob{}),
+	"Stats":             reflect.TypeOf(statsResponse{}),
+	"LegacyError":       reflect.TypeOf(""),
+	"Error":             reflect.TypeOf(apierror.Error{}),
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref"`
+	Type                 string                    `json:"type"`
+	Format               string                    `json:"format"`
+	Items                *openAPISchema            `json:"items"`
+	Properties           map[string]*openAPISchema `json:"properties"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties"`
+	Required             []string                  `json:"required"`
+}
+
+func TestOpenAPISchemasMatchTypes(t *testing.T) {
+	var spec struct {
+		Components struct {
+			Schemas map[string]*openAPISchema `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(openAPISpec, &spec))
+
+	names := make([]string, 0, len(openAPISchemaTypes))
+	for name := range openAPISchemaTypes {
+		names = append(names, name)
+	}
+	specNames := make([]string, 0, len(spec.Components.Schemas))
+	for name := range spec.Components.Schemas {
+		specNames = append(specNames, name)
+	}
+	sort.Strings(names)
+	sort.Strings(specNames)
+	require.Equal(t, names, specNames)
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			assertSchemaMatchesType(t, name, spec.Components.Schemas[name], openAPISchemaTypes[name])
+		})
+	}
+}
+
+func TestOpenAPIRefsResolve(t *testing.T) {
+	var spec struct {
+		Components map[string]map[string]json.RawMessage `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(openAPISpec, &spec))
+
+	for _, ref := range openAPIRefs(t) {
+		parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+		require.Len(t, parts, 2, ref)
+		_, ok := spec.Components[parts[0]][parts[1]]
+		assert.True(t, ok, "unresolved %s", ref)
+	}
+}
+
+func assertSchemaMatchesType(t *testing.T, path string, schema *openAPISchema, typ reflect.Type) {
+	t.Helper()
+	if !assert.NotNil(t, schema, "%s: missing schema", path) {
+		return
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		assert.Equal(t, openAPISchemaTypes[name], typ, "%s: %s is bound to other type", path, schema.Ref)
+		return
+	}
+	if typ == reflect.TypeOf(time.Time{}) {
+		assert.Equal(t, "string", schema.Type, path)
+		assert.Equal(t, "date-time", schema.Format, path)
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		assert.Equal(t, "string", schema.Type, path)
+	case reflect.Bool:
+		assert.Equal(t, "boolean", schema.Type, path)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		assert.Equal(t, "integer", schema.Type, path)
+	case reflect.Float32, reflect.Float64:
+		assert.Equal(t, "number", schema.Type, path)
+	case reflect.Slice:
+		assert.Equal(t, "array", schema.Type, path)
+		assertSchemaMatchesType(t, path+"[]", schema.Items, typ.Elem())
+	case reflect.Map:
+		assert.Equal(t, "object", schema.Type, path)
+		assertSchemaMatchesType(t, path+"{}", schema.AdditionalProperties, typ.Elem())
+	case reflect.Struct:
+		assert.Equal(t, "object", schema.Type, path)
+		fields := jsonFields(typ)
+		fieldNames := make([]string, 0, len(fields))
+		for name := range fields {
+			fieldNames = append(fieldNames, name)
+		}
+		propNames := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			propNames = append(propNames, name)
+		}
+		sort.Strings(fieldNames)
+		sort.Strings(propNames)
+		assert.Equal(t, fieldNames, propNames, "%s: properties", path)
+		for _, name := range schema.Required {
+			assert.Contains(t, fields, name, "%s: required", path)
+		}
+		for name, fieldType := range fields {
+			if prop, ok := schema.Properties[name]; ok {
+				assertSchemaMatchesType(t, path+"."+name, prop, fieldType)
+			}
+		}
+	default:
+		t.Errorf("%s: unsupported type %s", path, typ)
+	}
+}
+
+// Types of struct fields by JSON name
+func jsonFields(typ reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+func openAPIRefs(t *testing.T) []string {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal(openAPISpec, &doc))
+
+	var refs []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, value := range v {
+				if ref, ok := value.(string); ok && key == "$ref" {
+					refs = append(refs, ref)
+					continue
+				}
+				walk(value)
+			}
+		case []interface{}:
+			for _, value := range v {
+				walk(value)
+			}
+		}
+	}
+	walk(doc)
+
+	return refs
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+)
+
+// Query parameters controlling interstitial page
+const (
+	previewParam  = "preview"
+	continueParam = "continue"
+)
+
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex, nofollow">
+<title>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</title>
+</head>
+<body>
+<main>
+<h1>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</h1>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+<p>Destination set by the link owner:</p>
+<p><code>{{.Destination}}</code></p>
+{{if not .CreatedAt.IsZero}}<p>Created {{.CreatedAt.Format "January 2, 2006"}}</p>{{end}}
+<p><a href="{{.ContinueURL}}">Continue</a></p>
+</main>
+</body>
+</html>
+`))
+
+// Shortened URL preview
+type urlPreview struct {
+	OriginalURL  string    `json:"original_url"`
+	ShortURL     string    `json:"short_url"`
+	Title        string    `json:"title,omitempty"`
+	Description  string    `json:"description,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	Interstitial bool      `json:"interstitial"`
+}
+
+type previewPage struct {
+	Destination string
+	Title       string
+	Description string
+	CreatedAt   time.Time
+	ContinueURL string
+}
+
+// Get shortened URL preview. Links which can not be followed respond with the
+// same status as redirect
+func (h Handlers) GetURLPreview(w http.ResponseWriter, r *http.Request) {
+	h.getURLPreview(w, r, writeJSONError)
+}
+
+func (h Handlers) getURLPreview(w http.ResponseWriter, r *http.Request, writeError errorWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	domain, err := h.queryDomain(r)
+	var record models.Record
+	if err == nil {
+		record, err = h.store.FindByShortenedPath(r.Context(), domain, chi.URLParam(r, "id"))
+	}
+	var target string
+	if err == nil {
+		target, err = services.LinkTarget(record, time.Now())
+	}
+	if err != nil {
+		writeError(w, r, linkErrorStatus(err), err)
+		return
+	}
+
+	response := urlPreview{
+		OriginalURL:  target,
+		ShortURL:     h.config.ShortURL(record.Domain, record.ShortenedPath),
+		Title:        record.Title,
+		Description:  record.Description,
+		CreatedAt:    record.CreatedAt,
+		Interstitial: record.Interstitial,
+	}
+	if err = json.NewEncoder(w).Encode(response); err != nil {
+		logger.Log.Info("failed to encode response", zap.Error(err))
+	}
+}
+
+// Render interstitial page of link leading to destination. Continue link leads
+// to the shortened URL with incoming query parameters and skips the interstitial
+func (h Handlers) renderPreview(w http.ResponseWriter, r *http.Request, record models.Record, destination string) {
+	query := r.URL.Query()
+	query.Del(previewParam)
+	query.Set(continueParam, "1")
+	page := previewPage{
+		Destination: destination,
+		Title:       record.Title,
+		Description: record.Description,
+		CreatedAt:   record.CreatedAt,
+		ContinueURL: "/" + url.PathEscape(record.ShortenedPath) + "?" + query.Encode(),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if err := previewTemplate.Execute(w, page); err != nil {
+		logger.Log.Info("failed to render preview", zap.Error(err))
+	}
+}
+
+// Parse shortened path and preview mode from request, preview is requested by
+// "+" path suffix or "preview=1" query parameter
+func previewRequest(r *http.Request) (string, bool) {
+	shortenedPath := chi.URLParam(r, "id")
+	if path, ok := strings.CutSuffix(shortenedPath, "+"); ok {
+		return path, true
+	}
+
+	return shortenedPath, r.URL.Query().Get(previewParam) == "1"
+}
+
+// Interstitial control parameters are not passed to destination
+func redirectQuery(r *http.Request) url.Values {
+	query := r.URL.Query()
+	query.Del(previewParam)
+	query.Del(continueParam)
+
+	return query
+}
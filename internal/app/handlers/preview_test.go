@@ -0,0 +1,245 @@
+package handlers_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/handlers"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage/mocks"
+)
+
+func TestGetOriginalURLHandlerPreview(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storageMock := mocks.NewMockStorage(ctrl)
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "123").
+		AnyTimes().
+		Return(models.Record{
+			OriginalURL:   "http://example.com/?a=1&b=<2>",
+			ShortenedPath: "123",
+			Title:         "Example",
+			CreatedAt:     createdAt,
+		}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "forced").
+		AnyTimes().
+		Return(models.Record{
+			OriginalURL:   "http://example.com",
+			ShortenedPath: "forced",
+			Interstitial:  true,
+		}, nil)
+	activeUntil := time.Now().Add(-time.Hour)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "ended").
+		AnyTimes().
+		Return(models.Record{
+			OriginalURL:   "http://example.com",
+			ShortenedPath: "ended",
+			ActiveUntil:   &activeUntil,
+			FallbackURL:   "http://fallback.example.com",
+		}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "deleted").
+		AnyTimes().
+		Return(models.Record{ShortenedPath: "deleted", Interstitial: true, IsDeleted: true}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "exhausted").
+		AnyTimes().
+		Return(models.Record{
+			OriginalURL:   "http://example.com",
+			ShortenedPath: "exhausted",
+			Interstitial:  true,
+			MaxClicks:     1,
+		}, nil)
+	storageMock.EXPECT().
+		SaveClick(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(nil)
+
+	handler := handlers.NewHandlers(defaultConfig, storageMock)
+	router := chi.NewRouter()
+	router.Get("/{id}", handler.GetOriginalURL)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name     string
+		path     string
+		code     int
+		contains []string
+		location string
+	}{
+		{
+			name: "renders preview by path suffix",
+			path: "/123+",
+			code: http.StatusOK,
+			contains: []string{
+				"<title>Example</title>",
+				"<code>http://example.com/?a=1&amp;b=&lt;2&gt;</code>",
+				"Created March 1, 2024",
+				`<a href="/123?continue=1">Continue</a>`,
+			},
+		},
+		{
+			name: "renders preview by query parameter",
+			path: "/123?preview=1&ref=tw",
+			code: http.StatusOK,
+			contains: []string{
+				`<a href="/123?continue=1&amp;ref=tw">Continue</a>`,
+			},
+		},
+		{
+			name:     "renders preview forced by owner",
+			path:     "/forced",
+			code:     http.StatusOK,
+			contains: []string{"<title>Link preview</title>"},
+		},
+		{
+			name:     "redirects after continue",
+			path:     "/forced?continue=1",
+			code:     http.StatusTemporaryRedirect,
+			location: "http://example.com",
+		},
+		{
+			name:     "renders fallback URL of ended link",
+			path:     "/ended+",
+			code:     http.StatusOK,
+			contains: []string{"<code>http://fallback.example.com</code>"},
+		},
+		{
+			name: "responds with gone status instead of preview of deleted link",
+			path: "/deleted",
+			code: http.StatusGone,
+		},
+		{
+			name: "responds with gone status instead of preview of exhausted link",
+			path: "/exhausted+",
+			code: http.StatusGone,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, testServer.URL+tc.path, nil)
+			require.NoError(t, err)
+
+			transport := http.Transport{}
+			response, err := transport.RoundTrip(request)
+			require.NoError(t, err)
+			resBody, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			assert.Equal(t, tc.code, response.StatusCode)
+			for _, s := range tc.contains {
+				assert.Contains(t, string(resBody), s)
+			}
+			if tc.location != "" {
+				assert.Equal(t, tc.location, response.Header.Get("Location"))
+			}
+		})
+	}
+}
+
+func TestGetURLPreviewHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storageMock := mocks.NewMockStorage(ctrl)
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	activeFrom := time.Now().Add(time.Hour)
+	gomock.InOrder(
+		storageMock.EXPECT().
+			FindByShortenedPath(gomock.Any(), "", "123").
+			Return(models.Record{
+				OriginalURL:   "http://example.com",
+				ShortenedPath: "123",
+				Title:         "Example",
+				CreatedAt:     createdAt,
+			}, nil),
+		storageMock.EXPECT().
+			FindByShortenedPath(gomock.Any(), "", "123").
+			Return(models.Record{ShortenedPath: "123", IsDeleted: true}, nil),
+		storageMock.EXPECT().
+			FindByShortenedPath(gomock.Any(), "", "321").
+			Return(models.Record{}, storage.ErrNotFound),
+		storageMock.EXPECT().
+			FindByShortenedPath(gomock.Any(), "", "123").
+			Return(models.Record{ShortenedPath: "123", ActiveFrom: &activeFrom}, nil),
+	)
+
+	handler := handlers.NewHandlers(defaultConfig, storageMock)
+	router := chi.NewRouter()
+	router.Get("/api/urls/{id}", handler.GetURLPreview)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name string
+		path string
+		want want
+	}{
+		{
+			name: "responses with ok status",
+			path: "/api/urls/123",
+			want: want{
+				code: http.StatusOK,
+				response: `{"original_url":"http://example.com","short_url":"http://localhost:8080/123",` +
+					`"title":"Example","created_at":"2024-03-01T12:00:00Z","interstitial":false}` + "\n",
+			},
+		},
+		{
+			name: "responses with gone status if URL is deleted",
+			path: "/api/urls/123",
+			want: want{
+				code:     http.StatusGone,
+				response: toJSON(t, services.ErrLinkDeleted.Error()) + "\n",
+			},
+		},
+		{
+			name: "responses with not found status",
+			path: "/api/urls/321",
+			want: want{
+				code:     http.StatusNotFound,
+				response: toJSON(t, storage.ErrNotFound.Error()) + "\n",
+			},
+		},
+		{
+			name: "responses with not found status if URL is not active yet",
+			path: "/api/urls/123",
+			want: want{
+				code:     http.StatusNotFound,
+				response: toJSON(t, services.ErrLinkNotActive.Error()) + "\n",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			response, err := testServer.Client().Get(testServer.URL + tc.path)
+			require.NoError(t, err)
+			resBody, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			assert.Equal(t, tc.want.code, response.StatusCode)
+			assert.Equal(t, tc.want.response, string(resBody))
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+// QR code images depend only on shortened URL and options, so they are cached for a year
+const qrCacheControl = "public, max-age=31536000, immutable"
+
+// Get shortened URL QR code
+func (h Handlers) GetQRCode(w http.ResponseWriter, r *http.Request) {
+	shortenedPath := chi.URLParam(r, "id")
+	domain := h.config.DomainByHost(r.Host)
+	record, err := h.store.FindByShortenedPath(r.Context(), domain, shortenedPath)
+	if err == nil && record.IsDeleted {
+		err = storage.ErrNotFound
+	}
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, storage.ErrNotFound) {
+			code = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	opts, err := qrOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	img, err := services.RenderQR(h.config.ShortURL(record.Domain, record.ShortenedPath), opts)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidQROptions) {
+			code = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	sum := sha256.Sum256(img.Data)
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("Cache-Control", qrCacheControl)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(img.Data))
+}
+
+func qrOptionsFromQuery(query url.Values) (services.QROptions, error) {
+	opts := services.DefaultQROptions()
+	if format := query.Get("format"); format != "" {
+		opts.Format = format
+	}
+	if level := query.Get("level"); level != "" {
+		opts.Level = level
+	}
+	if size := query.Get("size"); size != "" {
+		var err error
+		if opts.Size, err = strconv.Atoi(size); err != nil {
+			return opts, fmt.Errorf("invalid size: %w", err)
+		}
+	}
+	if margin := query.Get("margin"); margin != "" {
+		var err error
+		if opts.Margin, err = strconv.Atoi(margin); err != nil {
+			return opts, fmt.Errorf("invalid margin: %w", err)
+		}
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,116 @@
+package handlers_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/handlers"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage/mocks"
+)
+
+func TestGetQRCodeHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storageMock := mocks.NewMockStorage(ctrl)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "123").
+		AnyTimes().
+		Return(models.Record{OriginalURL: "http://example.com", ShortenedPath: "123"}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "321").
+		AnyTimes().
+		Return(models.Record{}, storage.ErrNotFound)
+
+	handler := handlers.NewHandlers(defaultConfig, storageMock)
+	router := chi.NewRouter()
+	router.Get("/{id}/qr", handler.GetQRCode)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	svgOpts := services.DefaultQROptions()
+	svgOpts.Format = services.QRFormatSVG
+	svgOpts.Size = 512
+	svgOpts.Level = "H"
+	svgOpts.Margin = 2
+	svg, err := services.RenderQR(defaultConfig.BaseURL+"/123", svgOpts)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		path        string
+		code        int
+		contentType string
+		body        []byte
+	}{
+		{
+			name:        "responses with PNG by default",
+			path:        "/123/qr",
+			code:        http.StatusOK,
+			contentType: "image/png",
+		},
+		{
+			name:        "responses with SVG",
+			path:        "/123/qr?format=svg&size=512&level=H&margin=2",
+			code:        http.StatusOK,
+			contentType: "image/svg+xml",
+			body:        svg.Data,
+		},
+		{
+			name: "responses with bad request status if options are invalid",
+			path: "/123/qr?level=X",
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "responses with not found status",
+			path: "/321/qr",
+			code: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			response, err := testServer.Client().Get(testServer.URL + tc.path)
+			require.NoError(t, err)
+			resBody, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			assert.Equal(t, tc.code, response.StatusCode)
+			if tc.code != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tc.contentType, response.Header.Get("Content-Type"))
+			assert.Equal(t, "public, max-age=31536000, immutable", response.Header.Get("Cache-Control"))
+			assert.NotEmpty(t, response.Header.Get("ETag"))
+			if tc.body != nil {
+				assert.Equal(t, tc.body, resBody)
+			}
+		})
+	}
+
+	t.Run("responses with not modified status if ETag matches", func(t *testing.T) {
+		response, err := testServer.Client().Get(testServer.URL + "/123/qr")
+		require.NoError(t, err)
+		require.NoError(t, response.Body.Close())
+
+		request, err := http.NewRequest(http.MethodGet, testServer.URL+"/123/qr", nil)
+		require.NoError(t, err)
+		request.Header.Set("If-None-Match", response.Header.Get("ETag"))
+		response, err = testServer.Client().Do(request)
+		require.NoError(t, err)
+		require.NoError(t, response.Body.Close())
+		assert.Equal(t, http.StatusNotModified, response.StatusCode)
+	})
+}
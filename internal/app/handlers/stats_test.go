@@ -0,0 +1,91 @@
+package handlers_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/handlers"
+	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage/mocks"
+)
+
+func TestGetUserURLStatsHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storageMock := mocks.NewMockStorage(ctrl)
+	user := models.User{ID: 1}
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "1").
+		AnyTimes().
+		Return(models.Record{ShortenedPath: "1", UserID: user.ID}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "2").
+		AnyTimes().
+		Return(models.Record{ShortenedPath: "2", UserID: 2}, nil)
+	storageMock.EXPECT().
+		ClickStats(gomock.Any(), "", "1").
+		AnyTimes().
+		Return(models.ClickStats{Total: 3, ByVariant: map[string]int{"a": 1, "b": 2}}, nil)
+
+	userAuthenticator := new(userAuthenticatorMock)
+	userAuthenticator.On("Auth", mock.Anything).Return(user, nil)
+	handler := handlers.NewHandlers(defaultConfig, storageMock)
+	router := chi.NewRouter()
+	router.Use(middlewares.Authenticate(userAuthenticator))
+	router.Get("/api/user/urls/{id}/stats", handler.GetUserURLStats)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	authCookie := generateAuthCookie(t, user)
+	testCases := []struct {
+		name string
+		path string
+		want want
+	}{
+		{
+			name: "responses with ok status",
+			path: "/api/user/urls/1/stats",
+			want: want{
+				code:     http.StatusOK,
+				response: `{"clicks":3,"variants":{"a":1,"b":2}}` + "\n",
+			},
+		},
+		{
+			name: "responses with not found status if user does not own URL",
+			path: "/api/user/urls/2/stats",
+			want: want{
+				code:     http.StatusNotFound,
+				response: toJSON(t, storage.ErrNotFound.Error()) + "\n",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, testServer.URL+tc.path, nil)
+			require.NoError(t, err)
+			request.AddCookie(authCookie)
+
+			response, err := testServer.Client().Do(request)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			resBody, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want.code, response.StatusCode)
+			assert.Equal(t, tc.want.response, string(resBody))
+		})
+	}
+}
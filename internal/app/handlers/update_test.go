@@ -0,0 +1,184 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/handlers"
+	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage/mocks"
+)
+
+func TestUpdateUserURLHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storageMock := mocks.NewMockStorage(ctrl)
+	user := models.User{ID: 1}
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "1").
+		AnyTimes().
+		Return(models.Record{OriginalURL: "http://example.com", ShortenedPath: "1", UserID: user.ID}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "2").
+		AnyTimes().
+		Return(models.Record{OriginalURL: "http://example.com", ShortenedPath: "2", UserID: 2}, nil)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "3").
+		AnyTimes().
+		Return(models.Record{}, storage.ErrNotFound)
+	activeFrom := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	activeUntil := activeFrom.Add(24 * time.Hour)
+	storageMock.EXPECT().
+		FindByShortenedPath(gomock.Any(), "", "4").
+		AnyTimes().
+		Return(models.Record{
+			OriginalURL:   "http://example.com",
+			ShortenedPath: "4",
+			UserID:        user.ID,
+			ActiveFrom:    &activeFrom,
+			ActiveUntil:   &activeUntil,
+			FallbackURL:   "http://fallback.com",
+		}, nil)
+	storageMock.EXPECT().
+		Update(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(nil)
+
+	userAuthenticator := new(userAuthenticatorMock)
+	userAuthenticator.On("Auth", mock.Anything).Return(user, nil)
+	handler := handlers.NewHandlers(defaultConfig, storageMock)
+	router := chi.NewRouter()
+	router.Use(
+		middlewares.ResponseLogger,
+		middlewares.RequestLogger,
+		middlewares.GzipCompress,
+		middleware.AllowContentEncoding("gzip"),
+		middleware.AllowContentType("application/json", "application/x-gzip"),
+		middlewares.Authenticate(userAuthenticator),
+	)
+	router.Patch("/api/user/urls/{id}", handler.UpdateUserURL(services.NewURLUpdater(storageMock)))
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	authCookie := generateAuthCookie(t, user)
+	testCases := []struct {
+		name    string
+		path    string
+		reqBody string
+		want    want
+	}{
+		{
+			name: "responses with ok status",
+			path: "/api/user/urls/1",
+			reqBody: `{"active_from":"2024-01-01T00:00:00Z","active_until":"2024-02-01T00:00:00Z",` +
+				`"fallback_url":"http://fallback.com"}`,
+			want: want{
+				code: http.StatusOK,
+				response: toJSON(t, map[string]string{
+					"original_url": "http://example.com",
+					"short_url":    defaultConfig.BaseURL + "/1",
+					"active_from":  "2024-01-01T00:00:00Z",
+					"active_until": "2024-02-01T00:00:00Z",
+					"fallback_url": "http://fallback.com",
+				}) + "\n",
+			},
+		},
+		{
+			name:    "clears activity window bounds and fallback URL set to null",
+			path:    "/api/user/urls/4",
+			reqBody: `{"active_from":null,"fallback_url":null}`,
+			want: want{
+				code: http.StatusOK,
+				response: toJSON(t, map[string]string{
+					"original_url": "http://example.com",
+					"short_url":    defaultConfig.BaseURL + "/4",
+					"active_until": "2024-01-02T00:00:00Z",
+				}) + "\n",
+			},
+		},
+		{
+			name:    "responses with unprocessable entity status if activity window is invalid",
+			path:    "/api/user/urls/1",
+			reqBody: `{"active_from":"2024-02-01T00:00:00Z","active_until":"2024-01-01T00:00:00Z"}`,
+			want: want{
+				code:     http.StatusUnprocessableEntity,
+				response: toJSON(t, services.ErrInvalidActivityWindow.Error()) + "\n",
+			},
+		},
+		{
+			name:    "responses with unprocessable entity status if routing rule is invalid",
+			path:    "/api/user/urls/1",
+			reqBody: `{"rules":[{"field":"browser","pattern":"firefox","destination":"http://example.com"}]}`,
+			want: want{
+				code:     http.StatusUnprocessableEntity,
+				response: toJSON(t, services.ErrInvalidRoutingRule.Error()+` 0: unknown field "browser"`) + "\n",
+			},
+		},
+		{
+			name:    "responses with not found status if user does not own URL",
+			path:    "/api/user/urls/2",
+			reqBody: `{"fallback_url":"http://fallback.com"}`,
+			want: want{
+				code:     http.StatusNotFound,
+				response: toJSON(t, storage.ErrNotFound.Error()) + "\n",
+			},
+		},
+		{
+			name:    "responses with not found status if URL does not exist",
+			path:    "/api/user/urls/3",
+			reqBody: `{"fallback_url":"http://fallback.com"}`,
+			want: want{
+				code:     http.StatusNotFound,
+				response: toJSON(t, storage.ErrNotFound.Error()) + "\n",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(
+				http.MethodPatch,
+				testServer.URL+tc.path,
+				strings.NewReader(tc.reqBody),
+			)
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/json")
+			request.Header.Set("Accept-Encoding", "identity")
+			request.AddCookie(authCookie)
+
+			response, err := testServer.Client().Do(request)
+			require.NoError(t, err)
+			defer func() {
+				err = response.Body.Close()
+				require.NoError(t, err)
+			}()
+
+			resBody, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want.code, response.StatusCode)
+			if tc.want.code == http.StatusOK {
+				var body map[string]interface{}
+				require.NoError(t, json.Unmarshal(resBody, &body))
+				assert.NotEmpty(t, body["updated_at"])
+				delete(body, "updated_at")
+				assert.JSONEq(t, tc.want.response, toJSON(t, body))
+			} else {
+				assert.Equal(t, tc.want.response, string(resBody))
+			}
+		})
+	}
+}
@@ -1,39 +1,165 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 
 	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
+	"github.com/ilya-burinskiy/urlshort/internal/app/metrics"
 	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
 	"github.com/ilya-burinskiy/urlshort/internal/app/models"
 	"github.com/ilya-burinskiy/urlshort/internal/app/services"
 	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
 )
 
-// Get original URL
+// Get original URL. HEAD requests are answered the same way but do not count as clicks.
+// Renders interstitial page if preview is requested or forced by the owner, for
+// links which can be followed only
 func (h Handlers) GetOriginalURL(w http.ResponseWriter, r *http.Request) {
-	shortenedPath := chi.URLParam(r, "id")
-	record, err := h.store.FindByShortenedPath(context.Background(), shortenedPath)
+	shortenedPath, preview := previewRequest(r)
+	domain := h.config.DomainByHost(r.Host)
+	record, err := h.store.FindByShortenedPath(r.Context(), domain, shortenedPath)
 	if errors.Is(err, storage.ErrNotFound) {
-		http.Error(w, fmt.Sprintf("Original URL for \"%v\" not found", shortenedPath), http.StatusBadRequest)
+		metrics.LinkNotFound(metrics.TransportHTTP)
+		http.Error(w, fmt.Sprintf("Original URL for \"%v\" not found", shortenedPath), h.config.NotFoundCode)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if record.IsDeleted {
-		w.WriteHeader(http.StatusGone)
+	now := time.Now()
+	target, err := services.LinkTarget(record, now)
+	if err != nil {
+		h.writeLinkError(w, err)
+		return
+	}
+	if preview || (record.Interstitial && r.URL.Query().Get(continueParam) != "1") {
+		h.renderPreview(w, r, record, target)
 		return
 	}
+	if record.ActivityAt(now) == models.Ended {
+		setCacheHeaders(w, services.RedirectCacheHeaders(record, http.StatusTemporaryRedirect, now))
+		http.RedirectHandler(target, http.StatusTemporaryRedirect).
+			ServeHTTP(w, r)
+		metrics.RedirectServed(metrics.TransportHTTP)
+		return
+	}
+
+	isClick := r.Method != http.MethodHead
+	if record.IsClickLimited() && isClick {
+		_, err = h.store.ConsumeClick(r.Context(), domain, shortenedPath)
+		if errors.Is(err, storage.ErrClicksExhausted) {
+			h.writeLinkError(w, err)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	destination := services.ResolveDestination(record, services.Visitor{
+		UserAgent:      r.UserAgent(),
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+		Country:        r.Header.Get(h.config.CountryHeader),
+		Variant:        getVariant(r, shortenedPath),
+	})
+	if isClick {
+		if destination.Variant != "" {
+			setVariantCookie(w, shortenedPath, destination.Variant)
+		}
+		h.saveClick(r.Context(), models.Click{
+			Domain:        domain,
+			ShortenedPath: shortenedPath,
+			Variant:       destination.Variant,
+			CreatedAt:     now,
+		})
+	}
 
-	http.RedirectHandler(record.OriginalURL, http.StatusTemporaryRedirect).
+	redirectURL, err := services.BuildRedirectURL(destination.URL, record, redirectQuery(r), h.config.QueryMode)
+	if err != nil {
+		logger.Log.Info("failed to build redirect URL", zap.Error(err))
+		redirectURL = destination.URL
+	}
+
+	code := services.RedirectCode(record, h.config.RedirectCode)
+	setCacheHeaders(w, services.RedirectCacheHeaders(record, code, now))
+	http.RedirectHandler(redirectURL, code).
 		ServeHTTP(w, r)
+	metrics.RedirectServed(metrics.TransportHTTP)
+}
+
+// Respond with status of link which can not be followed
+func (h Handlers) writeLinkError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrLinkNotActive):
+		http.Error(w, h.config.NotActiveMessage, linkErrorStatus(err))
+	case errors.Is(err, services.ErrLinkEnded):
+		http.Error(w, h.config.EndedMessage, linkErrorStatus(err))
+	default:
+		w.WriteHeader(linkErrorStatus(err))
+	}
+}
+
+// HTTP status of link which can not be followed
+func linkErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrNotFound), errors.Is(err, services.ErrLinkNotActive):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrLinkDeleted),
+		errors.Is(err, services.ErrLinkEnded),
+		errors.Is(err, storage.ErrClicksExhausted):
+		return http.StatusGone
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Get user shortened URL click statistics
+func (h Handlers) GetUserURLStats(w http.ResponseWriter, r *http.Request) {
+	h.getUserURLStats(w, r, writeJSONError)
+}
+
+func (h Handlers) getUserURLStats(w http.ResponseWriter, r *http.Request, writeError errorWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, _ := middlewares.UserIDFromContext(r.Context())
+	shortenedPath := chi.URLParam(r, "id")
+	domain, err := h.queryDomain(r)
+	var record models.Record
+	if err == nil {
+		record, err = h.store.FindByShortenedPath(r.Context(), domain, shortenedPath)
+	}
+	if err == nil && record.UserID != userID {
+		err = storage.ErrNotFound
+	}
+	if err == nil {
+		var stats models.ClickStats
+		stats, err = h.store.ClickStats(r.Context(), domain, shortenedPath)
+		if err == nil {
+			if err = json.NewEncoder(w).Encode(stats); err != nil {
+				logger.Log.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+	}
+
+	code := http.StatusInternalServerError
+	if errors.Is(err, storage.ErrNotFound) {
+		code = http.StatusNotFound
+	}
+	writeError(w, r, code, err)
 }
 
 // Create shorened URL
@@ -55,14 +181,24 @@ func (h Handlers) CreateURL(
 			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 			return
 		}
-		originalURL := string(bytes)
+		record, err := recordFromQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		record.OriginalURL = string(bytes)
+		if record.Domain, err = h.normalizeDomain(record.Domain); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
 
-		record, err := shortener.Shortify(originalURL, user)
+		record, err = shortener.Shortify(r.Context(), record, user)
 		if err != nil {
 			var notUniqErr *storage.ErrNotUnique
 			if errors.As(err, &notUniqErr) {
 				w.WriteHeader(http.StatusConflict)
-				if _, err = w.Write([]byte(h.config.BaseURL + "/" + notUniqErr.Record.ShortenedPath)); err != nil {
+				shortURL := h.config.ShortURL(notUniqErr.Record.Domain, notUniqErr.Record.ShortenedPath)
+				if _, err = w.Write([]byte(shortURL)); err != nil {
 					logger.Log.Info("failed to write response", zap.Error(err))
 				}
 				return
@@ -72,12 +208,17 @@ func (h Handlers) CreateURL(
 		}
 
 		w.WriteHeader(http.StatusCreated)
-		if _, err = w.Write([]byte(h.config.BaseURL + "/" + record.ShortenedPath)); err != nil {
+		if _, err = w.Write([]byte(h.config.ShortURL(record.Domain, record.ShortenedPath))); err != nil {
 			logger.Log.Info("failed to write response", zap.Error(err))
 		}
 	}
 }
 
+// Shortened URL of legacy API
+type shortenResponse struct {
+	Result string `json:"result"`
+}
+
 // Create shortened URL from JSON
 func (h Handlers) CreateURLFromJSON(
 	shortener services.URLShortener,
@@ -85,7 +226,7 @@ func (h Handlers) CreateURLFromJSON(
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		var requestBody map[string]string
+		var requestBody createURLRequest
 		encoder := json.NewEncoder(w)
 		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
 			w.WriteHeader(http.StatusUnprocessableEntity)
@@ -94,6 +235,14 @@ func (h Handlers) CreateURLFromJSON(
 			}
 			return
 		}
+		domain, err := h.normalizeDomain(requestBody.Domain)
+		if err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			if err = encoder.Encode(err.Error()); err != nil {
+				logger.Log.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
 
 		jwtStr := getJWT(r)
 		user, jwtStr, err := userAuthenticator.AuthOrRegister(r.Context(), jwtStr)
@@ -103,15 +252,13 @@ func (h Handlers) CreateURLFromJSON(
 		}
 		setJWTCookie(w, jwtStr)
 
-		originalURL := requestBody["url"]
-		record, err := shortener.Shortify(originalURL, user)
+		record, err := shortener.Shortify(r.Context(), requestBody.record(domain), user)
 		if err != nil {
 			var notUniqErr *storage.ErrNotUnique
 			if errors.As(err, &notUniqErr) {
 				w.WriteHeader(http.StatusConflict)
 				err = encoder.Encode(
-					map[string]string{"result": h.config.BaseURL + "/" +
-						notUniqErr.Record.ShortenedPath},
+					shortenResponse{Result: h.config.ShortURL(notUniqErr.Record.Domain, notUniqErr.Record.ShortenedPath)},
 				)
 				if err != nil {
 					logger.Log.Info("failed to encode response", zap.Error(err))
@@ -126,7 +273,7 @@ func (h Handlers) CreateURLFromJSON(
 		}
 
 		w.WriteHeader(http.StatusCreated)
-		if err = encoder.Encode(map[string]string{"result": h.config.BaseURL + "/" + record.ShortenedPath}); err != nil {
+		if err = encoder.Encode(shortenResponse{Result: h.config.ShortURL(record.Domain, record.ShortenedPath)}); err != nil {
 			logger.Log.Info("failed to encode response", zap.Error(err))
 		}
 	}
@@ -149,6 +296,15 @@ func (h Handlers) BatchCreateURL(
 			}
 			return
 		}
+		for i := range records {
+			if records[i].Domain, err = h.normalizeDomain(records[i].Domain); err != nil {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				if err = encoder.Encode(err.Error()); err != nil {
+					logger.Log.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			}
+		}
 
 		jwtStr := getJWT(r)
 		user, jwtStr, err := userAuthenticator.AuthOrRegister(r.Context(), jwtStr)
@@ -158,7 +314,15 @@ func (h Handlers) BatchCreateURL(
 		}
 		setJWTCookie(w, jwtStr)
 
-		savedRecords, err := shortener.BatchShortify(records, user)
+		savedRecords, err := shortener.BatchShortify(r.Context(), records, user)
+		var notUniqErr *storage.ErrNotUnique
+		if errors.As(err, &notUniqErr) {
+			w.WriteHeader(http.StatusConflict)
+			if err = encoder.Encode("original URL is already shortened"); err != nil {
+				logger.Log.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
 		if err != nil {
 			w.WriteHeader(http.StatusUnprocessableEntity)
 			if err = encoder.Encode(err.Error()); err != nil {
@@ -167,31 +331,43 @@ func (h Handlers) BatchCreateURL(
 			return
 		}
 
-		type responseItem struct {
-			CorrelationID string `json:"correlation_id"`
-			ShortURL      string `json:"short_url"`
-		}
-		response := make([]responseItem, len(savedRecords))
-		for i := range records {
-			response[i] = responseItem{
-				CorrelationID: savedRecords[i].CorrelationID,
-				ShortURL:      h.config.BaseURL + "/" + savedRecords[i].ShortenedPath,
-			}
-		}
 		w.WriteHeader(http.StatusCreated)
-		if err = encoder.Encode(response); err != nil {
+		if err = encoder.Encode(h.batchCreateResponse(savedRecords)); err != nil {
 			logger.Log.Info("failed to encode response", zap.Error(err))
 		}
 	}
 }
 
-// Get user shortened URLs
+// Get user shortened URLs. Filtered by tag, folder, substring q of original URL or
+// shortened path and created_from, created_to range if given. If any of limit,
+// cursor, sort or order is given responds with a page of sorted URLs and cursor
+// of the next page
 func (h Handlers) GetUserURLs(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	filter, err := filterFromQuery(r.URL.Query())
+	var page models.Page
+	var paginated bool
+	if err == nil {
+		page, paginated, err = pageFromQuery(r.URL.Query())
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		if err = encoder.Encode(err.Error()); err != nil {
+			logger.Log.Info("failed to encode response", zap.Error(err))
+		}
+		return
+	}
+
 	userID, _ := middlewares.UserIDFromContext(r.Context())
 	user := models.User{ID: userID}
-	records, err := h.store.FindByUser(r.Context(), user)
-	encoder := json.NewEncoder(w)
+	var records []models.Record
+	var next *models.Cursor
+	if paginated {
+		records, next, err = h.store.FindByUserPage(r.Context(), user, filter, page)
+	} else {
+		records, err = h.store.FindByUser(r.Context(), user, filter)
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusUnprocessableEntity)
 		if err = encoder.Encode(fmt.Sprintf("failed to fetch records: %s", err.Error())); err != nil {
@@ -200,20 +376,17 @@ func (h Handlers) GetUserURLs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(records) == 0 {
+	if len(records) == 0 && !paginated {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	type responseItem struct {
-		OriginalURL string `json:"original_url"`
-		ShortURL    string `json:"short_url"`
-	}
-	response := make([]responseItem, len(records))
-	for i := range records {
-		response[i] = responseItem{
-			OriginalURL: records[i].OriginalURL,
-			ShortURL:    h.config.BaseURL + "/" + records[i].ShortenedPath,
+	items := h.userURLs(records)
+	var response interface{} = items
+	if paginated {
+		response = userURLsPage{
+			Items:      items,
+			NextCursor: services.EncodeCursor(page, next),
 		}
 	}
 	if err = encoder.Encode(response); err != nil {
@@ -221,6 +394,41 @@ func (h Handlers) GetUserURLs(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Update user shortened URL settings
+func (h Handlers) UpdateUserURL(urlUpdater services.URLUpdater) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		var patch services.RecordPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			if err = encoder.Encode("invalid request body"); err != nil {
+				logger.Log.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		shortenedPath := chi.URLParam(r, "id")
+		domain, err := h.queryDomain(r)
+		var record models.Record
+		if err == nil {
+			record, err = urlUpdater.Update(r.Context(), domain, shortenedPath, patch, models.User{ID: userID})
+		}
+		if err != nil {
+			w.WriteHeader(updateErrorStatus(err))
+			if err = encoder.Encode(err.Error()); err != nil {
+				logger.Log.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		if err = encoder.Encode(h.updatedURL(record)); err != nil {
+			logger.Log.Info("failed to encode response", zap.Error(err))
+		}
+	}
+}
+
 // Delete user shortened URLs
 func (h Handlers) DeleteUserURLs(urlDeleter services.DeferredDeleter) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -234,10 +442,19 @@ func (h Handlers) DeleteUserURLs(urlDeleter services.DeferredDeleter) func(http.
 			}
 			return
 		}
+		domain, err := h.normalizeDomain(r.URL.Query().Get("domain"))
+		if err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			if err = encoder.Encode(err.Error()); err != nil {
+				logger.Log.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
 
 		userID, _ := middlewares.UserIDFromContext(r.Context())
 		for _, shortPath := range shortPaths {
 			urlDeleter.Enqueue(models.Record{
+				Domain:        domain,
 				ShortenedPath: shortPath,
 				UserID:        userID,
 			})
@@ -246,3 +463,299 @@ func (h Handlers) DeleteUserURLs(urlDeleter services.DeferredDeleter) func(http.
 		w.WriteHeader(http.StatusAccepted)
 	}
 }
+
+func recordFromQuery(query url.Values) (models.Record, error) {
+	var record models.Record
+	var err error
+	if maxClicks := query.Get("max_clicks"); maxClicks != "" {
+		if record.MaxClicks, err = strconv.Atoi(maxClicks); err != nil {
+			return record, &fieldError{field: "max_clicks", err: err}
+		}
+	}
+	if activeFrom := query.Get("active_from"); activeFrom != "" {
+		t, err := time.Parse(time.RFC3339, activeFrom)
+		if err != nil {
+			return record, &fieldError{field: "active_from", err: err}
+		}
+		record.ActiveFrom = &t
+	}
+	if activeUntil := query.Get("active_until"); activeUntil != "" {
+		t, err := time.Parse(time.RFC3339, activeUntil)
+		if err != nil {
+			return record, &fieldError{field: "active_until", err: err}
+		}
+		record.ActiveUntil = &t
+	}
+	record.FallbackURL = query.Get("fallback_url")
+	record.QueryMode = query.Get("query_mode")
+	record.Domain = query.Get("domain")
+	record.Title = query.Get("title")
+	record.Description = query.Get("description")
+	record.Folder = query.Get("folder")
+	if tags := query.Get("tags"); tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			record.Tags = append(record.Tags, strings.TrimSpace(tag))
+		}
+	}
+	if interstitial := query.Get("interstitial"); interstitial != "" {
+		if record.Interstitial, err = strconv.ParseBool(interstitial); err != nil {
+			return record, &fieldError{field: "interstitial", err: err}
+		}
+	}
+	if redirectCode := query.Get("redirect_code"); redirectCode != "" {
+		if record.RedirectCode, err = strconv.Atoi(redirectCode); err != nil {
+			return record, &fieldError{field: "redirect_code", err: err}
+		}
+	}
+	for key := range query {
+		if strings.HasPrefix(key, "utm_") {
+			if record.UTMParams == nil {
+				record.UTMParams = make(map[string]string)
+			}
+			record.UTMParams[key] = query.Get(key)
+		}
+	}
+
+	return record, nil
+}
+
+func filterFromQuery(query url.Values) (models.RecordFilter, error) {
+	filter := models.RecordFilter{
+		Tags:   query["tag"],
+		Folder: query.Get("folder"),
+		Query:  query.Get("q"),
+	}
+	if createdFrom := query.Get("created_from"); createdFrom != "" {
+		t, err := time.Parse(time.RFC3339, createdFrom)
+		if err != nil {
+			return filter, &fieldError{field: "created_from", err: err}
+		}
+		filter.CreatedFrom = &t
+	}
+	if createdTo := query.Get("created_to"); createdTo != "" {
+		t, err := time.Parse(time.RFC3339, createdTo)
+		if err != nil {
+			return filter, &fieldError{field: "created_to", err: err}
+		}
+		filter.CreatedTo = &t
+	}
+
+	return filter, nil
+}
+
+func pageFromQuery(query url.Values) (models.Page, bool, error) {
+	if !query.Has("limit") && !query.Has("cursor") && !query.Has("sort") && !query.Has("order") {
+		return models.Page{}, false, nil
+	}
+
+	var limit int
+	if l := query.Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil {
+			return models.Page{}, true, &fieldError{field: "limit", err: err}
+		}
+	}
+	page, err := services.NewPage(limit, query.Get("cursor"), query.Get("sort"), query.Get("order"))
+
+	return page, true, err
+}
+
+// Create shortened URL request body
+type createURLRequest struct {
+	URL          string               `json:"url"`
+	MaxClicks    int                  `json:"max_clicks"`
+	ActiveFrom   *time.Time           `json:"active_from"`
+	ActiveUntil  *time.Time           `json:"active_until"`
+	FallbackURL  string               `json:"fallback_url"`
+	Rules        []models.RoutingRule `json:"rules"`
+	Variants     []models.Variant     `json:"variants"`
+	UTMParams    map[string]string    `json:"utm_params"`
+	QueryMode    string               `json:"query_mode"`
+	RedirectCode int                  `json:"redirect_code"`
+	Title        string               `json:"title"`
+	Description  string               `json:"description"`
+	Interstitial bool                 `json:"interstitial"`
+	Domain       string               `json:"domain"`
+	Tags         []string             `json:"tags"`
+	Folder       string               `json:"folder"`
+}
+
+func (req createURLRequest) record(domain string) models.Record {
+	return models.Record{
+		OriginalURL:  req.URL,
+		MaxClicks:    req.MaxClicks,
+		ActiveFrom:   req.ActiveFrom,
+		ActiveUntil:  req.ActiveUntil,
+		FallbackURL:  req.FallbackURL,
+		Rules:        req.Rules,
+		Variants:     req.Variants,
+		UTMParams:    req.UTMParams,
+		QueryMode:    req.QueryMode,
+		RedirectCode: req.RedirectCode,
+		Title:        req.Title,
+		Description:  req.Description,
+		Interstitial: req.Interstitial,
+		Domain:       domain,
+		Tags:         req.Tags,
+		Folder:       req.Folder,
+	}
+}
+
+// Batch create response item
+type batchCreateResponseItem struct {
+	CorrelationID string `json:"correlation_id"`
+	ShortURL      string `json:"short_url"`
+}
+
+func (h Handlers) batchCreateResponse(records []models.Record) []batchCreateResponseItem {
+	response := make([]batchCreateResponseItem, len(records))
+	for i := range records {
+		response[i] = batchCreateResponseItem{
+			CorrelationID: records[i].CorrelationID,
+			ShortURL:      h.config.ShortURL(records[i].Domain, records[i].ShortenedPath),
+		}
+	}
+
+	return response
+}
+
+// User shortened URL listing item
+type userURL struct {
+	OriginalURL string               `json:"original_url"`
+	ShortURL    string               `json:"short_url"`
+	Rules       []models.RoutingRule `json:"rules,omitempty"`
+	Variants    []models.Variant     `json:"variants,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Folder      string               `json:"folder,omitempty"`
+	Title       string               `json:"title,omitempty"`
+	Description string               `json:"description,omitempty"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+// Page of user shortened URLs
+type userURLsPage struct {
+	Items      []userURL `json:"items"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+func (h Handlers) userURLs(records []models.Record) []userURL {
+	items := make([]userURL, len(records))
+	for i := range records {
+		items[i] = userURL{
+			OriginalURL: records[i].OriginalURL,
+			ShortURL:    h.config.ShortURL(records[i].Domain, records[i].ShortenedPath),
+			Rules:       records[i].Rules,
+			Variants:    records[i].Variants,
+			Tags:        records[i].Tags,
+			Folder:      records[i].Folder,
+			Title:       records[i].Title,
+			Description: records[i].Description,
+			CreatedAt:   records[i].CreatedAt,
+			UpdatedAt:   records[i].UpdatedAt,
+		}
+	}
+
+	return items
+}
+
+// Updated user shortened URL
+type updatedURL struct {
+	OriginalURL  string               `json:"original_url"`
+	ShortURL     string               `json:"short_url"`
+	ActiveFrom   *time.Time           `json:"active_from,omitempty"`
+	ActiveUntil  *time.Time           `json:"active_until,omitempty"`
+	FallbackURL  string               `json:"fallback_url,omitempty"`
+	Rules        []models.RoutingRule `json:"rules,omitempty"`
+	Variants     []models.Variant     `json:"variants,omitempty"`
+	UTMParams    map[string]string    `json:"utm_params,omitempty"`
+	QueryMode    string               `json:"query_mode,omitempty"`
+	RedirectCode int                  `json:"redirect_code,omitempty"`
+	Title        string               `json:"title,omitempty"`
+	Description  string               `json:"description,omitempty"`
+	Interstitial bool                 `json:"interstitial,omitempty"`
+	Tags         []string             `json:"tags,omitempty"`
+	Folder       string               `json:"folder,omitempty"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+}
+
+func (h Handlers) updatedURL(record models.Record) updatedURL {
+	return updatedURL{
+		OriginalURL:  record.OriginalURL,
+		ShortURL:     h.config.ShortURL(record.Domain, record.ShortenedPath),
+		ActiveFrom:   record.ActiveFrom,
+		ActiveUntil:  record.ActiveUntil,
+		FallbackURL:  record.FallbackURL,
+		Rules:        record.Rules,
+		Variants:     record.Variants,
+		UTMParams:    record.UTMParams,
+		QueryMode:    record.QueryMode,
+		RedirectCode: record.RedirectCode,
+		Title:        record.Title,
+		Description:  record.Description,
+		Interstitial: record.Interstitial,
+		Tags:         record.Tags,
+		Folder:       record.Folder,
+		UpdatedAt:    record.UpdatedAt,
+	}
+}
+
+func updateErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound
+	case validationField(err) != "":
+		return http.StatusUnprocessableEntity
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Invalid request parameter error
+type fieldError struct {
+	field string
+	err   error
+}
+
+func (e *fieldError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.field, e.err.Error())
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.err
+}
+
+// Request fields of settings validation errors
+var validationFields = []struct {
+	err   error
+	field string
+}{
+	{services.ErrInvalidMaxClicks, "max_clicks"},
+	{services.ErrInvalidActivityWindow, "active_until"},
+	{services.ErrInvalidTitle, "title"},
+	{services.ErrInvalidDescription, "description"},
+	{services.ErrInvalidRoutingRule, "rules"},
+	{services.ErrInvalidVariant, "variants"},
+	{services.ErrInvalidQueryMode, "query_mode"},
+	{services.ErrInvalidUTMParams, "utm_params"},
+	{services.ErrInvalidRedirectCode, "redirect_code"},
+	{services.ErrInvalidTags, "tags"},
+	{services.ErrInvalidFolder, "folder"},
+	{services.ErrInvalidExportFormat, "format"},
+	{errUnknownDomain, "domain"},
+}
+
+// Request field of validation error, empty if err is not a validation error
+func validationField(err error) string {
+	var fieldErr *fieldError
+	if errors.As(err, &fieldErr) {
+		return fieldErr.field
+	}
+	for _, vf := range validationFields {
+		if errors.Is(err, vf.err) {
+			return vf.field
+		}
+	}
+
+	return ""
+}
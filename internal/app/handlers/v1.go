@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/apierror"
+	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
+	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+// Versioned JSON API. Errors are sent as apierror.Error with status 400 for
+// malformed request, 401 for missing or invalid JWT, 403 for untrusted client,
+// 404 for unknown link, 409 for already shortened URL, 413 for too large upload,
+// 422 for invalid link settings and 500 for internal error
+type V1 struct {
+	Handlers
+}
+
+// New versioned API handlers
+func NewV1(h Handlers) V1 {
+	return V1{Handlers: h}
+}
+
+// Shortened URL
+type shortURLResponse struct {
+	ShortURL string `json:"short_url"`
+}
+
+// Create shortened URL from JSON, responds with 201
+func (v V1) CreateURL(
+	shortener services.URLShortener,
+	userAuthenticator services.UserAuthenticator) func(http.ResponseWriter, *http.Request) {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var requestBody createURLRequest
+		if err := decodeJSON(r.Body, &requestBody); err != nil {
+			writeAPIError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := validateOriginalURL("url", requestBody.URL); err != nil {
+			writeAPIError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		domain, err := v.normalizeDomain(requestBody.Domain)
+		if err != nil {
+			writeAPIError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		jwtStr := getJWT(r)
+		user, jwtStr, err := userAuthenticator.AuthOrRegister(r.Context(), jwtStr)
+		if err != nil {
+			writeAPIError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		setJWTCookie(w, jwtStr)
+
+		record, err := shortener.Shortify(r.Context(), requestBody.record(domain), user)
+		if err != nil {
+			v.writeShortifyError(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, shortURLResponse{ShortURL: v.config.ShortURL(record.Domain, record.ShortenedPath)})
+	}
+}
+
+// Create multiple shortened URLs, responds with 201. Invalid item fields are
+// reported by item index
+func (v V1) BatchCreateURL(
+	shortener services.URLShortener,
+	userAuthenticator services.UserAuthenticator) func(http.ResponseWriter, *http.Request) {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		records := make([]models.Record, 0)
+		if err := decodeJSON(r.Body, &records); err != nil {
+			writeAPIError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		for i := range records {
+			err := validateOriginalURL(fmt.Sprintf("%d.original_url", i), records[i].OriginalURL)
+			if err == nil {
+				if records[i].Domain, err = v.normalizeDomain(records[i].Domain); err != nil {
+					err = &fieldError{field: fmt.Sprintf("%d.domain", i), err: err}
+				}
+			}
+			if err != nil {
+				writeAPIError(w, r, http.StatusUnprocessableEntity, err)
+				return
+			}
+		}
+
+		jwtStr := getJWT(r)
+		user, jwtStr, err := userAuthenticator.AuthOrRegister(r.Context(), jwtStr)
+		if err != nil {
+			writeAPIError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		setJWTCookie(w, jwtStr)
+
+		savedRecords, err := shortener.BatchShortify(r.Context(), records, user)
+		if err != nil {
+			v.writeShortifyError(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, v.batchCreateResponse(savedRecords))
+	}
+}
+
+// Get shortened URL preview
+func (v V1) GetURLPreview(w http.ResponseWriter, r *http.Request) {
+	v.getURLPreview(w, r, writeAPIError)
+}
+
+// Get user shortened URLs. Responds with a page even if nothing is found, the
+// page is the last one if next cursor is empty. Query parameters are the same
+// as of legacy listing, URLs are not paginated unless limit, cursor, sort or
+// order is given
+func (v V1) GetUserURLs(w http.ResponseWriter, r *http.Request) {
+	filter, err := filterFromQuery(r.URL.Query())
+	var page models.Page
+	var paginated bool
+	if err == nil {
+		page, paginated, err = pageFromQuery(r.URL.Query())
+	}
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, _ := middlewares.UserIDFromContext(r.Context())
+	user := models.User{ID: userID}
+	var records []models.Record
+	var next *models.Cursor
+	if paginated {
+		records, next, err = v.store.FindByUserPage(r.Context(), user, filter, page)
+	} else {
+		records, err = v.store.FindByUser(r.Context(), user, filter)
+	}
+	if err != nil {
+		writeAPIError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := userURLsPage{Items: v.userURLs(records)}
+	if paginated {
+		response.NextCursor = services.EncodeCursor(page, next)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Update user shortened URL settings
+func (v V1) UpdateUserURL(urlUpdater services.URLUpdater) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var patch services.RecordPatch
+		if err := decodeJSON(r.Body, &patch); err != nil {
+			writeAPIError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		domain, err := v.queryDomain(r)
+		var record models.Record
+		if err == nil {
+			record, err = urlUpdater.Update(r.Context(), domain, chi.URLParam(r, "id"), patch, models.User{ID: userID})
+		}
+		if err != nil {
+			writeAPIError(w, r, updateErrorStatus(err), err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, v.updatedURL(record))
+	}
+}
+
+// Get user shortened URL click statistics
+func (v V1) GetUserURLStats(w http.ResponseWriter, r *http.Request) {
+	v.getUserURLStats(w, r, writeAPIError)
+}
+
+// Delete user shortened URLs in background, responds with 202
+func (v V1) DeleteUserURLs(urlDeleter services.DeferredDeleter) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var shortPaths []string
+		if err := decodeJSON(r.Body, &shortPaths); err != nil {
+			writeAPIError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		domain, err := v.normalizeDomain(r.URL.Query().Get("domain"))
+		if err != nil {
+			writeAPIError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		for _, shortPath := range shortPaths {
+			urlDeleter.Enqueue(models.Record{
+				Domain:        domain,
+				ShortenedPath: shortPath,
+				UserID:        userID,
+			})
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// Export user shortened URLs with click counts
+func (v V1) ExportUserURLs(exporter services.URLExporter) func(http.ResponseWriter, *http.Request) {
+	return v.exportUserURLs(exporter, writeAPIError)
+}
+
+// Import user shortened URLs from CSV
+func (v V1) ImportURLs(
+	importer services.URLImporter,
+	importJobs *services.ImportJobs) func(http.ResponseWriter, *http.Request) {
+
+	return v.importURLs(importer, importJobs, "/api/v1/user/import/", writeAPIError)
+}
+
+// Get user import job status
+func (v V1) GetImportJob(importJobs *services.ImportJobs) func(http.ResponseWriter, *http.Request) {
+	return v.getImportJob(importJobs, writeAPIError)
+}
+
+// Get URLs and users count
+func (v V1) GetStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := v.stats(r)
+	if err != nil {
+		writeAPIError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// Unknown route of versioned API
+func (v V1) NotFound(w http.ResponseWriter, r *http.Request) {
+	apierror.Write(w, r, apierror.New(http.StatusNotFound, "route not found"))
+}
+
+// Known route requested with unsupported method
+func (v V1) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	apierror.Write(w, r, apierror.New(http.StatusMethodNotAllowed, "method not allowed"))
+}
+
+func (v V1) writeShortifyError(w http.ResponseWriter, r *http.Request, err error) {
+	var notUniqErr *storage.ErrNotUnique
+	if errors.As(err, &notUniqErr) {
+		shortURL := v.config.ShortURL(notUniqErr.Record.Domain, notUniqErr.Record.ShortenedPath)
+		apierror.Write(
+			w, r,
+			apierror.New(http.StatusConflict, "original URL is already shortened").WithDetail("short_url", shortURL),
+		)
+		return
+	}
+	if validationField(err) != "" {
+		writeAPIError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeAPIError(w, r, http.StatusInternalServerError, err)
+}
+
+// Write error envelope. Validation errors are detailed by field, internal
+// errors are logged and not disclosed
+func writeAPIError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if status == http.StatusInternalServerError {
+		logger.Log.Info(
+			"internal error",
+			zap.String("request_id", middleware.GetReqID(r.Context())),
+			zap.Error(err),
+		)
+		apierror.Write(w, r, apierror.New(status, "internal error"))
+		return
+	}
+
+	apiErr := apierror.New(status, err.Error())
+	if field := validationField(err); field != "" {
+		message := err.Error()
+		var fieldErr *fieldError
+		if errors.As(err, &fieldErr) {
+			message = fieldErr.err.Error()
+		}
+		apiErr.WithDetail(field, message)
+	}
+	apierror.Write(w, r, apiErr)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Log.Info("failed to encode response", zap.Error(err))
+	}
+}
+
+// Decode JSON request body. Values of wrong type are reported by field
+func decodeJSON(body io.Reader, v interface{}) error {
+	err := json.NewDecoder(body).Decode(v)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &fieldError{field: typeErr.Field, err: fmt.Errorf("must be %s", jsonTypeName(typeErr.Type))}
+	}
+	if err != nil {
+		return fmt.Errorf("malformed request body: %w", err)
+	}
+
+	return nil
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	}
+
+	return t.String()
+}
+
+// Original URL must be absolute HTTP(S) URL
+func validateOriginalURL(field, originalURL string) error {
+	if originalURL == "" {
+		return &fieldError{field: field, err: errors.New("must not be empty")}
+	}
+	u, err := url.Parse(originalURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &fieldError{field: field, err: errors.New("must be absolute http or https URL")}
+	}
+
+	return nil
+}
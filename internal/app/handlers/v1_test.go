@@ -0,0 +1,207 @@
+package handlers_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/handlers"
+	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+func TestV1CreateURL(t *testing.T) {
+	store := storage.NewMapStorage(nil)
+	shortener := new(urlShortenerMock)
+	userAuthenticator := new(userAuthenticatorMock)
+	userAuthenticator.On("AuthOrRegister", mock.Anything, mock.Anything).Return(models.User{ID: 1}, "123", nil)
+	v1 := handlers.NewV1(handlers.NewHandlers(defaultConfig, store))
+	router := chi.NewRouter()
+	router.Post("/api/v1/urls", v1.CreateURL(shortener, userAuthenticator))
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name        string
+		requestBody string
+		shortifyRes []interface{}
+		want        want
+	}{
+		{
+			name:        "responds with created status",
+			requestBody: `{"url":"http://example.com"}`,
+			shortifyRes: []interface{}{models.Record{ShortenedPath: "123"}, nil},
+			want: want{
+				code:     http.StatusCreated,
+				response: `{"short_url":"http://localhost:8080/123"}`,
+			},
+		},
+		{
+			name:        "responds with bad request status if body is malformed",
+			requestBody: `{"url":`,
+			want: want{
+				code:     http.StatusBadRequest,
+				response: `{"code":"invalid_request","message":"malformed request body: unexpected EOF"}`,
+			},
+		},
+		{
+			name:        "responds with bad request status if field has wrong type",
+			requestBody: `{"url":"http://example.com","max_clicks":"10"}`,
+			want: want{
+				code: http.StatusBadRequest,
+				response: `{"code":"invalid_request","message":"invalid max_clicks: must be integer",` +
+					`"details":{"max_clicks":"must be integer"}}`,
+			},
+		},
+		{
+			name:        "responds with unprocessable entity status if url is not absolute",
+			requestBody: `{"url":"example.com"}`,
+			want: want{
+				code: http.StatusUnprocessableEntity,
+				response: `{"code":"validation_failed","message":"invalid url: must be absolute http or https URL",` +
+					`"details":{"url":"must be absolute http or https URL"}}`,
+			},
+		},
+		{
+			name:        "responds with unprocessable entity status if domain is unknown",
+			requestBody: `{"url":"http://example.com","domain":"sho.rt"}`,
+			want: want{
+				code:     http.StatusUnprocessableEntity,
+				response: `{"code":"validation_failed","message":"unknown domain","details":{"domain":"unknown domain"}}`,
+			},
+		},
+		{
+			name:        "responds with unprocessable entity status if settings are invalid",
+			requestBody: `{"url":"http://example.com","tags":[""]}`,
+			shortifyRes: []interface{}{models.Record{}, fmt.Errorf("%w: empty tag", services.ErrInvalidTags)},
+			want: want{
+				code: http.StatusUnprocessableEntity,
+				response: `{"code":"validation_failed","message":"invalid tags: empty tag",` +
+					`"details":{"tags":"invalid tags: empty tag"}}`,
+			},
+		},
+		{
+			name:        "responds with conflict status and existing short URL",
+			requestBody: `{"url":"http://example.com"}`,
+			shortifyRes: []interface{}{
+				models.Record{},
+				fmt.Errorf("failed to generate shortened path: %w", storage.NewErrNotUnique(models.Record{ShortenedPath: "321"})),
+			},
+			want: want{
+				code: http.StatusConflict,
+				response: `{"code":"already_exists","message":"original URL is already shortened",` +
+					`"details":{"short_url":"http://localhost:8080/321"}}`,
+			},
+		},
+		{
+			name:        "responds with internal error status without error message",
+			requestBody: `{"url":"http://example.com"}`,
+			shortifyRes: []interface{}{models.Record{}, errors.New("connection refused")},
+			want: want{
+				code:     http.StatusInternalServerError,
+				response: `{"code":"internal","message":"internal error"}`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.shortifyRes != nil {
+				mockCall := shortener.On("Shortify", mock.Anything, mock.Anything).Return(tc.shortifyRes...)
+				defer mockCall.Unset()
+			}
+
+			response, err := testServer.Client().Post(
+				testServer.URL+"/api/v1/urls", "application/json", strings.NewReader(tc.requestBody),
+			)
+			require.NoError(t, err)
+			resBody, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			require.NoError(t, response.Body.Close())
+
+			assert.Equal(t, tc.want.code, response.StatusCode)
+			assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+			assert.JSONEq(t, tc.want.response, string(resBody))
+		})
+	}
+}
+
+func TestV1GetUserURLs(t *testing.T) {
+	store := storage.NewMapStorage(nil)
+	user := models.User{ID: 1}
+	userAuthenticator := new(userAuthenticatorMock)
+	userAuthenticator.On("Auth", mock.Anything).Return(user, nil)
+	v1 := handlers.NewV1(handlers.NewHandlers(defaultConfig, store))
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.With(middlewares.AuthenticateV1(userAuthenticator)).Get("/api/v1/user/urls", v1.GetUserURLs)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	testCases := []struct {
+		name   string
+		query  string
+		cookie *http.Cookie
+		want   want
+	}{
+		{
+			name:   "responds with empty page if user has no URLs",
+			cookie: generateAuthCookie(t, user),
+			want: want{
+				code:     http.StatusOK,
+				response: `{"items":[]}`,
+			},
+		},
+		{
+			name:   "responds with bad request status if query parameter is invalid",
+			query:  "?created_from=yesterday",
+			cookie: generateAuthCookie(t, user),
+			want: want{
+				code: http.StatusBadRequest,
+				response: `{"code":"invalid_request",` +
+					`"message":"invalid created_from: parsing time \"yesterday\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"yesterday\" as \"2006\"",` +
+					`"details":{"created_from":"parsing time \"yesterday\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"yesterday\" as \"2006\""},` +
+					`"request_id":"req-1"}`,
+			},
+		},
+		{
+			name: "responds with unauthorized status without JWT",
+			want: want{
+				code:     http.StatusUnauthorized,
+				response: `{"code":"unauthenticated","message":"missing JWT","request_id":"req-1"}`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, testServer.URL+"/api/v1/user/urls"+tc.query, nil)
+			require.NoError(t, err)
+			request.Header.Set(middleware.RequestIDHeader, "req-1")
+			if tc.cookie != nil {
+				request.AddCookie(tc.cookie)
+			}
+
+			response, err := testServer.Client().Do(request)
+			require.NoError(t, err)
+			resBody, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			require.NoError(t, response.Body.Close())
+
+			assert.Equal(t, tc.want.code, response.StatusCode)
+			assert.JSONEq(t, tc.want.response, string(resBody))
+		})
+	}
+}
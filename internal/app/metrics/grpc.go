@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	grpcRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "requests_total",
+			Help:      "gRPC calls by full method and status code.",
+		},
+		[]string{"method", "code"},
+	)
+	grpcDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "request_duration_seconds",
+			Help:      "gRPC call latency by full method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"method", "code"},
+	)
+)
+
+// Unary calls metrics interceptor. Must be the first one in chain, so calls
+// rejected by other interceptors are counted
+func UnaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	observeGRPC(info.FullMethod, start, err)
+
+	return resp, err
+}
+
+// Streaming calls metrics interceptor, latency is the stream duration
+func StreamServerInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+
+	start := time.Now()
+	err := handler(srv, ss)
+	observeGRPC(info.FullMethod, start, err)
+
+	return err
+}
+
+func observeGRPC(method string, start time.Time, err error) {
+	labels := prometheus.Labels{"method": method, "code": status.Code(err).String()}
+	grpcRequests.With(labels).Inc()
+	grpcDuration.With(labels).Observe(time.Since(start).Seconds())
+}
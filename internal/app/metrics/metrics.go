@@ -0,0 +1,129 @@
+// Package metrics collects Prometheus metrics of the shortener. Collectors are
+// registered in the default registry, which also has Go runtime and process
+// metrics
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "urlshort"
+
+// Transports of redirects and not found links
+const (
+	TransportHTTP = "http"
+	TransportGRPC = "grpc"
+)
+
+// Route label of requests not matching any route
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests by route pattern, method and status.",
+		},
+		[]string{"route", "method", "status"},
+	)
+	httpDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency by route pattern, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"route", "method", "status"},
+	)
+	linksCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "links_created_total",
+		Help:      "Shortened links saved to storage.",
+	})
+	redirects = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "redirects_total",
+			Help:      "Redirects to original URLs by transport.",
+		},
+		[]string{"transport"},
+	)
+	notFound = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "not_found_total",
+			Help:      "Requested short links which do not exist, by transport.",
+		},
+		[]string{"transport"},
+	)
+)
+
+// Handler exposing metrics of default registry
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// HTTP requests metrics middleware. Must be used by the root router, so route
+// pattern is complete when request is served
+func Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		h.ServeHTTP(ww, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && len(rctx.RoutePatterns) > 0 {
+			// Pattern of root route is empty
+			route = rctx.RoutePattern()
+			if route == "" {
+				route = "/"
+			}
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(status)}
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Count links saved to storage
+func LinksCreated(n int) {
+	linksCreated.Add(float64(n))
+}
+
+// Count redirect to original URL
+func RedirectServed(transport string) {
+	redirects.WithLabelValues(transport).Inc()
+}
+
+// Count request of unknown short link
+func LinkNotFound(transport string) {
+	notFound.WithLabelValues(transport).Inc()
+}
+
+// Gauge of deleter queue depth
+func NewDeleterQueueCollector(queueLen func() int) prometheus.Collector {
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "deleter",
+			Name:      "queue_depth",
+			Help:      "Records waiting for deferred deletion.",
+		},
+		func() float64 { return float64(queueLen()) },
+	)
+}
@@ -0,0 +1,147 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/metrics"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+// Exposed metrics in text format
+func scrape(t *testing.T) string {
+	response := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, response.Code)
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	return string(body)
+}
+
+func TestMiddleware(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(metrics.Middleware)
+	router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	router.Route("/middleware-test", func(router chi.Router) {
+		router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "http://example.com", http.StatusTemporaryRedirect)
+		})
+	})
+
+	for _, path := range []string{"/middleware-test/1", "/middleware-test/2", "/middleware-test-unknown"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+
+	body := scrape(t)
+	assert.Contains(t, body, `urlshort_http_requests_total{method="GET",route="/middleware-test/{id}",status="307"} 2`)
+	assert.Contains(t, body, `urlshort_http_requests_total{method="GET",route="unmatched",status="404"}`)
+	assert.Contains(t, body, `urlshort_http_requests_total{method="POST",route="/",status="201"} 1`)
+	assert.Contains(t, body,
+		`urlshort_http_request_duration_seconds_count{method="GET",route="/middleware-test/{id}",status="307"} 2`)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Interceptor"}
+	_, err := metrics.UnaryServerInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	})
+	require.Error(t, err)
+	_, err = metrics.UnaryServerInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	require.NoError(t, err)
+
+	body := scrape(t)
+	assert.Contains(t, body, `urlshort_grpc_requests_total{code="NotFound",method="/test.Service/Interceptor"} 1`)
+	assert.Contains(t, body, `urlshort_grpc_requests_total{code="OK",method="/test.Service/Interceptor"} 1`)
+}
+
+type failingStorage struct {
+	storage.Storage
+}
+
+func (failingStorage) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	before := metricValue(t, scrape(t), "urlshort_links_created_total")
+	store := metrics.NewStorage(storage.NewMapStorage(nil))
+
+	require.NoError(t, store.Save(ctx, models.Record{OriginalURL: "http://example.com", ShortenedPath: "1"}))
+	var notUnique *storage.ErrNotUnique
+	require.ErrorAs(t, store.Save(ctx, models.Record{OriginalURL: "http://example.com", ShortenedPath: "2"}), &notUnique)
+	inserted, err := store.BatchInsert(ctx, []models.Record{
+		{OriginalURL: "http://example.com", ShortenedPath: "3"},
+		{OriginalURL: "http://example.org", ShortenedPath: "4"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false, true}, inserted)
+	_, err = store.FindByShortenedPath(ctx, "", "5")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	require.Error(t, metrics.NewStorage(failingStorage{}).Ping(ctx))
+
+	body := scrape(t)
+	assert.Equal(t, before+2, metricValue(t, body, "urlshort_links_created_total"))
+	assert.Contains(t, body, `urlshort_storage_operation_duration_seconds_count{operation="save"} 2`)
+	assert.Contains(t, body, `urlshort_storage_operation_duration_seconds_count{operation="find_by_shortened_path"} 1`)
+	assert.Contains(t, body, `urlshort_storage_errors_total{operation="ping"} 1`)
+	assert.NotContains(t, body, `urlshort_storage_errors_total{operation="save"}`)
+	assert.NotContains(t, body, `urlshort_storage_errors_total{operation="find_by_shortened_path"}`)
+}
+
+func TestNewDeleterQueueCollector(t *testing.T) {
+	collector := metrics.NewDeleterQueueCollector(func() int { return 3 })
+	assert.Equal(t, 3.0, testutil.ToFloat64(collector))
+}
+
+func TestNewPoolCollector(t *testing.T) {
+	// Pool connects lazily, statistics are available without database
+	pool, err := pgxpool.New(context.Background(), "postgres://localhost:1/db?pool_max_conns=7")
+	require.NoError(t, err)
+	defer pool.Close()
+
+	collector := metrics.NewPoolCollector(pool.Stat)
+	assert.Equal(t, 12, testutil.CollectAndCount(collector))
+	expected := `
+# HELP urlshort_db_pool_max_connections Max pool size.
+# TYPE urlshort_db_pool_max_connections gauge
+urlshort_db_pool_max_connections 7
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "urlshort_db_pool_max_connections"))
+}
+
+// Value of metric without labels
+func metricValue(t *testing.T, body, name string) float64 {
+	for _, line := range strings.Split(body, "\n") {
+		if value, ok := strings.CutPrefix(line, name+" "); ok {
+			var result float64
+			_, err := fmt.Sscan(value, &result)
+			require.NoError(t, err)
+			return result
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector of Postgres connection pool statistics
+type poolCollector struct {
+	stat func() *pgxpool.Stat
+
+	acquiredConns       *prometheus.Desc
+	idleConns           *prometheus.Desc
+	constructingConns   *prometheus.Desc
+	totalConns          *prometheus.Desc
+	maxConns            *prometheus.Desc
+	acquires            *prometheus.Desc
+	acquireDuration     *prometheus.Desc
+	emptyAcquires       *prometheus.Desc
+	canceledAcquires    *prometheus.Desc
+	newConns            *prometheus.Desc
+	maxLifetimeDestroys *prometheus.Desc
+	maxIdleDestroys     *prometheus.Desc
+}
+
+// Collector of Postgres connection pool statistics, stat is usually
+// DBStorage.Stat
+func NewPoolCollector(stat func() *pgxpool.Stat) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+
+	return poolCollector{
+		stat:                stat,
+		acquiredConns:       desc("acquired_connections", "Connections currently in use."),
+		idleConns:           desc("idle_connections", "Idle connections."),
+		constructingConns:   desc("constructing_connections", "Connections being established."),
+		totalConns:          desc("connections", "All open connections."),
+		maxConns:            desc("max_connections", "Max pool size."),
+		acquires:            desc("acquires_total", "Successful connection acquires."),
+		acquireDuration:     desc("acquire_duration_seconds_total", "Time spent acquiring connections."),
+		emptyAcquires:       desc("empty_acquires_total", "Acquires which waited for a connection."),
+		canceledAcquires:    desc("canceled_acquires_total", "Acquires canceled by context."),
+		newConns:            desc("new_connections_total", "Connections opened."),
+		maxLifetimeDestroys: desc("max_lifetime_destroys_total", "Connections closed by max lifetime."),
+		maxIdleDestroys:     desc("max_idle_destroys_total", "Connections closed by max idle time."),
+	}
+}
+
+func (c poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.stat()
+	gauge := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+	}
+	counter := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value)
+	}
+
+	gauge(c.acquiredConns, float64(stat.AcquiredConns()))
+	gauge(c.idleConns, float64(stat.IdleConns()))
+	gauge(c.constructingConns, float64(stat.ConstructingConns()))
+	gauge(c.totalConns, float64(stat.TotalConns()))
+	gauge(c.maxConns, float64(stat.MaxConns()))
+	counter(c.acquires, float64(stat.AcquireCount()))
+	counter(c.acquireDuration, stat.AcquireDuration().Seconds())
+	counter(c.emptyAcquires, float64(stat.EmptyAcquireCount()))
+	counter(c.canceledAcquires, float64(stat.CanceledAcquireCount()))
+	counter(c.newConns, float64(stat.NewConnsCount()))
+	counter(c.maxLifetimeDestroys, float64(stat.MaxLifetimeDestroyCount()))
+	counter(c.maxIdleDestroys, float64(stat.MaxIdleDestroyCount()))
+}
@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+var (
+	storageDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "operation_duration_seconds",
+			Help:      "Storage operation latency by operation.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		},
+		[]string{"operation"},
+	)
+	storageErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "errors_total",
+			Help:      "Failed storage operations by operation. Not found, not unique and exhausted clicks are not failures.",
+		},
+		[]string{"operation"},
+	)
+)
+
+// Storage measuring latency and errors of operations and counting created links
+type instrumentedStorage struct {
+	store storage.Storage
+}
+
+// Storage decorator collecting metrics of store
+func NewStorage(store storage.Storage) storage.Storage {
+	return instrumentedStorage{store: store}
+}
+
+// Observe operation started at start. Deferred with pointer to named result
+// error, so the error is read when operation returns
+func observeStorage(operation string, start time.Time, err *error) {
+	storageDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	var notUnique *storage.ErrNotUnique
+	if *err != nil &&
+		!errors.Is(*err, storage.ErrNotFound) &&
+		!errors.Is(*err, storage.ErrClicksExhausted) &&
+		!errors.As(*err, &notUnique) {
+		storageErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+func (s instrumentedStorage) FindByOriginalURL(ctx context.Context, originalURL string) (_ models.Record, err error) {
+	defer observeStorage("find_by_original_url", time.Now(), &err)
+	return s.store.FindByOriginalURL(ctx, originalURL)
+}
+
+func (s instrumentedStorage) FindByShortenedPath(
+	ctx context.Context,
+	domain string,
+	shortenedPath string) (_ models.Record, err error) {
+
+	defer observeStorage("find_by_shortened_path", time.Now(), &err)
+	return s.store.FindByShortenedPath(ctx, domain, shortenedPath)
+}
+
+func (s instrumentedStorage) FindByUser(
+	ctx context.Context,
+	user models.User,
+	filter models.RecordFilter) (_ []models.Record, err error) {
+
+	defer observeStorage("find_by_user", time.Now(), &err)
+	return s.store.FindByUser(ctx, user, filter)
+}
+
+func (s instrumentedStorage) FindByUserPage(
+	ctx context.Context,
+	user models.User,
+	filter models.RecordFilter,
+	page models.Page) (_ []models.Record, _ *models.Cursor, err error) {
+
+	defer observeStorage("find_by_user_page", time.Now(), &err)
+	return s.store.FindByUserPage(ctx, user, filter, page)
+}
+
+// Latency includes time spent in visit
+func (s instrumentedStorage) ExportByUser(
+	ctx context.Context,
+	user models.User,
+	includeDeleted bool,
+	visit func(record models.Record, clicks int) error) (err error) {
+
+	defer observeStorage("export_by_user", time.Now(), &err)
+	return s.store.ExportByUser(ctx, user, includeDeleted, visit)
+}
+
+func (s instrumentedStorage) Save(ctx context.Context, record models.Record) (err error) {
+	defer observeStorage("save", time.Now(), &err)
+	if err = s.store.Save(ctx, record); err == nil {
+		LinksCreated(1)
+	}
+
+	return err
+}
+
+func (s instrumentedStorage) BatchSave(ctx context.Context, records []models.Record) (err error) {
+	defer observeStorage("batch_save", time.Now(), &err)
+	if err = s.store.BatchSave(ctx, records); err == nil {
+		LinksCreated(len(records))
+	}
+
+	return err
+}
+
+func (s instrumentedStorage) BatchInsert(ctx context.Context, records []models.Record) (_ []bool, err error) {
+	defer observeStorage("batch_insert", time.Now(), &err)
+	inserted, err := s.store.BatchInsert(ctx, records)
+	created := 0
+	for _, ok := range inserted {
+		if ok {
+			created++
+		}
+	}
+	LinksCreated(created)
+
+	return inserted, err
+}
+
+func (s instrumentedStorage) Update(ctx context.Context, record models.Record) (err error) {
+	defer observeStorage("update", time.Now(), &err)
+	return s.store.Update(ctx, record)
+}
+
+func (s instrumentedStorage) BatchDelete(ctx context.Context, records []models.Record) (err error) {
+	defer observeStorage("batch_delete", time.Now(), &err)
+	return s.store.BatchDelete(ctx, records)
+}
+
+func (s instrumentedStorage) ConsumeClick(ctx context.Context, domain, shortenedPath string) (_ int, err error) {
+	defer observeStorage("consume_click", time.Now(), &err)
+	return s.store.ConsumeClick(ctx, domain, shortenedPath)
+}
+
+func (s instrumentedStorage) SaveClick(ctx context.Context, click models.Click) (err error) {
+	defer observeStorage("save_click", time.Now(), &err)
+	return s.store.SaveClick(ctx, click)
+}
+
+func (s instrumentedStorage) ClickStats(
+	ctx context.Context,
+	domain string,
+	shortenedPath string) (_ models.ClickStats, err error) {
+
+	defer observeStorage("click_stats", time.Now(), &err)
+	return s.store.ClickStats(ctx, domain, shortenedPath)
+}
+
+func (s instrumentedStorage) URLsCount(ctx context.Context) (_ int, err error) {
+	defer observeStorage("urls_count", time.Now(), &err)
+	return s.store.URLsCount(ctx)
+}
+
+func (s instrumentedStorage) UsersCount(ctx context.Context) (_ int, err error) {
+	defer observeStorage("users_count", time.Now(), &err)
+	return s.store.UsersCount(ctx)
+}
+
+func (s instrumentedStorage) Ping(ctx context.Context) (err error) {
+	defer observeStorage("ping", time.Now(), &err)
+	return s.store.Ping(ctx)
+}
+
+func (s instrumentedStorage) CreateUser(ctx context.Context) (_ models.User, err error) {
+	defer observeStorage("create_user", time.Now(), &err)
+	return s.store.CreateUser(ctx)
+}
@@ -2,6 +2,7 @@ package middlewares
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"net"
@@ -9,8 +10,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
+	"github.com/ilya-burinskiy/urlshort/internal/app/apierror"
 	"github.com/ilya-burinskiy/urlshort/internal/app/compress"
 	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
 	"github.com/ilya-burinskiy/urlshort/internal/app/services"
@@ -76,34 +80,57 @@ func RequestLogger(h http.Handler) http.Handler {
 		start := time.Now()
 		h.ServeHTTP(w, r)
 		duration := time.Since(start)
+		traceID := zap.Skip()
+		if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.HasTraceID() {
+			traceID = zap.String("trace_id", spanContext.TraceID().String())
+		}
 		logger.Log.Info("got incoming HTTP request",
+			zap.String("request_id", middleware.GetReqID(r.Context())),
 			zap.String("method", r.Method),
 			zap.String("URI", r.RequestURI),
 			zap.String("duration", duration.String()),
+			traceID,
 		)
 	})
 }
 
 // Authentication middleware
 func Authenticate(userAuthenticator services.UserAuthenticator) func(http.Handler) http.Handler {
+	return authenticate(userAuthenticator, func(w http.ResponseWriter, r *http.Request, err error) {
+		w.WriteHeader(http.StatusUnauthorized)
+		if err = json.NewEncoder(w).Encode(err.Error()); err != nil {
+			logger.Log.Info("authenticate middleware", zap.Error(err))
+		}
+	})
+}
+
+// Authentication middleware of versioned API, responds with error envelope
+func AuthenticateV1(userAuthenticator services.UserAuthenticator) func(http.Handler) http.Handler {
+	return authenticate(userAuthenticator, func(w http.ResponseWriter, r *http.Request, err error) {
+		message := err.Error()
+		if errors.Is(err, http.ErrNoCookie) {
+			message = "missing JWT"
+		}
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, message))
+	})
+}
+
+func authenticate(
+	userAuthenticator services.UserAuthenticator,
+	unauthorized func(w http.ResponseWriter, r *http.Request, err error)) func(http.Handler) http.Handler {
+
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			encoder := json.NewEncoder(w)
 			cookie, err := r.Cookie("jwt")
 			if err != nil {
-				w.WriteHeader(http.StatusUnauthorized)
-				if err = encoder.Encode(err.Error()); err != nil {
-					logger.Log.Info("authenticate middleware", zap.Error(err))
-				}
+				unauthorized(w, r, err)
 				return
 			}
 
-			user, err := userAuthenticator.Auth(cookie.Value)
+			user, err := userAuthenticator.Auth(r.Context(), cookie.Value)
 			if errors.Is(err, services.ErrInvalidJWT) {
-				w.WriteHeader(http.StatusUnauthorized)
-				if err = encoder.Encode("invalid JWT"); err != nil {
-					logger.Log.Info("authenticate middleware", zap.Error(err))
-				}
+				unauthorized(w, r, services.ErrInvalidJWT)
+				return
 			}
 			ctx := context.WithValue(r.Context(), userIDKey, user.ID)
 			h.ServeHTTP(w, r.WithContext(ctx))
@@ -113,9 +140,26 @@ func Authenticate(userAuthenticator services.UserAuthenticator) func(http.Handle
 
 // OnlyTrustedIP
 func OnlyTrustedIP(ipChecker services.IPChecker) func(http.Handler) http.Handler {
+	return onlyTrustedIP(ipChecker, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+}
+
+// OnlyTrustedIP of versioned API, responds with error envelope
+func OnlyTrustedIPV1(ipChecker services.IPChecker) func(http.Handler) http.Handler {
+	return onlyTrustedIP(ipChecker, func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, apierror.New(http.StatusForbidden, "client IP is not in trusted subnet"))
+	})
+}
+
+// Only clients in trusted subnet or with admin bearer token. Token check is
+// off if token is empty
+func OnlyAdmin(ipChecker services.IPChecker, token string) func(http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if !ipChecker.InTrustedSubnet(net.ParseIP(r.Header.Get("X-Real-IP"))) {
+			bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			isAdmin := ok && token != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1
+			if !isAdmin && !ipChecker.InTrustedSubnet(net.ParseIP(r.Header.Get("X-Real-IP"))) {
 				w.WriteHeader(http.StatusForbidden)
 				return
 			}
@@ -124,6 +168,21 @@ func OnlyTrustedIP(ipChecker services.IPChecker) func(http.Handler) http.Handler
 	}
 }
 
+func onlyTrustedIP(
+	ipChecker services.IPChecker,
+	forbidden func(w http.ResponseWriter, r *http.Request)) func(http.Handler) http.Handler {
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !ipChecker.InTrustedSubnet(net.ParseIP(r.Header.Get("X-Real-IP"))) {
+				forbidden(w, r)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
 // Get user ID from context
 func UserIDFromContext(ctx context.Context) (int, bool) {
 	userID, ok := ctx.Value(userIDKey).(int)
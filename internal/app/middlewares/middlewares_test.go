@@ -1 +1,70 @@
 package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/auth"
+	"github.com/ilya-burinskiy/urlshort/internal/app/middlewares"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+func TestAuthenticate(t *testing.T) {
+	jwtStr, err := auth.BuildJWTString(models.User{ID: 42})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		middleware func(services.UserAuthenticator) func(http.Handler) http.Handler
+		jwt        string
+		wantCode   int
+		wantUserID int
+	}{
+		{
+			name:       "passes user id of JWT",
+			middleware: middlewares.Authenticate,
+			jwt:        jwtStr,
+			wantCode:   http.StatusOK,
+			wantUserID: 42,
+		},
+		{
+			name:       "does not call handler with invalid JWT",
+			middleware: middlewares.Authenticate,
+			jwt:        jwtStr + "x",
+			wantCode:   http.StatusUnauthorized,
+		},
+		{
+			name:       "does not call handler of versioned API with invalid JWT",
+			middleware: middlewares.AuthenticateV1,
+			jwt:        "invalid",
+			wantCode:   http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			var userID int
+			handler := tc.middleware(services.NewUserAuthenticator(storage.NewMapStorage(nil)))(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					called = true
+					userID, _ = middlewares.UserIDFromContext(r.Context())
+				}),
+			)
+			request := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+			request.AddCookie(&http.Cookie{Name: "jwt", Value: tc.jwt})
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantCode == http.StatusOK, called)
+			assert.Equal(t, tc.wantUserID, userID)
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Click on shortened URL
+type Click struct {
+	Domain        string    `json:"domain,omitempty"`
+	ShortenedPath string    `json:"shortened_path"`
+	Variant       string    `json:"variant,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Click statistics of shortened URL
+type ClickStats struct {
+	Total     int            `json:"clicks"`
+	ByVariant map[string]int `json:"variants,omitempty"`
+}
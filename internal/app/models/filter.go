@@ -0,0 +1,51 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// User records filter. Zero fields match every record
+type RecordFilter struct {
+	// Record must have every tag
+	Tags   []string
+	Folder string
+	// Case insensitive substring of original URL or shortened path
+	Query string
+	// Created at or after
+	CreatedFrom *time.Time
+	// Created before
+	CreatedTo *time.Time
+	// Skip deleted records
+	ExcludeDeleted bool
+}
+
+// Record matches filter
+func (f RecordFilter) Matches(r Record) bool {
+	if f.Folder != "" && r.Folder != f.Folder {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !r.HasTag(tag) {
+			return false
+		}
+	}
+	if f.Query != "" {
+		query := strings.ToLower(f.Query)
+		if !strings.Contains(strings.ToLower(r.OriginalURL), query) &&
+			!strings.Contains(strings.ToLower(r.ShortenedPath), query) {
+			return false
+		}
+	}
+	if f.CreatedFrom != nil && r.CreatedAt.Before(*f.CreatedFrom) {
+		return false
+	}
+	if f.CreatedTo != nil && !r.CreatedAt.Before(*f.CreatedTo) {
+		return false
+	}
+	if f.ExcludeDeleted && r.IsDeleted {
+		return false
+	}
+
+	return true
+}
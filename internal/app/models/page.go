@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// User records sort orders. Ties are broken by domain and shortened path
+const (
+	SortByCreatedAt = "created_at"
+	SortByCode      = "code"
+)
+
+// Page of sorted user records
+type Page struct {
+	// Max number of records, zero means no limit
+	Limit  int
+	SortBy string
+	Desc   bool
+	// Position of last record of previous page, nil for the first page
+	After *Cursor
+}
+
+// Position of record in sorted user records
+type Cursor struct {
+	CreatedAt     time.Time
+	Domain        string
+	ShortenedPath string
+}
+
+// Position of record
+func CursorOf(r Record) Cursor {
+	return Cursor{CreatedAt: r.CreatedAt, Domain: r.Domain, ShortenedPath: r.ShortenedPath}
+}
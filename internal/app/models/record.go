@@ -1,10 +1,119 @@
 package models
 
+import (
+	"net/http"
+	"time"
+)
+
+// Record activity state at some point in time
+type Activity int
+
+const (
+	// Record is active
+	Active Activity = iota
+	// Record activity window has not started yet
+	NotYetActive
+	// Record activity window has ended
+	Ended
+)
+
+// Routing rule fields
+const (
+	RuleFieldUserAgent = "user_agent"
+	RuleFieldOS        = "os"
+	RuleFieldLanguage  = "language"
+	RuleFieldCountry   = "country"
+)
+
+// Routing rule. Sends visitors whose Field matches Pattern to Destination
+type RoutingRule struct {
+	Field       string `json:"field"`
+	Pattern     string `json:"pattern"`
+	Destination string `json:"destination"`
+}
+
+// Query string passthrough modes
+const (
+	// Use server default mode
+	QueryModeDefault = ""
+	// Drop incoming query parameters
+	QueryModeDrop = "drop"
+	// Add incoming query parameters missing in destination
+	QueryModeMerge = "merge"
+	// Replace destination query parameters with incoming ones
+	QueryModeOverride = "override"
+)
+
+// Weighted destination variant for split testing
+type Variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
 // Shortened URL model
 type Record struct {
 	OriginalURL   string `json:"original_url"`
 	ShortenedPath string `json:"shortened_path"`
-	CorrelationID string `json:"correlation_id"`
-	UserID        int    `json:"user_id"`
-	IsDeleted     bool   `json:"is_deleted"`
+	// Short domain, empty for the default one. Shortened paths are unique within domain
+	Domain          string            `json:"domain,omitempty"`
+	CorrelationID   string            `json:"correlation_id"`
+	UserID          int               `json:"user_id"`
+	IsDeleted       bool              `json:"is_deleted"`
+	MaxClicks       int               `json:"max_clicks,omitempty"`
+	RemainingClicks int               `json:"remaining_clicks,omitempty"`
+	ActiveFrom      *time.Time        `json:"active_from,omitempty"`
+	ActiveUntil     *time.Time        `json:"active_until,omitempty"`
+	FallbackURL     string            `json:"fallback_url,omitempty"`
+	Rules           []RoutingRule     `json:"rules,omitempty"`
+	Variants        []Variant         `json:"variants,omitempty"`
+	UTMParams       map[string]string `json:"utm_params,omitempty"`
+	QueryMode       string            `json:"query_mode,omitempty"`
+	RedirectCode    int               `json:"redirect_code,omitempty"`
+	Title           string            `json:"title,omitempty"`
+	Description     string            `json:"description,omitempty"`
+	Interstitial    bool              `json:"interstitial,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	Tags            []string          `json:"tags,omitempty"`
+	Folder          string            `json:"folder,omitempty"`
+}
+
+// Is supported redirect status code
+func IsRedirectCode(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+
+	return false
+}
+
+// Has limited number of clicks
+func (r Record) IsClickLimited() bool {
+	return r.MaxClicks > 0
+}
+
+// Activity at the given time
+func (r Record) ActivityAt(t time.Time) Activity {
+	if r.ActiveFrom != nil && t.Before(*r.ActiveFrom) {
+		return NotYetActive
+	}
+	if r.ActiveUntil != nil && !t.Before(*r.ActiveUntil) {
+		return Ended
+	}
+
+	return Active
+}
+
+// Has tag
+func (r Record) HasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
 }
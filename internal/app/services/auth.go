@@ -8,13 +8,15 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/ilya-burinskiy/urlshort/internal/app/auth"
 	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var ErrInvalidJWT = errors.New("invalid JWT")
 
 type UserAuthenticator interface {
 	AuthOrRegister(context.Context, string) (models.User, string, error)
-	Auth(string) (models.User, error)
+	Auth(context.Context, string) (models.User, error)
 }
 
 
@@ -31,12 +33,9 @@ func NewUserAuthenticator(usrCreator UserCreator) UserAuthenticator {
 }
 
 func (a authUserService) AuthOrRegister(ctx context.Context, jwtStr string) (models.User, string, error) {
-	claims := &auth.Claims{}
-	token, err := jwt.ParseWithClaims(jwtStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(auth.SecretKey), nil
-	})
+	claims, err := parseJWT(ctx, jwtStr)
 	var user models.User
-	if err != nil || !token.Valid {
+	if err != nil {
 		newUser, err := a.usrCreator.CreateUser(ctx)
 		if err != nil {
 			return user, "", fmt.Errorf("failed to authenticate guest: %w", err)
@@ -56,15 +55,30 @@ func (a authUserService) AuthOrRegister(ctx context.Context, jwtStr string) (mod
 	return user, jwtStr, nil
 }
 
-func (a authUserService) Auth(jwtStr string) (models.User, error) {
+func (a authUserService) Auth(ctx context.Context, jwtStr string) (models.User, error) {
+	claims, err := parseJWT(ctx, jwtStr)
+	var user models.User
+	if err != nil {
+		return user, err
+	}
+	user.ID = claims.UserID
+
+	return user, nil
+}
+
+func parseJWT(ctx context.Context, jwtStr string) (*auth.Claims, error) {
+	_, span := tracing.Start(ctx, "UserAuthenticator.ParseJWT")
+	defer span.End()
+
 	claims := &auth.Claims{}
 	token, err := jwt.ParseWithClaims(jwtStr, claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(auth.SecretKey), nil
 	})
-	var user models.User
 	if err != nil || !token.Valid {
-		return user, ErrInvalidJWT
+		span.SetAttributes(attribute.Bool("valid", false))
+		return nil, ErrInvalidJWT
 	}
+	span.SetAttributes(attribute.Bool("valid", true))
 
-	return user, nil
+	return claims, nil
 }
@@ -0,0 +1,27 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/auth"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+func TestUserAuthenticatorAuth(t *testing.T) {
+	authenticator := services.NewUserAuthenticator(storage.NewMapStorage(nil))
+	jwtStr, err := auth.BuildJWTString(models.User{ID: 42})
+	require.NoError(t, err)
+
+	user, err := authenticator.Auth(context.Background(), jwtStr)
+	require.NoError(t, err)
+	assert.Equal(t, 42, user.ID)
+
+	_, err = authenticator.Auth(context.Background(), jwtStr+"x")
+	assert.ErrorIs(t, err, services.ErrInvalidJWT)
+}
@@ -2,11 +2,34 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/tracing"
 )
 
+// Invalid max clicks error
+var ErrInvalidMaxClicks = errors.New("max clicks must not be negative")
+
+// Invalid activity window error
+var ErrInvalidActivityWindow = errors.New("active until must be after active from")
+
+// Invalid title error
+var ErrInvalidTitle = fmt.Errorf("title must not be longer than %d characters", maxTitleLen)
+
+// Invalid description error
+var ErrInvalidDescription = fmt.Errorf("description must not be longer than %d characters", maxDescriptionLen)
+
+const (
+	maxTitleLen       = 255
+	maxDescriptionLen = 1024
+)
 
 // Interface for a hex string generation
 type HexStrGen interface {
@@ -15,8 +38,8 @@ type HexStrGen interface {
 
 // Interface for creating shortened URLs
 type URLShortener interface {
-	Shortify(string, models.User) (models.Record, error)
-	BatchShortify([]models.Record, models.User) ([]models.Record, error)
+	Shortify(context.Context, models.Record, models.User) (models.Record, error)
+	BatchShortify(context.Context, []models.Record, models.User) ([]models.Record, error)
 }
 
 type URLSaver interface {
@@ -40,24 +63,48 @@ func NewURLShortener(pathLen int, strGen HexStrGen, urlSaver URLSaver) URLShorte
 }
 
 // Create
-func (srv urlShortener) Shortify(originalURL string, user models.User) (models.Record, error) {
+func (srv urlShortener) Shortify(ctx context.Context, record models.Record, user models.User) (_ models.Record, err error) {
+	ctx, span := tracing.Start(ctx, "URLShortener.Shortify")
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateRecord(record); err != nil {
+		return models.Record{}, err
+	}
+
 	shortenedPath, err := srv.strGen.Gen(srv.pathLen)
 	if err != nil {
 		return models.Record{}, fmt.Errorf("failed to generate shortened path: %s", err.Error())
 	}
 
-	record := models.Record{OriginalURL: originalURL, ShortenedPath: shortenedPath, UserID: user.ID}
-	err = srv.urlSaver.Save(context.Background(), record)
+	record.ShortenedPath = shortenedPath
+	record.UserID = user.ID
+	record.RemainingClicks = record.MaxClicks
+	record.CreatedAt = time.Now()
+	record.UpdatedAt = record.CreatedAt
+	err = srv.urlSaver.Save(ctx, record)
 	if err != nil {
-		return models.Record{}, fmt.Errorf("failed to generate shortened path: %s", err.Error())
+		return models.Record{}, fmt.Errorf("failed to generate shortened path: %w", err)
 	}
 
 	return record, nil
 }
 
 // BatchCreate
-func (srv urlShortener) BatchShortify(records []models.Record, user models.User) ([]models.Record, error) {
+func (srv urlShortener) BatchShortify(
+	ctx context.Context,
+	records []models.Record,
+	user models.User) (_ []models.Record, err error) {
+
+	ctx, span := tracing.Start(ctx, "URLShortener.BatchShortify", trace.WithAttributes(
+		attribute.Int("records", len(records)),
+	))
+	defer func() { tracing.End(span, err) }()
+
 	for i := range records {
+		if err := validateRecord(records[i]); err != nil {
+			return nil, fmt.Errorf("invalid record \"%s\": %w", records[i].OriginalURL, err)
+		}
+
 		shortenedPath, err := srv.strGen.Gen(srv.pathLen)
 		if err != nil {
 			return nil, fmt.Errorf(
@@ -67,12 +114,48 @@ func (srv urlShortener) BatchShortify(records []models.Record, user models.User)
 		}
 		records[i].ShortenedPath = shortenedPath
 		records[i].UserID = user.ID
+		records[i].RemainingClicks = records[i].MaxClicks
+		records[i].CreatedAt = time.Now()
+		records[i].UpdatedAt = records[i].CreatedAt
 	}
 
-	err := srv.urlSaver.BatchSave(context.Background(), records)
+	err = srv.urlSaver.BatchSave(ctx, records)
 	if err != nil {
 		return nil, err
 	}
 
 	return records, nil
 }
+
+func validateRecord(record models.Record) error {
+	if record.MaxClicks < 0 {
+		return ErrInvalidMaxClicks
+	}
+	if record.ActiveFrom != nil && record.ActiveUntil != nil && !record.ActiveUntil.After(*record.ActiveFrom) {
+		return ErrInvalidActivityWindow
+	}
+	if utf8.RuneCountInString(record.Title) > maxTitleLen {
+		return ErrInvalidTitle
+	}
+	if utf8.RuneCountInString(record.Description) > maxDescriptionLen {
+		return ErrInvalidDescription
+	}
+
+	if err := validateRules(record.Rules); err != nil {
+		return err
+	}
+	if err := validateQueryParams(record); err != nil {
+		return err
+	}
+	if err := validateRedirectCode(record); err != nil {
+		return err
+	}
+	if err := validateTags(record); err != nil {
+		return err
+	}
+	if err := validateFolder(record); err != nil {
+		return err
+	}
+
+	return validateVariants(record.Variants)
+}
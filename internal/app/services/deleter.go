@@ -2,12 +2,16 @@ package services
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
 	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/tracing"
 )
 
 // BatchDeleter
@@ -18,16 +22,27 @@ type BatchDeleter interface {
 type DeferredDeleter struct {
 	batchDeleter BatchDeleter
 	ch chan models.Record
+	// Records received by Run and not deleted yet
+	pending *atomic.Int64
 }
 
 func NewDeferredDeleter(batchDeleter BatchDeleter) DeferredDeleter {
-	return DeferredDeleter{batchDeleter: batchDeleter, ch: make(chan models.Record, 1024)}
+	return DeferredDeleter{
+		batchDeleter: batchDeleter,
+		ch:           make(chan models.Record, 1024),
+		pending:      &atomic.Int64{},
+	}
 }
 
 func (d DeferredDeleter) Enqueue(record models.Record) {
 	d.ch <- record
 }
 
+// Number of records waiting for deletion
+func (d DeferredDeleter) QueueLen() int {
+	return len(d.ch) + int(d.pending.Load())
+}
+
 // Run
 func (d DeferredDeleter) Run() {
 	ticker := time.NewTicker(5 * time.Second)
@@ -37,17 +52,32 @@ func (d DeferredDeleter) Run() {
 		select {
 		case record := <-d.ch:
 			records = append(records, record)
+			d.pending.Add(1)
 		case <-ticker.C:
 			if len(records) == 0 {
 				continue
 			}
 
-			err := d.batchDeleter.BatchDelete(context.TODO(), records)
+			err := d.deleteBatch(records)
 			if err != nil {
 				logger.Log.Info("run batch delete error", zap.String("err", err.Error()))
 				continue
 			}
+			d.pending.Add(-int64(len(records)))
 			records = nil
 		}
 	}
 }
+
+// Delete records in span of batch, there is no request to continue trace of
+func (d DeferredDeleter) deleteBatch(records []models.Record) (err error) {
+	ctx, span := tracing.Start(
+		context.Background(),
+		"DeferredDeleter.BatchDelete",
+		trace.WithNewRoot(),
+		trace.WithAttributes(attribute.Int("records", len(records))),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return d.batchDeleter.BatchDelete(ctx, records)
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+)
+
+// Export formats
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatJSONL = "jsonl"
+)
+
+// Unknown export format error
+var ErrInvalidExportFormat = errors.New("invalid export format")
+
+// Exported shortened URL. Alias, tags and expires_at columns of CSV export
+// match import columns, so exported file can be imported back
+type ExportedURL struct {
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	Alias       string     `json:"alias"`
+	Domain      string     `json:"domain,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Folder      string     `json:"folder,omitempty"`
+	Clicks      int        `json:"clicks"`
+	IsDeleted   bool       `json:"is_deleted"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+var exportColumns = []string{
+	"short_url", "original_url", ImportColumnAlias, "domain", "title", "description",
+	ImportColumnTags, "folder", "clicks", "is_deleted", "created_at", "updated_at", ImportColumnExpiresAt,
+}
+
+// Interface for exporting links
+type URLExporter interface {
+	Export(ctx context.Context, user models.User, includeDeleted bool, visit func(ExportedURL) error) error
+}
+
+// URLExportStore
+type URLExportStore interface {
+	ExportByUser(
+		ctx context.Context,
+		user models.User,
+		includeDeleted bool,
+		visit func(record models.Record, clicks int) error,
+	) error
+}
+
+type urlExporter struct {
+	store    URLExportStore
+	shortURL func(domain, shortenedPath string) string
+}
+
+// NewURLExporter
+func NewURLExporter(store URLExportStore, shortURL func(domain, shortenedPath string) string) URLExporter {
+	return urlExporter{store: store, shortURL: shortURL}
+}
+
+// Visit user links in creation order. Deleted links are visited only if includeDeleted is set
+func (exp urlExporter) Export(
+	ctx context.Context,
+	user models.User,
+	includeDeleted bool,
+	visit func(ExportedURL) error) error {
+
+	return exp.store.ExportByUser(ctx, user, includeDeleted, func(record models.Record, clicks int) error {
+		return visit(ExportedURL{
+			ShortURL:    exp.shortURL(record.Domain, record.ShortenedPath),
+			OriginalURL: record.OriginalURL,
+			Alias:       record.ShortenedPath,
+			Domain:      record.Domain,
+			Title:       record.Title,
+			Description: record.Description,
+			Tags:        record.Tags,
+			Folder:      record.Folder,
+			Clicks:      clicks,
+			IsDeleted:   record.IsDeleted,
+			CreatedAt:   record.CreatedAt,
+			UpdatedAt:   record.UpdatedAt,
+			ExpiresAt:   record.ActiveUntil,
+		})
+	})
+}
+
+// Writer of exported links in one of export formats
+type ExportEncoder interface {
+	Encode(u ExportedURL) error
+	// Write buffered data. Must be called after the last link
+	Flush() error
+}
+
+// New export encoder writing to w
+func NewExportEncoder(format string, w io.Writer) (ExportEncoder, error) {
+	switch format {
+	case ExportFormatCSV:
+		return &csvExportEncoder{writer: csv.NewWriter(w)}, nil
+	case ExportFormatJSONL:
+		return jsonlExportEncoder{encoder: json.NewEncoder(w)}, nil
+	}
+
+	return nil, fmt.Errorf("%w \"%s\", must be %s or %s", ErrInvalidExportFormat, format, ExportFormatCSV, ExportFormatJSONL)
+}
+
+// Header row is written before the first link, or on flush of empty export
+type csvExportEncoder struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (e *csvExportEncoder) Encode(u ExportedURL) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	expiresAt := ""
+	if u.ExpiresAt != nil {
+		expiresAt = u.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	return e.writer.Write([]string{
+		u.ShortURL,
+		u.OriginalURL,
+		u.Alias,
+		u.Domain,
+		u.Title,
+		u.Description,
+		strings.Join(u.Tags, ","),
+		u.Folder,
+		strconv.Itoa(u.Clicks),
+		strconv.FormatBool(u.IsDeleted),
+		u.CreatedAt.UTC().Format(time.RFC3339),
+		u.UpdatedAt.UTC().Format(time.RFC3339),
+		expiresAt,
+	})
+}
+
+func (e *csvExportEncoder) Flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.writer.Flush()
+
+	return e.writer.Error()
+}
+
+func (e *csvExportEncoder) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+	e.headerWritten = true
+
+	return e.writer.Write(exportColumns)
+}
+
+type jsonlExportEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e jsonlExportEncoder) Encode(u ExportedURL) error {
+	return e.encoder.Encode(u)
+}
+
+func (e jsonlExportEncoder) Flush() error {
+	return nil
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+// Import CSV columns. Only original_url is required, unknown columns are ignored
+const (
+	ImportColumnURL       = "original_url"
+	ImportColumnAlias     = "alias"
+	ImportColumnTags      = "tags"
+	ImportColumnExpiresAt = "expires_at"
+)
+
+// Import row statuses
+const (
+	ImportRowCreated  = "created"
+	ImportRowConflict = "conflict"
+	ImportRowInvalid  = "invalid"
+)
+
+// Import limits
+const (
+	ImportChunkSize = 100
+	MaxImportRows   = 100000
+)
+
+// Invalid import file error
+var ErrInvalidImport = errors.New("invalid import file")
+
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Import result of CSV row
+type ImportRow struct {
+	// Line number of the row in CSV file
+	Row      int    `json:"row"`
+	Status   string `json:"status"`
+	ShortURL string `json:"short_url,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Import report. Rows are reported in file order
+type ImportReport struct {
+	Created   int         `json:"created"`
+	Conflicts int         `json:"conflicts"`
+	Invalid   int         `json:"invalid"`
+	Rows      []ImportRow `json:"rows,omitempty"`
+}
+
+// Number of processed rows
+func (r ImportReport) Processed() int {
+	return r.Created + r.Conflicts + r.Invalid
+}
+
+// Interface for importing links
+type URLImporter interface {
+	Import(ctx context.Context, src io.Reader, user models.User, progress func(ImportReport)) (ImportReport, error)
+}
+
+// URLImportStore
+type URLImportStore interface {
+	FindByOriginalURL(ctx context.Context, originalURL string) (models.Record, error)
+	BatchInsert(ctx context.Context, records []models.Record) ([]bool, error)
+}
+
+type urlImporter struct {
+	pathLen  int
+	strGen   HexStrGen
+	store    URLImportStore
+	shortURL func(domain, shortenedPath string) string
+}
+
+// NewURLImporter
+func NewURLImporter(
+	pathLen int,
+	strGen HexStrGen,
+	store URLImportStore,
+	shortURL func(domain, shortenedPath string) string) URLImporter {
+
+	return urlImporter{pathLen: pathLen, strGen: strGen, store: store, shortURL: shortURL}
+}
+
+// Import links from CSV with header row. Rows are parsed and validated one by
+// one and saved in chunks, progress is called with report counters after every chunk
+func (imp urlImporter) Import(
+	ctx context.Context,
+	src io.Reader,
+	user models.User,
+	progress func(ImportReport)) (ImportReport, error) {
+
+	reader := csv.NewReader(src)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return ImportReport{}, fmt.Errorf("%w: failed to read header: %s", ErrInvalidImport, err.Error())
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns[ImportColumnURL]; !ok {
+		return ImportReport{}, fmt.Errorf("%w: missing %s column", ErrInvalidImport, ImportColumnURL)
+	}
+
+	report := ImportReport{Rows: make([]ImportRow, 0)}
+	chunk := make([]models.Record, 0, ImportChunkSize)
+	chunkRows := make([]int, 0, ImportChunkSize)
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		if err := imp.saveChunk(ctx, chunk, chunkRows, &report); err != nil {
+			return err
+		}
+		chunk, chunkRows = chunk[:0], chunkRows[:0]
+		if progress != nil {
+			progress(ImportReport{Created: report.Created, Conflicts: report.Conflicts, Invalid: report.Invalid})
+		}
+		return nil
+	}
+
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if len(report.Rows) == MaxImportRows {
+			if err := flush(); err != nil {
+				return report, err
+			}
+			return report, fmt.Errorf("%w: more than %d rows", ErrInvalidImport, MaxImportRows)
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.Rows = append(report.Rows, ImportRow{
+				Row:    parseErr.StartLine,
+				Status: ImportRowInvalid,
+				Error:  parseErr.Err.Error(),
+			})
+			report.Invalid++
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to read import file: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		record, err := imp.parseRow(fields, columns, user)
+		if err != nil {
+			report.Rows = append(report.Rows, ImportRow{Row: line, Status: ImportRowInvalid, Error: err.Error()})
+			report.Invalid++
+			continue
+		}
+
+		report.Rows = append(report.Rows, ImportRow{Row: line})
+		chunk = append(chunk, record)
+		chunkRows = append(chunkRows, len(report.Rows)-1)
+		if len(chunk) == ImportChunkSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	return report, flush()
+}
+
+func (imp urlImporter) parseRow(fields []string, columns map[string]int, user models.User) (models.Record, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(fields) {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+
+	now := time.Now()
+	record := models.Record{
+		OriginalURL: field(ImportColumnURL),
+		UserID:      user.ID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	u, err := url.Parse(record.OriginalURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return record, fmt.Errorf("%s must be an absolute http or https URL", ImportColumnURL)
+	}
+
+	if alias := field(ImportColumnAlias); alias != "" {
+		if !aliasPattern.MatchString(alias) {
+			return record, fmt.Errorf("%s must be 1 to 64 letters, digits, \"-\" or \"_\"", ImportColumnAlias)
+		}
+		record.ShortenedPath = alias
+	} else {
+		if record.ShortenedPath, err = imp.strGen.Gen(imp.pathLen); err != nil {
+			return record, fmt.Errorf("failed to generate shortened path: %w", err)
+		}
+	}
+
+	if tags := field(ImportColumnTags); tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				record.Tags = append(record.Tags, tag)
+			}
+		}
+	}
+	if expiresAt := field(ImportColumnExpiresAt); expiresAt != "" {
+		t, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return record, fmt.Errorf("invalid %s: %w", ImportColumnExpiresAt, err)
+		}
+		if !t.After(now) {
+			return record, fmt.Errorf("%s must be in the future", ImportColumnExpiresAt)
+		}
+		record.ActiveUntil = &t
+	}
+
+	return record, validateRecord(record)
+}
+
+func (imp urlImporter) saveChunk(ctx context.Context, chunk []models.Record, rows []int, report *ImportReport) error {
+	inserted, err := imp.store.BatchInsert(ctx, chunk)
+	if err != nil {
+		return err
+	}
+
+	for i, record := range chunk {
+		row := &report.Rows[rows[i]]
+		if inserted[i] {
+			row.Status = ImportRowCreated
+			row.ShortURL = imp.shortURL(record.Domain, record.ShortenedPath)
+			report.Created++
+			continue
+		}
+
+		row.Status = ImportRowConflict
+		report.Conflicts++
+		existing, err := imp.store.FindByOriginalURL(ctx, record.OriginalURL)
+		switch {
+		case err == nil:
+			row.ShortURL = imp.shortURL(existing.Domain, existing.ShortenedPath)
+			row.Error = "original URL is already shortened"
+		case errors.Is(err, storage.ErrNotFound):
+			row.Error = "shortened path is already taken"
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
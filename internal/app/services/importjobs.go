@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+// Import job statuses
+const (
+	ImportJobRunning = "running"
+	ImportJobDone    = "done"
+	ImportJobFailed  = "failed"
+)
+
+// How long finished import jobs are kept
+const ImportJobTTL = time.Hour
+
+// Background import job. Report has only counters until the job finishes
+type ImportJob struct {
+	ID         string       `json:"id"`
+	Status     string       `json:"status"`
+	Error      string       `json:"error,omitempty"`
+	Report     ImportReport `json:"report"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	userID     int
+}
+
+// In-memory registry of background import jobs. Jobs do not survive restart
+type ImportJobs struct {
+	mu       sync.Mutex
+	jobs     map[string]*ImportJob
+	importer URLImporter
+	strGen   HexStrGen
+}
+
+// NewImportJobs
+func NewImportJobs(importer URLImporter, strGen HexStrGen) *ImportJobs {
+	return &ImportJobs{
+		jobs:     make(map[string]*ImportJob),
+		importer: importer,
+		strGen:   strGen,
+	}
+}
+
+// Start import from src in background. src is closed when import finishes
+func (j *ImportJobs) Start(src io.ReadCloser, user models.User) (ImportJob, error) {
+	id, err := j.strGen.Gen(16)
+	if err != nil {
+		return ImportJob{}, fmt.Errorf("failed to generate job id: %w", err)
+	}
+
+	j.mu.Lock()
+	j.purge(time.Now())
+	job := &ImportJob{ID: id, Status: ImportJobRunning, StartedAt: time.Now(), userID: user.ID}
+	j.jobs[id] = job
+	result := *job
+	j.mu.Unlock()
+
+	go j.run(job, src, user)
+
+	return result, nil
+}
+
+// Get user import job. Returns storage.ErrNotFound if user does not own the job
+func (j *ImportJobs) Get(id string, user models.User) (ImportJob, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.purge(time.Now())
+	job, ok := j.jobs[id]
+	if !ok || job.userID != user.ID {
+		return ImportJob{}, storage.ErrNotFound
+	}
+
+	return *job, nil
+}
+
+func (j *ImportJobs) run(job *ImportJob, src io.ReadCloser, user models.User) {
+	defer func() {
+		if err := src.Close(); err != nil {
+			logger.Log.Info("failed to close import file", zap.Error(err))
+		}
+	}()
+
+	report, err := j.importer.Import(context.Background(), src, user, func(progress ImportReport) {
+		j.mu.Lock()
+		job.Report = progress
+		j.mu.Unlock()
+	})
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Report = report
+	job.Status = ImportJobDone
+	if err != nil {
+		job.Status = ImportJobFailed
+		job.Error = err.Error()
+	}
+}
+
+func (j *ImportJobs) purge(now time.Time) {
+	for id, job := range j.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > ImportJobTTL {
+			delete(j.jobs, id)
+		}
+	}
+}
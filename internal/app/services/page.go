@@ -0,0 +1,86 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+)
+
+// Max number of records per page
+const MaxPageLimit = 1000
+
+// Invalid pagination parameters error
+var ErrInvalidPage = errors.New("invalid pagination parameters")
+
+// Cursors are opaque to clients and bound to the sort order they were issued for
+type cursorToken struct {
+	SortBy        string    `json:"s"`
+	Desc          bool      `json:"o,omitempty"`
+	CreatedAt     time.Time `json:"t"`
+	Domain        string    `json:"d,omitempty"`
+	ShortenedPath string    `json:"p"`
+}
+
+// Build page of user records. Sort is created_at (default) or code, order is asc (default) or desc
+func NewPage(limit int, cursor, sortBy, order string) (models.Page, error) {
+	page := models.Page{Limit: limit, SortBy: sortBy}
+	if limit < 0 || limit > MaxPageLimit {
+		return page, fmt.Errorf("%w: limit must not be negative or greater than %d", ErrInvalidPage, MaxPageLimit)
+	}
+	switch sortBy {
+	case "":
+		page.SortBy = models.SortByCreatedAt
+	case models.SortByCreatedAt, models.SortByCode:
+	default:
+		return page, fmt.Errorf("%w: unknown sort \"%s\"", ErrInvalidPage, sortBy)
+	}
+	switch order {
+	case "", "asc":
+	case "desc":
+		page.Desc = true
+	default:
+		return page, fmt.Errorf("%w: unknown order \"%s\"", ErrInvalidPage, order)
+	}
+
+	if cursor == "" {
+		return page, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return page, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+	}
+	var token cursorToken
+	if err = json.Unmarshal(data, &token); err != nil {
+		return page, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+	}
+	if token.SortBy != page.SortBy || token.Desc != page.Desc {
+		return page, fmt.Errorf("%w: cursor was issued for another sort order", ErrInvalidPage)
+	}
+	page.After = &models.Cursor{
+		CreatedAt:     token.CreatedAt,
+		Domain:        token.Domain,
+		ShortenedPath: token.ShortenedPath,
+	}
+
+	return page, nil
+}
+
+// Encode cursor of the next page, empty if there are no more records
+func EncodeCursor(page models.Page, next *models.Cursor) string {
+	if next == nil {
+		return ""
+	}
+	data, _ := json.Marshal(cursorToken{
+		SortBy:        page.SortBy,
+		Desc:          page.Desc,
+		CreatedAt:     next.CreatedAt,
+		Domain:        next.Domain,
+		ShortenedPath: next.ShortenedPath,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
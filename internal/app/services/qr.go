@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QR code image formats
+const (
+	QRFormatPNG = "png"
+	QRFormatSVG = "svg"
+)
+
+// QR code options limits
+const (
+	DefaultQRSize   = 256
+	MaxQRSize       = 2048
+	DefaultQRMargin = 4
+	MaxQRMargin     = 16
+)
+
+// Invalid QR code options error
+var ErrInvalidQROptions = errors.New("invalid QR code options")
+
+var qrLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// QR code rendering options
+type QROptions struct {
+	// Image format, png or svg
+	Format string
+	// Image width and height in pixels
+	Size int
+	// Error correction level, one of L, M, Q, H
+	Level string
+	// Quiet zone width in modules
+	Margin int
+}
+
+// Default QR code options
+func DefaultQROptions() QROptions {
+	return QROptions{
+		Format: QRFormatPNG,
+		Size:   DefaultQRSize,
+		Level:  "M",
+		Margin: DefaultQRMargin,
+	}
+}
+
+// Rendered QR code image
+type QRImage struct {
+	ContentType string
+	Data        []byte
+}
+
+// Render QR code of content
+func RenderQR(content string, opts QROptions) (QRImage, error) {
+	level, ok := qrLevels[strings.ToUpper(opts.Level)]
+	if !ok {
+		return QRImage{}, fmt.Errorf("%w: unknown error correction level \"%s\"", ErrInvalidQROptions, opts.Level)
+	}
+	if opts.Size <= 0 || opts.Size > MaxQRSize {
+		return QRImage{}, fmt.Errorf("%w: size must be between 1 and %d", ErrInvalidQROptions, MaxQRSize)
+	}
+	if opts.Margin < 0 || opts.Margin > MaxQRMargin {
+		return QRImage{}, fmt.Errorf("%w: margin must be between 0 and %d", ErrInvalidQROptions, MaxQRMargin)
+	}
+
+	code, err := qrcode.New(content, level)
+	if err != nil {
+		return QRImage{}, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	code.DisableBorder = true
+	modules := code.Bitmap()
+
+	switch opts.Format {
+	case QRFormatPNG:
+		data, err := renderQRPNG(modules, opts.Size, opts.Margin)
+		if err != nil {
+			return QRImage{}, err
+		}
+		return QRImage{ContentType: "image/png", Data: data}, nil
+	case QRFormatSVG:
+		return QRImage{ContentType: "image/svg+xml", Data: renderQRSVG(modules, opts.Size, opts.Margin)}, nil
+	}
+
+	return QRImage{}, fmt.Errorf("%w: unknown format \"%s\"", ErrInvalidQROptions, opts.Format)
+}
+
+// Modules are scaled by the largest integer factor fitting the size and centered
+func renderQRPNG(modules [][]bool, size, margin int) ([]byte, error) {
+	total := len(modules) + 2*margin
+	scale := size / total
+	if scale == 0 {
+		return nil, fmt.Errorf("%w: size must be at least %d", ErrInvalidQROptions, total)
+	}
+	offset := (size-scale*total)/2 + margin*scale
+
+	img := image.NewPaletted(
+		image.Rect(0, 0, size, size),
+		color.Palette{color.Gray{Y: 0xff}, color.Gray{Y: 0x00}},
+	)
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				start := img.PixOffset(offset+x*scale, offset+y*scale+dy)
+				for dx := 0; dx < scale; dx++ {
+					img.Pix[start+dx] = 1
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Dark modules are drawn as horizontal runs of a single path
+func renderQRSVG(modules [][]bool, size, margin int) []byte {
+	total := strconv.Itoa(len(modules) + 2*margin)
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	buf.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="` + strconv.Itoa(size) +
+		`" height="` + strconv.Itoa(size) + `" viewBox="0 0 ` + total + " " + total +
+		`" shape-rendering="crispEdges">` + "\n")
+	buf.WriteString(`<rect width="100%" height="100%" fill="#fff"/>` + "\n")
+	buf.WriteString(`<path fill="#000" d="`)
+	for y, row := range modules {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			run := 1
+			for x+run < len(row) && row[x+run] {
+				run++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x+margin, y+margin, run, run)
+			x += run - 1
+		}
+	}
+	buf.WriteString(`"/>` + "\n</svg>\n")
+
+	return buf.Bytes()
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+)
+
+// Invalid query parameters settings error
+var ErrInvalidQueryParams = errors.New("invalid query parameters")
+
+// Invalid query parameters settings errors by setting
+var (
+	ErrInvalidQueryMode = fmt.Errorf("%w: unknown query mode", ErrInvalidQueryParams)
+	ErrInvalidUTMParams = fmt.Errorf("%w: empty UTM parameter name", ErrInvalidQueryParams)
+)
+
+// Build redirect URL. Appends record UTM parameters to destination and passes
+// incoming query parameters according to record query mode, defaultMode is used
+// if record has no mode. Destination fragment is preserved
+func BuildRedirectURL(
+	destination string,
+	record models.Record,
+	incoming url.Values,
+	defaultMode string) (string, error) {
+
+	mode := record.QueryMode
+	if mode == models.QueryModeDefault {
+		mode = defaultMode
+	}
+	if mode != models.QueryModeMerge && mode != models.QueryModeOverride {
+		incoming = nil
+	}
+	if len(record.UTMParams) == 0 && len(incoming) == 0 {
+		return destination, nil
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse destination: %w", err)
+	}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse destination query: %w", err)
+	}
+
+	// UTM parameters configured on link replace the ones already in destination,
+	// "{code}" in values is substituted with shortened path
+	params := url.Values{}
+	for key, value := range record.UTMParams {
+		params.Set(key, strings.ReplaceAll(value, "{code}", record.ShortenedPath))
+	}
+	for key, values := range incoming {
+		if mode == models.QueryModeMerge && (query.Has(key) || params.Has(key)) {
+			continue
+		}
+		params[key] = values
+	}
+
+	u.RawQuery = replaceQueryParams(u.RawQuery, params)
+	return u.String(), nil
+}
+
+// Removes params keys from raw query and appends params keeping the order of
+// remaining destination parameters
+func replaceQueryParams(rawQuery string, params url.Values) string {
+	var parts []string
+	for _, part := range strings.Split(rawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(part, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil && params.Has(unescaped) {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if encoded := params.Encode(); encoded != "" {
+		parts = append(parts, encoded)
+	}
+
+	return strings.Join(parts, "&")
+}
+
+func validateQueryParams(record models.Record) error {
+	switch record.QueryMode {
+	case models.QueryModeDefault, models.QueryModeDrop, models.QueryModeMerge, models.QueryModeOverride:
+	default:
+		return fmt.Errorf("%w \"%s\"", ErrInvalidQueryMode, record.QueryMode)
+	}
+	for key := range record.UTMParams {
+		if key == "" {
+			return ErrInvalidUTMParams
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+// Invalid redirect code error
+var ErrInvalidRedirectCode = errors.New("redirect code must be one of 301, 302, 307, 308")
+
+// Errors of links which can not be followed
+var (
+	ErrLinkDeleted   = errors.New("link is deleted")
+	ErrLinkNotActive = errors.New("link is not active yet")
+	ErrLinkEnded     = errors.New("link activity window has ended")
+)
+
+// Max age of cached permanent redirects without expiry. Owner may update or
+// delete link at any time, so caches must revalidate it shortly
+const PermanentRedirectMaxAge = 5 * time.Minute
+
+// Redirect caching headers
+type CacheHeaders struct {
+	CacheControl string
+	Expires      time.Time
+}
+
+// Redirect status code of record, defaultCode is used if record has no code
+func RedirectCode(record models.Record, defaultCode int) int {
+	if record.RedirectCode != 0 {
+		return record.RedirectCode
+	}
+	if models.IsRedirectCode(defaultCode) {
+		return defaultCode
+	}
+
+	return http.StatusTemporaryRedirect
+}
+
+// Destination of link before routing rules: original URL within activity
+// window, fallback URL after it. Returns error if link can not be followed,
+// storage.ErrClicksExhausted if it has no clicks left. Clicks are not consumed
+func LinkTarget(record models.Record, now time.Time) (string, error) {
+	if record.IsDeleted {
+		return "", ErrLinkDeleted
+	}
+	switch record.ActivityAt(now) {
+	case models.NotYetActive:
+		return "", ErrLinkNotActive
+	case models.Ended:
+		if record.FallbackURL == "" {
+			return "", ErrLinkEnded
+		}
+		return record.FallbackURL, nil
+	}
+	if record.IsClickLimited() && record.RemainingClicks <= 0 {
+		return "", storage.ErrClicksExhausted
+	}
+
+	return record.OriginalURL, nil
+}
+
+// Caching headers of redirect. Only permanent redirects of links which resolve
+// to the same destination for every visitor may be cached, for
+// PermanentRedirectMaxAge or until link expiry
+func RedirectCacheHeaders(record models.Record, code int, now time.Time) CacheHeaders {
+	permanent := code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect
+	if !permanent || record.IsClickLimited() || len(record.Rules) > 0 || len(record.Variants) > 0 {
+		return CacheHeaders{CacheControl: "private, no-cache", Expires: now}
+	}
+
+	maxAge := PermanentRedirectMaxAge
+	if record.ActiveUntil != nil && record.ActiveUntil.Sub(now) < maxAge {
+		maxAge = record.ActiveUntil.Sub(now)
+	}
+	if maxAge <= 0 {
+		return CacheHeaders{CacheControl: "private, no-cache", Expires: now}
+	}
+
+	return CacheHeaders{
+		CacheControl: fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())),
+		Expires:      now.Add(maxAge),
+	}
+}
+
+func validateRedirectCode(record models.Record) error {
+	if record.RedirectCode != 0 && !models.IsRedirectCode(record.RedirectCode) {
+		return ErrInvalidRedirectCode
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+)
+
+// Invalid routing rule error
+var ErrInvalidRoutingRule = errors.New("invalid routing rule")
+
+// Visitor attributes used for routing
+type Visitor struct {
+	UserAgent      string
+	AcceptLanguage string
+	Country        string
+	// Previously served variant name
+	Variant string
+}
+
+// Resolved destination
+type Destination struct {
+	URL string
+	// Served variant name, empty if record has no variants or a routing rule matched
+	Variant string
+}
+
+// Resolve destination for visitor. The first matching rule wins, then a weighted
+// variant is picked, record's original URL is the default destination
+func ResolveDestination(record models.Record, visitor Visitor) Destination {
+	for _, rule := range record.Rules {
+		if ruleMatches(rule, visitor) {
+			return Destination{URL: rule.Destination}
+		}
+	}
+	if variant, ok := PickVariant(record.Variants, visitor.Variant); ok {
+		return Destination{URL: variant.URL, Variant: variant.Name}
+	}
+
+	return Destination{URL: record.OriginalURL}
+}
+
+// Detect visitor operating system by user agent
+func DetectOS(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return "ios"
+	case strings.Contains(ua, "android"):
+		return "android"
+	case strings.Contains(ua, "windows"):
+		return "windows"
+	case strings.Contains(ua, "mac os x"), strings.Contains(ua, "macintosh"):
+		return "macos"
+	case strings.Contains(ua, "linux"):
+		return "linux"
+	}
+
+	return ""
+}
+
+// Preferred language from Accept-Language header, e.g. "de-de"
+func PreferredLanguage(acceptLanguage string) string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+	var langs []lang
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if qStr, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(qStr, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			langs = append(langs, lang{tag: strings.ToLower(tag), q: q})
+		}
+	}
+	if len(langs) == 0 {
+		return ""
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	return langs[0].tag
+}
+
+func ruleMatches(rule models.RoutingRule, visitor Visitor) bool {
+	pattern := strings.ToLower(rule.Pattern)
+	switch rule.Field {
+	case models.RuleFieldUserAgent:
+		return strings.Contains(strings.ToLower(visitor.UserAgent), pattern)
+	case models.RuleFieldOS:
+		return DetectOS(visitor.UserAgent) == pattern
+	case models.RuleFieldLanguage:
+		language := PreferredLanguage(visitor.AcceptLanguage)
+		return language == pattern || strings.HasPrefix(language, pattern+"-")
+	case models.RuleFieldCountry:
+		return strings.EqualFold(visitor.Country, pattern)
+	}
+
+	return false
+}
+
+func validateRules(rules []models.RoutingRule) error {
+	for i, rule := range rules {
+		switch rule.Field {
+		case models.RuleFieldUserAgent, models.RuleFieldOS, models.RuleFieldLanguage, models.RuleFieldCountry:
+		default:
+			return fmt.Errorf("%w %d: unknown field \"%s\"", ErrInvalidRoutingRule, i, rule.Field)
+		}
+		if rule.Pattern == "" {
+			return fmt.Errorf("%w %d: empty pattern", ErrInvalidRoutingRule, i)
+		}
+		if rule.Destination == "" {
+			return fmt.Errorf("%w %d: empty destination", ErrInvalidRoutingRule, i)
+		}
+	}
+
+	return nil
+}
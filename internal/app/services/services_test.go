@@ -1 +1,389 @@
 package services_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/services"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+func TestResolveDestination(t *testing.T) {
+	record := models.Record{
+		OriginalURL: "http://example.com",
+		Rules: []models.RoutingRule{
+			{Field: models.RuleFieldOS, Pattern: "ios", Destination: "http://apps.apple.com"},
+			{Field: models.RuleFieldOS, Pattern: "android", Destination: "http://play.google.com"},
+			{Field: models.RuleFieldLanguage, Pattern: "de", Destination: "http://example.de"},
+			{Field: models.RuleFieldCountry, Pattern: "FR", Destination: "http://example.fr"},
+			{Field: models.RuleFieldUserAgent, Pattern: "curl", Destination: "http://example.com/cli"},
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		visitor services.Visitor
+		want    string
+	}{
+		{
+			name:    "matches iOS",
+			visitor: services.Visitor{UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)"},
+			want:    "http://apps.apple.com",
+		},
+		{
+			name:    "matches Android",
+			visitor: services.Visitor{UserAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8)"},
+			want:    "http://play.google.com",
+		},
+		{
+			name:    "matches preferred language",
+			visitor: services.Visitor{AcceptLanguage: "en;q=0.5, de-DE;q=0.9"},
+			want:    "http://example.de",
+		},
+		{
+			name:    "matches country case insensitively",
+			visitor: services.Visitor{Country: "fr"},
+			want:    "http://example.fr",
+		},
+		{
+			name:    "matches user agent substring",
+			visitor: services.Visitor{UserAgent: "curl/8.0.1"},
+			want:    "http://example.com/cli",
+		},
+		{
+			name:    "falls back to original URL",
+			visitor: services.Visitor{UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64)", AcceptLanguage: "en-US"},
+			want:    "http://example.com",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, services.ResolveDestination(record, tc.visitor).URL)
+		})
+	}
+}
+
+func TestPickVariant(t *testing.T) {
+	variants := []models.Variant{
+		{Name: "a", URL: "http://a.example.com", Weight: 0},
+		{Name: "b", URL: "http://b.example.com", Weight: 3},
+		{Name: "c", URL: "http://c.example.com", Weight: 1},
+	}
+
+	served := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		variant, ok := services.PickVariant(variants, "")
+		assert.True(t, ok)
+		served[variant.Name]++
+	}
+	assert.Zero(t, served["a"])
+	assert.Greater(t, served["b"], served["c"])
+
+	variant, ok := services.PickVariant(variants, "c")
+	assert.True(t, ok)
+	assert.Equal(t, "c", variant.Name)
+
+	variant, ok = services.PickVariant(variants, "a")
+	assert.True(t, ok)
+	assert.NotEqual(t, "a", variant.Name)
+
+	_, ok = services.PickVariant(nil, "")
+	assert.False(t, ok)
+}
+
+func TestBuildRedirectURL(t *testing.T) {
+	incoming := url.Values{"ref": {"tw"}, "b": {"incoming"}}
+	testCases := []struct {
+		name        string
+		destination string
+		record      models.Record
+		defaultMode string
+		want        string
+	}{
+		{
+			name:        "drops query by default",
+			destination: "http://example.com/path?b=1",
+			defaultMode: models.QueryModeDrop,
+			want:        "http://example.com/path?b=1",
+		},
+		{
+			name:        "uses server default mode",
+			destination: "http://example.com/path?b=1",
+			defaultMode: models.QueryModeOverride,
+			want:        "http://example.com/path?b=incoming&ref=tw",
+		},
+		{
+			name:        "merges keeping destination params",
+			destination: "http://example.com/path?b=1&a=2",
+			record:      models.Record{QueryMode: models.QueryModeMerge},
+			defaultMode: models.QueryModeDrop,
+			want:        "http://example.com/path?b=1&a=2&ref=tw",
+		},
+		{
+			name:        "overrides destination params",
+			destination: "http://example.com/path?b=1&a=2",
+			record:      models.Record{QueryMode: models.QueryModeOverride},
+			defaultMode: models.QueryModeDrop,
+			want:        "http://example.com/path?a=2&b=incoming&ref=tw",
+		},
+		{
+			name:        "record mode wins over server default",
+			destination: "http://example.com/path?b=1",
+			record:      models.Record{QueryMode: models.QueryModeDrop},
+			defaultMode: models.QueryModeOverride,
+			want:        "http://example.com/path?b=1",
+		},
+		{
+			name:        "appends UTM params before fragment",
+			destination: "http://example.com/path?utm_source=old#section",
+			record: models.Record{
+				ShortenedPath: "abc",
+				UTMParams:     map[string]string{"utm_source": "short", "utm_campaign": "{code}"},
+			},
+			defaultMode: models.QueryModeDrop,
+			want:        "http://example.com/path?utm_campaign=abc&utm_source=short#section",
+		},
+		{
+			name:        "merge does not override UTM params",
+			destination: "http://example.com/#top",
+			record: models.Record{
+				QueryMode: models.QueryModeMerge,
+				UTMParams: map[string]string{"ref": "short"},
+			},
+			defaultMode: models.QueryModeDrop,
+			want:        "http://example.com/?b=incoming&ref=short#top",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := services.BuildRedirectURL(tc.destination, tc.record, incoming, tc.defaultMode)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestRenderQR(t *testing.T) {
+	opts := services.DefaultQROptions()
+	opts.Size = 200
+	img, err := services.RenderQR("http://localhost:8080/abc", opts)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", img.ContentType)
+
+	decoded, err := png.Decode(bytes.NewReader(img.Data))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 200, 200), decoded.Bounds())
+	// version 2 code with margin is 33 modules wide, scaled by 6 and centered
+	offset := (200-33*6)/2 + 4*6
+	assert.Equal(t, color.GrayModel.Convert(color.White), color.GrayModel.Convert(decoded.At(offset-1, offset-1)))
+	assert.Equal(t, color.GrayModel.Convert(color.Black), color.GrayModel.Convert(decoded.At(offset, offset)))
+
+	opts.Format = services.QRFormatSVG
+	opts.Margin = 0
+	img, err = services.RenderQR("http://localhost:8080/abc", opts)
+	require.NoError(t, err)
+	assert.Equal(t, "image/svg+xml", img.ContentType)
+	assert.Contains(t, string(img.Data), `viewBox="0 0 25 25"`)
+	assert.Contains(t, string(img.Data), `d="M0 0h7v1h-7z`)
+
+	for _, invalid := range []services.QROptions{
+		{Format: "gif", Size: 200, Level: "M"},
+		{Format: services.QRFormatPNG, Size: 200, Level: "X"},
+		{Format: services.QRFormatPNG, Size: 10, Level: "M", Margin: 4},
+		{Format: services.QRFormatPNG, Size: 200, Level: "M", Margin: -1},
+	} {
+		_, err = services.RenderQR("http://localhost:8080/abc", invalid)
+		assert.ErrorIs(t, err, services.ErrInvalidQROptions)
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	page, err := services.NewPage(10, "", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, models.Page{Limit: 10, SortBy: models.SortByCreatedAt}, page)
+
+	page, err = services.NewPage(10, "", models.SortByCode, "desc")
+	require.NoError(t, err)
+	next := models.Cursor{
+		CreatedAt:     time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC),
+		Domain:        "sho.rt",
+		ShortenedPath: "abc",
+	}
+	cursor := services.EncodeCursor(page, &next)
+	assert.Empty(t, services.EncodeCursor(page, nil))
+
+	page, err = services.NewPage(10, cursor, models.SortByCode, "desc")
+	require.NoError(t, err)
+	assert.Equal(t, &next, page.After)
+
+	for _, invalid := range []struct {
+		limit               int
+		cursor, sort, order string
+	}{
+		{limit: -1},
+		{limit: services.MaxPageLimit + 1},
+		{sort: "title"},
+		{order: "random"},
+		{cursor: "not a cursor"},
+		{cursor: cursor, sort: models.SortByCode},
+	} {
+		_, err = services.NewPage(invalid.limit, invalid.cursor, invalid.sort, invalid.order)
+		assert.ErrorIs(t, err, services.ErrInvalidPage)
+	}
+}
+
+type seqStrGen struct{ n int }
+
+func (g *seqStrGen) Gen(n int) (string, error) {
+	g.n++
+	return fmt.Sprintf("gen%d", g.n), nil
+}
+
+func TestURLImporter(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMapStorage(nil)
+	err := store.Save(ctx, models.Record{OriginalURL: "http://existing.com", ShortenedPath: "taken", UserID: 2})
+	require.NoError(t, err)
+	shortURL := func(domain, shortenedPath string) string { return "http://localhost:8080/" + shortenedPath }
+	importer := services.NewURLImporter(8, &seqStrGen{}, store, shortURL)
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	csv := "\ufeffOriginal_URL,alias,tags,expires_at,comment\n" +
+		"http://example.com,,\"work, docs\"," + expiresAt + ",ignored\n" +
+		"http://example.org,my-link\n" +
+		"http://existing.com\n" +
+		"http://example.net,taken\n" +
+		"ftp://example.com\n" +
+		"http://example.io,bad alias\n" +
+		"http://example.dev,,,2000-01-01T00:00:00Z\n"
+
+	var progress []services.ImportReport
+	report, err := importer.Import(ctx, strings.NewReader(csv), models.User{ID: 1}, func(r services.ImportReport) {
+		progress = append(progress, r)
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 2, report.Conflicts)
+	assert.Equal(t, 3, report.Invalid)
+	assert.Equal(t, []services.ImportReport{{Created: 2, Conflicts: 2, Invalid: 3}}, progress)
+	assert.Equal(t, []services.ImportRow{
+		{Row: 2, Status: services.ImportRowCreated, ShortURL: "http://localhost:8080/gen1"},
+		{Row: 3, Status: services.ImportRowCreated, ShortURL: "http://localhost:8080/my-link"},
+		{
+			Row:      4,
+			Status:   services.ImportRowConflict,
+			ShortURL: "http://localhost:8080/taken",
+			Error:    "original URL is already shortened",
+		},
+		{Row: 5, Status: services.ImportRowConflict, Error: "shortened path is already taken"},
+		{Row: 6, Status: services.ImportRowInvalid, Error: "original_url must be an absolute http or https URL"},
+		{Row: 7, Status: services.ImportRowInvalid, Error: `alias must be 1 to 64 letters, digits, "-" or "_"`},
+		{Row: 8, Status: services.ImportRowInvalid, Error: "expires_at must be in the future"},
+	}, report.Rows)
+
+	record, err := store.FindByShortenedPath(ctx, "", "gen1")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com", record.OriginalURL)
+	assert.Equal(t, []string{"work", "docs"}, record.Tags)
+	assert.Equal(t, 1, record.UserID)
+	require.NotNil(t, record.ActiveUntil)
+	assert.Equal(t, expiresAt, record.ActiveUntil.UTC().Format(time.RFC3339))
+
+	_, err = importer.Import(ctx, strings.NewReader("url\nhttp://example.com\n"), models.User{ID: 1}, nil)
+	assert.ErrorIs(t, err, services.ErrInvalidImport)
+}
+
+func TestURLShortenerSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	defer func() { require.NoError(t, provider.Shutdown(context.Background())) }()
+
+	store := storage.NewMapStorage(nil)
+	shortener := services.NewURLShortener(8, &seqStrGen{}, store)
+	authenticator := services.NewUserAuthenticator(store)
+	ctx, request := provider.Tracer("test").Start(context.Background(), "request")
+	_, err := shortener.Shortify(ctx, models.Record{OriginalURL: "http://example.com"}, models.User{ID: 1})
+	require.NoError(t, err)
+	invalid := []models.Record{{OriginalURL: "http://example.com", MaxClicks: -1}}
+	_, err = shortener.BatchShortify(ctx, invalid, models.User{ID: 1})
+	require.ErrorIs(t, err, services.ErrInvalidMaxClicks)
+	_, err = authenticator.Auth(ctx, "invalid")
+	require.ErrorIs(t, err, services.ErrInvalidJWT)
+	request.End()
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	require.Len(t, spans, 4)
+	for _, name := range []string{"URLShortener.Shortify", "URLShortener.BatchShortify", "UserAuthenticator.ParseJWT"} {
+		assert.Equal(t, request.SpanContext().SpanID(), spans[name].Parent.SpanID(), name)
+	}
+	assert.Equal(t, codes.Unset, spans["URLShortener.Shortify"].Status.Code)
+	assert.Equal(t, codes.Error, spans["URLShortener.BatchShortify"].Status.Code)
+}
+
+func TestRecordPatch(t *testing.T) {
+	activeFrom := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	activeUntil := activeFrom.Add(24 * time.Hour)
+	record := models.Record{ActiveFrom: &activeFrom, ActiveUntil: &activeUntil, FallbackURL: "http://fallback.com"}
+
+	testCases := []struct {
+		name string
+		body string
+		want models.Record
+	}{
+		{
+			name: "leaves absent fields unchanged",
+			body: `{}`,
+			want: record,
+		},
+		{
+			name: "clears fields set to null",
+			body: `{"active_from":null,"active_until":null,"fallback_url":null}`,
+			want: models.Record{},
+		},
+		{
+			name: "sets fields",
+			body: `{"active_until":"2024-03-01T00:00:00Z","fallback_url":"http://other.com"}`,
+			want: models.Record{
+				ActiveFrom:  &activeFrom,
+				ActiveUntil: timePtr(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)),
+				FallbackURL: "http://other.com",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var patch services.RecordPatch
+			require.NoError(t, json.Unmarshal([]byte(tc.body), &patch))
+			assert.Equal(t, tc.want, patch.Apply(record))
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
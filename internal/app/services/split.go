@@ -0,0 +1,66 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+)
+
+// Invalid variant error
+var ErrInvalidVariant = errors.New("invalid variant")
+
+// Pick variant by weight. Sticky variant is kept while it has positive weight
+func PickVariant(variants []models.Variant, sticky string) (models.Variant, bool) {
+	total := 0
+	for _, variant := range variants {
+		if sticky != "" && variant.Name == sticky && variant.Weight > 0 {
+			return variant, true
+		}
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return models.Variant{}, false
+	}
+
+	n := rand.Intn(total)
+	for _, variant := range variants {
+		if n < variant.Weight {
+			return variant, true
+		}
+		n -= variant.Weight
+	}
+
+	return models.Variant{}, false
+}
+
+func validateVariants(variants []models.Variant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	names := make(map[string]struct{}, len(variants))
+	total := 0
+	for i, variant := range variants {
+		if variant.Name == "" {
+			return fmt.Errorf("%w %d: empty name", ErrInvalidVariant, i)
+		}
+		if _, ok := names[variant.Name]; ok {
+			return fmt.Errorf("%w %d: duplicate name \"%s\"", ErrInvalidVariant, i, variant.Name)
+		}
+		names[variant.Name] = struct{}{}
+		if variant.URL == "" {
+			return fmt.Errorf("%w %d: empty url", ErrInvalidVariant, i)
+		}
+		if variant.Weight < 0 {
+			return fmt.Errorf("%w %d: negative weight", ErrInvalidVariant, i)
+		}
+		total += variant.Weight
+	}
+	if total == 0 {
+		return fmt.Errorf("%w: weights sum must be positive", ErrInvalidVariant)
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+)
+
+// Tags and folder limits
+const (
+	MaxTags      = 20
+	MaxTagLen    = 64
+	MaxFolderLen = 255
+)
+
+// Invalid tags error
+var ErrInvalidTags = errors.New("invalid tags")
+
+// Invalid folder error
+var ErrInvalidFolder = errors.New("invalid folder")
+
+func validateTags(record models.Record) error {
+	if len(record.Tags) > MaxTags {
+		return fmt.Errorf("%w: at most %d tags are allowed", ErrInvalidTags, MaxTags)
+	}
+
+	seen := make(map[string]struct{}, len(record.Tags))
+	for _, tag := range record.Tags {
+		if tag == "" || strings.TrimSpace(tag) != tag || strings.Contains(tag, ",") {
+			return fmt.Errorf("%w: tag \"%s\" must be non-empty, without surrounding spaces and commas", ErrInvalidTags, tag)
+		}
+		if utf8.RuneCountInString(tag) > MaxTagLen {
+			return fmt.Errorf("%w: tag must not be longer than %d characters", ErrInvalidTags, MaxTagLen)
+		}
+		if _, ok := seen[tag]; ok {
+			return fmt.Errorf("%w: duplicate tag \"%s\"", ErrInvalidTags, tag)
+		}
+		seen[tag] = struct{}{}
+	}
+
+	return nil
+}
+
+func validateFolder(record models.Record) error {
+	if utf8.RuneCountInString(record.Folder) > MaxFolderLen {
+		return fmt.Errorf("%w: folder must not be longer than %d characters", ErrInvalidFolder, MaxFolderLen)
+	}
+	if strings.TrimSpace(record.Folder) != record.Folder {
+		return fmt.Errorf("%w: folder must not start or end with spaces", ErrInvalidFolder)
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+// Record settings patch. Nil fields are left unchanged, clear flags reset
+// activity window bounds and fallback URL
+type RecordPatch struct {
+	ActiveFrom   *time.Time            `json:"active_from"`
+	ActiveUntil  *time.Time            `json:"active_until"`
+	FallbackURL  *string               `json:"fallback_url"`
+	Rules        *[]models.RoutingRule `json:"rules"`
+	Variants     *[]models.Variant     `json:"variants"`
+	UTMParams    *map[string]string    `json:"utm_params"`
+	QueryMode    *string               `json:"query_mode"`
+	RedirectCode *int                  `json:"redirect_code"`
+	Title        *string               `json:"title"`
+	Description  *string               `json:"description"`
+	Interstitial *bool                 `json:"interstitial"`
+	Tags         *[]string             `json:"tags"`
+	Folder       *string               `json:"folder"`
+
+	// Set by JSON null of the field, take precedence over its value
+	ClearActiveFrom  bool `json:"-"`
+	ClearActiveUntil bool `json:"-"`
+	ClearFallbackURL bool `json:"-"`
+}
+
+// Decode patch, null active_from, active_until and fallback_url clear them
+func (p *RecordPatch) UnmarshalJSON(data []byte) error {
+	type plainPatch RecordPatch
+	if err := json.Unmarshal(data, (*plainPatch)(p)); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	p.ClearActiveFrom = isNull(fields["active_from"])
+	p.ClearActiveUntil = isNull(fields["active_until"])
+	p.ClearFallbackURL = isNull(fields["fallback_url"])
+
+	return nil
+}
+
+func isNull(value json.RawMessage) bool {
+	return string(value) == "null"
+}
+
+// Apply patch to record
+func (p RecordPatch) Apply(record models.Record) models.Record {
+	if p.ClearActiveFrom {
+		record.ActiveFrom = nil
+	} else if p.ActiveFrom != nil {
+		record.ActiveFrom = p.ActiveFrom
+	}
+	if p.ClearActiveUntil {
+		record.ActiveUntil = nil
+	} else if p.ActiveUntil != nil {
+		record.ActiveUntil = p.ActiveUntil
+	}
+	if p.ClearFallbackURL {
+		record.FallbackURL = ""
+	} else if p.FallbackURL != nil {
+		record.FallbackURL = *p.FallbackURL
+	}
+	if p.Rules != nil {
+		record.Rules = *p.Rules
+	}
+	if p.Variants != nil {
+		record.Variants = *p.Variants
+	}
+	if p.UTMParams != nil {
+		record.UTMParams = *p.UTMParams
+	}
+	if p.QueryMode != nil {
+		record.QueryMode = *p.QueryMode
+	}
+	if p.RedirectCode != nil {
+		record.RedirectCode = *p.RedirectCode
+	}
+	if p.Title != nil {
+		record.Title = *p.Title
+	}
+	if p.Description != nil {
+		record.Description = *p.Description
+	}
+	if p.Interstitial != nil {
+		record.Interstitial = *p.Interstitial
+	}
+	if p.Tags != nil {
+		record.Tags = *p.Tags
+	}
+	if p.Folder != nil {
+		record.Folder = *p.Folder
+	}
+
+	return record
+}
+
+// Interface for updating user shortened URLs
+type URLUpdater interface {
+	Update(context.Context, string, string, RecordPatch, models.User) (models.Record, error)
+}
+
+// RecordUpdater
+type RecordUpdater interface {
+	FindByShortenedPath(ctx context.Context, domain, shortenedPath string) (models.Record, error)
+	Update(ctx context.Context, record models.Record) error
+}
+
+type urlUpdater struct {
+	store RecordUpdater
+}
+
+// NewURLUpdater
+func NewURLUpdater(store RecordUpdater) URLUpdater {
+	return urlUpdater{store: store}
+}
+
+// Update user record. Returns storage.ErrNotFound if user does not own the record
+func (srv urlUpdater) Update(
+	ctx context.Context,
+	domain string,
+	shortenedPath string,
+	patch RecordPatch,
+	user models.User) (models.Record, error) {
+
+	record, err := srv.store.FindByShortenedPath(ctx, domain, shortenedPath)
+	if err != nil {
+		return models.Record{}, err
+	}
+	if record.UserID != user.ID || record.IsDeleted {
+		return models.Record{}, storage.ErrNotFound
+	}
+
+	record = patch.Apply(record)
+	if err = validateRecord(record); err != nil {
+		return models.Record{}, err
+	}
+	record.UpdatedAt = time.Now()
+	if err = srv.store.Update(ctx, record); err != nil {
+		return models.Record{}, err
+	}
+
+	return record, nil
+}
@@ -3,8 +3,12 @@ package storage
 import (
 	"context"
 	"embed"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
+	"strings"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -17,6 +21,7 @@ import (
 
 	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
 	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/tracing"
 )
 
 // PostgreSQL storage
@@ -24,13 +29,23 @@ type DBStorage struct {
 	pool *pgxpool.Pool
 }
 
-// New PostgreSQL storage
+// New PostgreSQL storage. Pending migrations are applied
 func NewDBStorage(dsn string) (*DBStorage, error) {
 	if err := runMigrations(dsn); err != nil {
 		return nil, fmt.Errorf("failed to run DB migrations: %w", err)
 	}
 
-	pool, err := pgxpool.New(context.Background(), dsn)
+	return OpenDBStorage(dsn)
+}
+
+// Open PostgreSQL storage without applying migrations, schema is not changed
+func OpenDBStorage(dsn string) (*DBStorage, error) {
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DSN: %w", err)
+	}
+	config.ConnConfig.Tracer = tracing.QueryTracer{}
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create a connection pool: %w", err)
 	}
@@ -44,13 +59,10 @@ func NewDBStorage(dsn string) (*DBStorage, error) {
 func (db *DBStorage) FindByOriginalURL(ctx context.Context, originalURL string) (models.Record, error) {
 	row := db.pool.QueryRow(
 		ctx,
-		`SELECT "shortened_path",
-				"correlation_id"
-		 FROM "urls" WHERE "original_url" = @originalUrl`,
+		`SELECT `+recordColumns+` FROM "urls" WHERE "original_url" = @originalUrl`,
 		pgx.NamedArgs{"originalUrl": originalURL},
 	)
-	var shortenedPath, correlationID string
-	err := row.Scan(&shortenedPath, &correlationID)
+	record, err := scanRecord(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return models.Record{}, ErrNotFound
@@ -59,24 +71,17 @@ func (db *DBStorage) FindByOriginalURL(ctx context.Context, originalURL string)
 		return models.Record{}, fmt.Errorf("failed to get shortened path: %w", err)
 	}
 
-	return models.Record{
-		OriginalURL:   originalURL,
-		ShortenedPath: shortenedPath,
-		CorrelationID: correlationID,
-	}, nil
+	return record, nil
 }
 
 // Find record by shortened path
-func (db *DBStorage) FindByShortenedPath(ctx context.Context, shortenedPath string) (models.Record, error) {
+func (db *DBStorage) FindByShortenedPath(ctx context.Context, domain, shortenedPath string) (models.Record, error) {
 	row := db.pool.QueryRow(
 		ctx,
-		`SELECT "original_url", "correlation_id", "is_deleted"
-		 FROM "urls" WHERE "shortened_path" = @shortenedPath`,
-		pgx.NamedArgs{"shortenedPath": shortenedPath},
+		`SELECT `+recordColumns+` FROM "urls" WHERE "domain" = @domain AND "shortened_path" = @shortenedPath`,
+		pgx.NamedArgs{"domain": domain, "shortenedPath": shortenedPath},
 	)
-	var originalURL, correlationID string
-	var isDeleted bool
-	err := row.Scan(&originalURL, &correlationID, &isDeleted)
+	record, err := scanRecord(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return models.Record{}, ErrNotFound
@@ -85,38 +90,22 @@ func (db *DBStorage) FindByShortenedPath(ctx context.Context, shortenedPath stri
 		return models.Record{}, fmt.Errorf("failed to get original url: %w", err)
 	}
 
-	return models.Record{
-		OriginalURL:   originalURL,
-		ShortenedPath: shortenedPath,
-		CorrelationID: correlationID,
-		IsDeleted:     isDeleted,
-	}, nil
+	return record, nil
 }
 
-// Find user records
-func (db *DBStorage) FindByUser(ctx context.Context, user models.User) ([]models.Record, error) {
+// Find user records matching filter
+func (db *DBStorage) FindByUser(ctx context.Context, user models.User, filter models.RecordFilter) ([]models.Record, error) {
 	rows, err := db.pool.Query(
 		ctx,
-		`SELECT "original_url", "shortened_path", "correlation_id", "user_id"
-		 FROM "urls"
-		 WHERE "user_id" = @userID`,
-		pgx.NamedArgs{"userID": user.ID},
+		`SELECT `+recordColumns+` FROM "urls" WHERE `+userRecordsCondition,
+		userRecordsArgs(user, filter),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch records: %s", err.Error())
 	}
 
 	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Record, error) {
-		var originalURL, shortenedPath, correlationID string
-		var userID int
-		err = row.Scan(&originalURL, &shortenedPath, &correlationID, &userID)
-
-		return models.Record{
-			OriginalURL:   originalURL,
-			ShortenedPath: shortenedPath,
-			CorrelationID: correlationID,
-			UserID:        userID,
-		}, err
+		return scanRecord(row)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch records: %s", err.Error())
@@ -125,38 +114,243 @@ func (db *DBStorage) FindByUser(ctx context.Context, user models.User) ([]models
 	return result, nil
 }
 
+// Find page of sorted user records matching filter. Returns cursor of the last
+// record if there are more records. Keyset condition and order match the
+// "urls" (user_id, created_at, domain, shortened_path) and
+// (user_id, shortened_path, domain) indexes
+func (db *DBStorage) FindByUserPage(
+	ctx context.Context,
+	user models.User,
+	filter models.RecordFilter,
+	page models.Page) ([]models.Record, *models.Cursor, error) {
+
+	columns := []string{`"created_at"`, `"domain"`, `"shortened_path"`}
+	cursor := `@afterCreatedAt::timestamptz, @afterDomain::varchar, @afterShortenedPath::varchar`
+	if page.SortBy == models.SortByCode {
+		columns = []string{`"shortened_path"`, `"domain"`}
+		cursor = `@afterShortenedPath::varchar, @afterDomain::varchar`
+	}
+	cmp, direction := ">", " ASC"
+	if page.Desc {
+		cmp, direction = "<", " DESC"
+	}
+
+	query := `SELECT ` + recordColumns + ` FROM "urls" WHERE ` + userRecordsCondition
+	args := userRecordsArgs(user, filter)
+	if page.After != nil {
+		query += ` AND (` + strings.Join(columns, ", ") + `) ` + cmp + ` (` + cursor + `)`
+		args["afterCreatedAt"] = page.After.CreatedAt
+		args["afterDomain"] = page.After.Domain
+		args["afterShortenedPath"] = page.After.ShortenedPath
+	}
+	query += ` ORDER BY ` + strings.Join(columns, direction+", ") + direction
+	if page.Limit > 0 {
+		query += ` LIMIT @limit`
+		args["limit"] = page.Limit + 1
+	}
+
+	rows, err := db.pool.Query(ctx, query, args)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch records: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Record, error) {
+		return scanRecord(row)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch records: %w", err)
+	}
+
+	if page.Limit > 0 && len(result) > page.Limit {
+		result = result[:page.Limit]
+		next := models.CursorOf(result[len(result)-1])
+		return result, &next, nil
+	}
+
+	return result, nil, nil
+}
+
+// Visit user records with click counts in creation order. Rows are streamed
+// from database, visit error stops the export and is returned
+func (db *DBStorage) ExportByUser(
+	ctx context.Context,
+	user models.User,
+	includeDeleted bool,
+	visit func(record models.Record, clicks int) error) error {
+
+	rows, err := db.pool.Query(
+		ctx,
+		`SELECT `+recordColumns+`,
+			(SELECT COUNT(*) FROM "url_clicks" AS "c" WHERE "c"."url_id" = "urls"."id")
+		 FROM "urls"
+		 WHERE "user_id" = @userID AND (@includeDeleted OR NOT "is_deleted")
+		 ORDER BY "created_at", "domain", "shortened_path"`,
+		pgx.NamedArgs{"userID": user.ID, "includeDeleted": includeDeleted},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to fetch records: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var clicks int
+		record, err := scanRecord(extraColumnsRow{Row: rows, dest: []any{&clicks}})
+		if err != nil {
+			return fmt.Errorf("failed to fetch records: %w", err)
+		}
+		if err = visit(record, clicks); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to fetch records: %w", err)
+	}
+
+	return nil
+}
+
 // Save record to database
 func (db *DBStorage) Save(ctx context.Context, record models.Record) error {
-	_, err := db.pool.Exec(
+	record = withTimestamps(record, time.Now())
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer rollback(ctx, tx)
+
+	_, err = tx.Exec(
 		ctx,
-		`INSERT INTO "urls" ("original_url", "shortened_path", "user_id") VALUES (@originalURL, @shortenedPath, @user_id)`,
+		`INSERT INTO "urls" (
+			"original_url", "domain", "shortened_path", "user_id", "max_clicks", "remaining_clicks",
+			"active_from", "active_until", "fallback_url", "utm_params", "query_mode",
+			"redirect_code", "title", "interstitial", "created_at", "tags", "folder",
+			"description", "updated_at"
+		 ) VALUES (
+			@originalURL, @domain, @shortenedPath, @user_id, @maxClicks, @remainingClicks,
+			@activeFrom, @activeUntil, @fallbackURL, @utmParams, @queryMode,
+			@redirectCode, @title, @interstitial, @createdAt, @tags, @folder,
+			@description, @updatedAt
+		 )`,
 		pgx.NamedArgs{
-			"originalURL":   record.OriginalURL,
-			"shortenedPath": record.ShortenedPath,
-			"user_id":       record.UserID,
+			"originalURL":     record.OriginalURL,
+			"domain":          record.Domain,
+			"shortenedPath":   record.ShortenedPath,
+			"user_id":         record.UserID,
+			"maxClicks":       record.MaxClicks,
+			"remainingClicks": record.RemainingClicks,
+			"activeFrom":      record.ActiveFrom,
+			"activeUntil":     record.ActiveUntil,
+			"fallbackURL":     record.FallbackURL,
+			"utmParams":       record.UTMParams,
+			"queryMode":       record.QueryMode,
+			"redirectCode":    record.RedirectCode,
+			"title":           record.Title,
+			"interstitial":    record.Interstitial,
+			"createdAt":       record.CreatedAt,
+			"tags":            record.Tags,
+			"folder":          record.Folder,
+			"description":     record.Description,
+			"updatedAt":       record.UpdatedAt,
 		},
 	)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			if pgErr.Code == pgerrcode.UniqueViolation {
-				return NewErrNotUnique(record)
+				// Transaction is aborted, existing record is read outside of it
+				return db.notUniqueError(ctx, record)
 			}
 		}
 		return fmt.Errorf("failed to save original url and shortened path: %w", err)
 	}
 
-	return nil
+	batch := &pgx.Batch{}
+	queueReplaceRules(batch, record)
+	queueReplaceVariants(batch, record)
+	if err = tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to save routing rules and variants: %w", err)
+	}
+
+	return tx.Commit(ctx)
 }
 
-// Batch save records to database
+// Batch save records to database. Nothing is saved if any record conflicts with
+// existing one by original URL or shortened path, which is reported by ErrNotUnique
 func (db *DBStorage) BatchSave(ctx context.Context, records []models.Record) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer rollback(ctx, tx)
+
 	batch := &pgx.Batch{}
+	now := time.Now()
 	for _, r := range records {
+		r = withTimestamps(r, now)
 		batch.Queue(
-			`INSERT INTO "urls" ("original_url", "shortened_path", "user_id") VALUES ($1, $2, $3)
-			 ON CONFLICT ("original_url") DO UPDATE SET "shortened_path" = $2`,
-			r.OriginalURL, r.ShortenedPath, r.UserID,
+			`INSERT INTO "urls" (
+				"original_url", "shortened_path", "user_id", "max_clicks", "remaining_clicks",
+				"active_from", "active_until", "fallback_url", "utm_params", "query_mode",
+				"redirect_code", "title", "interstitial", "created_at", "domain", "tags", "folder",
+				"description", "updated_at"
+			 ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+			 ON CONFLICT DO NOTHING`,
+			r.OriginalURL, r.ShortenedPath, r.UserID, r.MaxClicks, r.RemainingClicks,
+			r.ActiveFrom, r.ActiveUntil, r.FallbackURL, r.UTMParams, r.QueryMode,
+			r.RedirectCode, r.Title, r.Interstitial, r.CreatedAt, r.Domain, r.Tags, r.Folder,
+			r.Description, r.UpdatedAt,
+		)
+	}
+	res := tx.SendBatch(ctx, batch)
+	for _, r := range records {
+		tag, err := res.Exec()
+		if err != nil {
+			closeBatch(res)
+			return fmt.Errorf("failed to batch save: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			closeBatch(res)
+			rollback(ctx, tx)
+			return db.notUniqueError(ctx, r)
+		}
+	}
+	if err = res.Close(); err != nil {
+		return fmt.Errorf("failed to batch save: %w", err)
+	}
+
+	// Every record is inserted, routing rules and variants of existing ones
+	// are not touched
+	batch = &pgx.Batch{}
+	for _, r := range records {
+		queueReplaceRules(batch, r)
+		queueReplaceVariants(batch, r)
+	}
+	if err = tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to save routing rules and variants: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Batch insert records skipping ones conflicting with existing records by
+// original URL or shortened path. Reports which records were inserted.
+// Routing rules and variants are not inserted
+func (db *DBStorage) BatchInsert(ctx context.Context, records []models.Record) ([]bool, error) {
+	batch := &pgx.Batch{}
+	now := time.Now()
+	for _, r := range records {
+		r = withTimestamps(r, now)
+		batch.Queue(
+			`INSERT INTO "urls" (
+				"original_url", "domain", "shortened_path", "user_id", "max_clicks", "remaining_clicks",
+				"active_from", "active_until", "fallback_url", "utm_params", "query_mode",
+				"redirect_code", "title", "interstitial", "created_at", "tags", "folder",
+				"description", "updated_at"
+			 ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+			 ON CONFLICT DO NOTHING`,
+			r.OriginalURL, r.Domain, r.ShortenedPath, r.UserID, r.MaxClicks, r.RemainingClicks,
+			r.ActiveFrom, r.ActiveUntil, r.FallbackURL, r.UTMParams, r.QueryMode,
+			r.RedirectCode, r.Title, r.Interstitial, r.CreatedAt, r.Tags, r.Folder,
+			r.Description, r.UpdatedAt,
 		)
 	}
 	res := db.pool.SendBatch(ctx, batch)
@@ -166,14 +360,70 @@ func (db *DBStorage) BatchSave(ctx context.Context, records []models.Record) err
 		}
 	}()
 
-	for range records {
-		_, err := res.Exec()
+	inserted := make([]bool, len(records))
+	for i := range records {
+		tag, err := res.Exec()
 		if err != nil {
-			return fmt.Errorf("failed to batch save: %w", err)
+			return nil, fmt.Errorf("failed to batch insert: %w", err)
 		}
+		inserted[i] = tag.RowsAffected() > 0
+	}
+
+	return inserted, res.Close()
+}
+
+// Update user record settings
+func (db *DBStorage) Update(ctx context.Context, record models.Record) error {
+	if record.UpdatedAt.IsZero() {
+		record.UpdatedAt = time.Now()
+	}
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer rollback(ctx, tx)
+
+	tag, err := tx.Exec(
+		ctx,
+		`UPDATE "urls"
+		 SET "active_from" = @activeFrom, "active_until" = @activeUntil, "fallback_url" = @fallbackURL,
+		     "utm_params" = @utmParams, "query_mode" = @queryMode, "redirect_code" = @redirectCode,
+		     "title" = @title, "interstitial" = @interstitial, "tags" = @tags, "folder" = @folder,
+		     "description" = @description, "updated_at" = @updatedAt
+		 WHERE "domain" = @domain AND "shortened_path" = @shortenedPath AND "user_id" = @userID`,
+		pgx.NamedArgs{
+			"activeFrom":    record.ActiveFrom,
+			"activeUntil":   record.ActiveUntil,
+			"fallbackURL":   record.FallbackURL,
+			"utmParams":     record.UTMParams,
+			"queryMode":     record.QueryMode,
+			"redirectCode":  record.RedirectCode,
+			"title":         record.Title,
+			"interstitial":  record.Interstitial,
+			"tags":          record.Tags,
+			"folder":        record.Folder,
+			"description":   record.Description,
+			"updatedAt":     record.UpdatedAt,
+			"domain":        record.Domain,
+			"shortenedPath": record.ShortenedPath,
+			"userID":        record.UserID,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update record: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	batch := &pgx.Batch{}
+	queueReplaceRules(batch, record)
+	queueReplaceVariants(batch, record)
+	if err = tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to update routing rules and variants: %w", err)
 	}
 
-	return res.Close()
+	return tx.Commit(ctx)
 }
 
 // Batch delete records from database
@@ -182,8 +432,8 @@ func (db *DBStorage) BatchDelete(ctx context.Context, records []models.Record) e
 	for _, r := range records {
 		batch.Queue(
 			`UPDATE "urls" SET "is_deleted" = TRUE
-			 WHERE "shortened_path" = @shortenedPath AND "user_id" = @userID`,
-			pgx.NamedArgs{"shortenedPath": r.ShortenedPath, "userID": r.UserID},
+			 WHERE "domain" = @domain AND "shortened_path" = @shortenedPath AND "user_id" = @userID`,
+			pgx.NamedArgs{"domain": r.Domain, "shortenedPath": r.ShortenedPath, "userID": r.UserID},
 		)
 	}
 	err := db.pool.SendBatch(ctx, &batch).Close()
@@ -194,6 +444,87 @@ func (db *DBStorage) BatchDelete(ctx context.Context, records []models.Record) e
 	return nil
 }
 
+// Consume one click of click limited record
+func (db *DBStorage) ConsumeClick(ctx context.Context, domain, shortenedPath string) (int, error) {
+	row := db.pool.QueryRow(
+		ctx,
+		`UPDATE "urls" SET "remaining_clicks" = "remaining_clicks" - 1
+		 WHERE "domain" = @domain AND "shortened_path" = @shortenedPath
+		   AND "max_clicks" > 0 AND "remaining_clicks" > 0
+		 RETURNING "remaining_clicks"`,
+		pgx.NamedArgs{"domain": domain, "shortenedPath": shortenedPath},
+	)
+	var remainingClicks int
+	err := row.Scan(&remainingClicks)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrClicksExhausted
+		}
+
+		return 0, fmt.Errorf("failed to consume click: %w", err)
+	}
+
+	return remainingClicks, nil
+}
+
+// Save click on shortened URL
+func (db *DBStorage) SaveClick(ctx context.Context, click models.Click) error {
+	tag, err := db.pool.Exec(
+		ctx,
+		`INSERT INTO "url_clicks" ("url_id", "variant", "created_at")
+		 SELECT "id", @variant, @createdAt FROM "urls"
+		 WHERE "domain" = @domain AND "shortened_path" = @shortenedPath`,
+		pgx.NamedArgs{
+			"domain":        click.Domain,
+			"variant":       click.Variant,
+			"createdAt":     click.CreatedAt,
+			"shortenedPath": click.ShortenedPath,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save click: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Click statistics of shortened URL
+func (db *DBStorage) ClickStats(ctx context.Context, domain, shortenedPath string) (models.ClickStats, error) {
+	rows, err := db.pool.Query(
+		ctx,
+		`SELECT "c"."variant", COUNT(*)
+		 FROM "url_clicks" AS "c" JOIN "urls" AS "u" ON "u"."id" = "c"."url_id"
+		 WHERE "u"."domain" = @domain AND "u"."shortened_path" = @shortenedPath
+		 GROUP BY "c"."variant"`,
+		pgx.NamedArgs{"domain": domain, "shortenedPath": shortenedPath},
+	)
+	if err != nil {
+		return models.ClickStats{}, fmt.Errorf("failed to fetch click stats: %w", err)
+	}
+
+	stats := models.ClickStats{}
+	var variant string
+	var count int
+	_, err = pgx.ForEachRow(rows, []any{&variant, &count}, func() error {
+		stats.Total += count
+		if variant != "" {
+			if stats.ByVariant == nil {
+				stats.ByVariant = make(map[string]int)
+			}
+			stats.ByVariant[variant] = count
+		}
+		return nil
+	})
+	if err != nil {
+		return models.ClickStats{}, fmt.Errorf("failed to fetch click stats: %w", err)
+	}
+
+	return stats, nil
+}
+
 // Create user
 func (db *DBStorage) CreateUser(ctx context.Context) (models.User, error) {
 	row := db.pool.QueryRow(ctx, `INSERT INTO "users" ("id") VALUES (DEFAULT) RETURNING "id"`)
@@ -230,30 +561,388 @@ func (db *DBStorage) UsersCount(ctx context.Context) (int, error) {
 	return usersCount, nil
 }
 
+// Check database is reachable
+func (db *DBStorage) Ping(ctx context.Context) error {
+	if err := db.pool.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return nil
+}
+
 // Close connection to database
 func (db *DBStorage) Close() {
 	db.pool.Close()
 }
 
+// Connection pool statistics
+func (db *DBStorage) Stat() *pgxpool.Stat {
+	return db.pool.Stat()
+}
+
+// All users in ID order
+func (db *DBStorage) Users(ctx context.Context) ([]models.User, error) {
+	rows, err := db.pool.Query(ctx, `SELECT "id" FROM "users" ORDER BY "id"`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %w", err)
+	}
+
+	users := make([]models.User, 0)
+	var user models.User
+	_, err = pgx.ForEachRow(rows, []any{&user.ID}, func() error {
+		users = append(users, user)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %w", err)
+	}
+
+	return users, nil
+}
+
+// Visit all records including deleted ones in insertion order
+func (db *DBStorage) Records(ctx context.Context, visit func(record models.Record) error) error {
+	rows, err := db.pool.Query(ctx, `SELECT `+recordColumns+` FROM "urls" ORDER BY "id"`)
+	if err != nil {
+		return fmt.Errorf("failed to fetch records: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			return fmt.Errorf("failed to fetch records: %w", err)
+		}
+		if err = visit(record); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to fetch records: %w", err)
+	}
+
+	return nil
+}
+
+// Restore users and records keeping user IDs, shortened paths and deletion
+// state in one transaction. Fails if any user or record already exists. Users
+// created afterwards get IDs greater than restored ones
+func (db *DBStorage) Restore(ctx context.Context, users []models.User, records []models.Record) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer rollback(ctx, tx)
+
+	batch := &pgx.Batch{}
+	for _, user := range users {
+		batch.Queue(`INSERT INTO "users" ("id") VALUES ($1)`, user.ID)
+	}
+	batch.Queue(`SELECT setval(pg_get_serial_sequence('users', 'id'), GREATEST(MAX("id"), 1)) FROM "users"`)
+	now := time.Now()
+	for _, r := range records {
+		r = withTimestamps(r, now)
+		batch.Queue(
+			`INSERT INTO "urls" (
+				"original_url", "domain", "shortened_path", "correlation_id", "user_id", "is_deleted",
+				"max_clicks", "remaining_clicks", "active_from", "active_until", "fallback_url",
+				"utm_params", "query_mode", "redirect_code", "title", "interstitial", "created_at",
+				"tags", "folder", "description", "updated_at"
+			 ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)`,
+			r.OriginalURL, r.Domain, r.ShortenedPath, r.CorrelationID, r.UserID, r.IsDeleted,
+			r.MaxClicks, r.RemainingClicks, r.ActiveFrom, r.ActiveUntil, r.FallbackURL,
+			r.UTMParams, r.QueryMode, r.RedirectCode, r.Title, r.Interstitial, r.CreatedAt,
+			r.Tags, r.Folder, r.Description, r.UpdatedAt,
+		)
+		queueReplaceRules(batch, r)
+		queueReplaceVariants(batch, r)
+	}
+	if err = tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to restore records: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+const userRecordsCondition = `"user_id" = @userID
+	AND (@folder = '' OR "folder" = @folder)
+	AND (COALESCE(cardinality(@tags::text[]), 0) = 0 OR "tags" @> @tags::text[])
+	AND (@query = '' OR strpos(lower("original_url"), lower(@query)) > 0
+	     OR strpos(lower("shortened_path"), lower(@query)) > 0)
+	AND (@createdFrom::timestamptz IS NULL OR "created_at" >= @createdFrom)
+	AND (@createdTo::timestamptz IS NULL OR "created_at" < @createdTo)
+	AND (NOT @excludeDeleted::boolean OR NOT "is_deleted")`
+
+func userRecordsArgs(user models.User, filter models.RecordFilter) pgx.NamedArgs {
+	return pgx.NamedArgs{
+		"userID":         user.ID,
+		"folder":         filter.Folder,
+		"tags":           filter.Tags,
+		"query":          filter.Query,
+		"createdFrom":    filter.CreatedFrom,
+		"createdTo":      filter.CreatedTo,
+		"excludeDeleted": filter.ExcludeDeleted,
+	}
+}
+
+const recordColumns = `"original_url", "domain", "shortened_path", "correlation_id", "user_id", "is_deleted",
+	"max_clicks", "remaining_clicks", "active_from", "active_until", "fallback_url",
+	"utm_params", "query_mode", "redirect_code", "title", "interstitial", "created_at",
+	"tags", "folder", "description", "updated_at",
+	COALESCE((
+		SELECT json_agg(json_build_object(
+			'field', "r"."field", 'pattern', "r"."pattern", 'destination', "r"."destination"
+		) ORDER BY "r"."position")
+		FROM "url_routing_rules" AS "r" WHERE "r"."url_id" = "urls"."id"
+	), '[]'),
+	COALESCE((
+		SELECT json_agg(json_build_object(
+			'name', "v"."name", 'url', "v"."url", 'weight', "v"."weight"
+		) ORDER BY "v"."position")
+		FROM "url_variants" AS "v" WHERE "v"."url_id" = "urls"."id"
+	), '[]')`
+
+// Row with columns selected after record columns
+type extraColumnsRow struct {
+	pgx.Row
+	dest []any
+}
+
+func (r extraColumnsRow) Scan(dest ...any) error {
+	return r.Row.Scan(append(dest, r.dest...)...)
+}
+
+func scanRecord(row pgx.Row) (models.Record, error) {
+	var record models.Record
+	var rules, variants []byte
+	err := row.Scan(
+		&record.OriginalURL,
+		&record.Domain,
+		&record.ShortenedPath,
+		&record.CorrelationID,
+		&record.UserID,
+		&record.IsDeleted,
+		&record.MaxClicks,
+		&record.RemainingClicks,
+		&record.ActiveFrom,
+		&record.ActiveUntil,
+		&record.FallbackURL,
+		&record.UTMParams,
+		&record.QueryMode,
+		&record.RedirectCode,
+		&record.Title,
+		&record.Interstitial,
+		&record.CreatedAt,
+		&record.Tags,
+		&record.Folder,
+		&record.Description,
+		&record.UpdatedAt,
+		&rules,
+		&variants,
+	)
+	if err != nil {
+		return record, err
+	}
+	if err = json.Unmarshal(rules, &record.Rules); err != nil {
+		return record, fmt.Errorf("failed to parse routing rules: %w", err)
+	}
+	if len(record.Rules) == 0 {
+		record.Rules = nil
+	}
+	if err = json.Unmarshal(variants, &record.Variants); err != nil {
+		return record, fmt.Errorf("failed to parse variants: %w", err)
+	}
+	if len(record.Variants) == 0 {
+		record.Variants = nil
+	}
+
+	return record, nil
+}
+
+func queueReplaceRules(batch *pgx.Batch, record models.Record) {
+	batch.Queue(
+		`DELETE FROM "url_routing_rules"
+		 WHERE "url_id" = (SELECT "id" FROM "urls" WHERE "domain" = $1 AND "shortened_path" = $2)`,
+		record.Domain, record.ShortenedPath,
+	)
+	for i, rule := range record.Rules {
+		batch.Queue(
+			`INSERT INTO "url_routing_rules" ("url_id", "position", "field", "pattern", "destination")
+			 SELECT "id", $3, $4, $5, $6 FROM "urls" WHERE "domain" = $1 AND "shortened_path" = $2`,
+			record.Domain, record.ShortenedPath, i, rule.Field, rule.Pattern, rule.Destination,
+		)
+	}
+}
+
+func queueReplaceVariants(batch *pgx.Batch, record models.Record) {
+	batch.Queue(
+		`DELETE FROM "url_variants"
+		 WHERE "url_id" = (SELECT "id" FROM "urls" WHERE "domain" = $1 AND "shortened_path" = $2)`,
+		record.Domain, record.ShortenedPath,
+	)
+	for i, variant := range record.Variants {
+		batch.Queue(
+			`INSERT INTO "url_variants" ("url_id", "position", "name", "url", "weight")
+			 SELECT "id", $3, $4, $5, $6 FROM "urls" WHERE "domain" = $1 AND "shortened_path" = $2`,
+			record.Domain, record.ShortenedPath, i, variant.Name, variant.URL, variant.Weight,
+		)
+	}
+}
+
+// Not unique error of record conflicting with existing one. Existing record is
+// reported if found
+func (db *DBStorage) notUniqueError(ctx context.Context, record models.Record) error {
+	if existing, err := db.FindByOriginalURL(ctx, record.OriginalURL); err == nil {
+		return NewErrNotUnique(existing)
+	}
+	return NewErrNotUnique(record)
+}
+
+func closeBatch(res pgx.BatchResults) {
+	if err := res.Close(); err != nil {
+		logger.Log.Info("closing batch result", zap.Error(err))
+	}
+}
+
+func rollback(ctx context.Context, tx pgx.Tx) {
+	if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		logger.Log.Info("failed to rollback transaction", zap.Error(err))
+	}
+}
+
 //go:embed db/migrations/*.sql
 var migrationsDir embed.FS
 
 func runMigrations(dsn string) error {
+	migrator, err := NewMigrator(dsn)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	_, err = migrator.Up(0)
+	return err
+}
+
+// Version of the last embedded migration
+func LatestSchemaVersion() (uint, error) {
 	d, err := iofs.New(migrationsDir, "db/migrations")
 	if err != nil {
-		return fmt.Errorf("failed to return an iofs driver: %w", err)
+		return 0, fmt.Errorf("failed to return an iofs driver: %w", err)
+	}
+	defer d.Close()
+
+	version, err := d.First()
+	for err == nil {
+		var next uint
+		if next, err = d.Next(version); err == nil {
+			version = next
+		}
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	return version, nil
+}
+
+// Schema version applied by migrator, read without changing the database.
+// Version is zero if no migration is applied
+func (db *DBStorage) SchemaVersion(ctx context.Context) (version uint, dirty bool, err error) {
+	err = db.pool.QueryRow(ctx, `SELECT "version", "dirty" FROM "schema_migrations" LIMIT 1`).
+		Scan(&version, &dirty)
+	var pgErr *pgconn.PgError
+	if errors.Is(err, pgx.ErrNoRows) || (errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UndefinedTable) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	return version, dirty, nil
+}
+
+// Migrator of database schema by embedded migrations
+type Migrator struct {
+	m *migrate.Migrate
+}
+
+// New migrator of database
+func NewMigrator(dsn string) (*Migrator, error) {
+	d, err := iofs.New(migrationsDir, "db/migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to return an iofs driver: %w", err)
 	}
 
 	m, err := migrate.NewWithSourceInstance("iofs", d, dsn)
 	if err != nil {
-		return fmt.Errorf("failed to get a new migrate instance: %w", err)
+		return nil, fmt.Errorf("failed to get a new migrate instance: %w", err)
 	}
 
-	if err := m.Up(); err != nil {
-		if !errors.Is(err, migrate.ErrNoChange) {
-			return fmt.Errorf("failed to apply migrations: %w", err)
-		}
+	return &Migrator{m: m}, nil
+}
+
+// Apply steps migrations, all pending ones if steps is zero. Reports if schema
+// changed
+func (mg *Migrator) Up(steps int) (bool, error) {
+	var err error
+	if steps > 0 {
+		err = mg.m.Steps(steps)
+	} else {
+		err = mg.m.Up()
 	}
 
-	return nil
+	return mg.result(err, "failed to apply migrations")
+}
+
+// Roll back steps migrations, all applied ones if steps is zero. Reports if
+// schema changed
+func (mg *Migrator) Down(steps int) (bool, error) {
+	var err error
+	if steps > 0 {
+		err = mg.m.Steps(-steps)
+	} else {
+		err = mg.m.Down()
+	}
+
+	return mg.result(err, "failed to roll back migrations")
+}
+
+// Current schema version. Version is zero if no migration is applied, dirty
+// schema needs manual fix after failed migration
+func (mg *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = mg.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	return version, dirty, nil
+}
+
+// Close source and database of migrator
+func (mg *Migrator) Close() error {
+	sourceErr, dbErr := mg.m.Close()
+	if sourceErr != nil {
+		return sourceErr
+	}
+
+	return dbErr
+}
+
+func (mg *Migrator) result(err error, msg string) (bool, error) {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return false, nil
+	}
+	// Stepping past the first or the last migration
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("%s: no more migrations", msg)
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", msg, err)
+	}
+
+	return true, nil
 }
@@ -0,0 +1,3 @@
+ALTER TABLE "urls"
+DROP COLUMN "max_clicks",
+DROP COLUMN "remaining_clicks";
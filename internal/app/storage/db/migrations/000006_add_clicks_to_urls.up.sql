@@ -0,0 +1,3 @@
+ALTER TABLE "urls"
+ADD COLUMN "max_clicks" integer NOT NULL DEFAULT 0,
+ADD COLUMN "remaining_clicks" integer NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+ALTER TABLE "urls"
+DROP COLUMN "active_from",
+DROP COLUMN "active_until",
+DROP COLUMN "fallback_url";
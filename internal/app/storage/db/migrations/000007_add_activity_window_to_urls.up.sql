@@ -0,0 +1,4 @@
+ALTER TABLE "urls"
+ADD COLUMN "active_from" timestamptz,
+ADD COLUMN "active_until" timestamptz,
+ADD COLUMN "fallback_url" varchar(499) NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+CREATE TABLE "url_routing_rules" (
+    "id" bigserial PRIMARY KEY,
+    "url_id" bigint NOT NULL REFERENCES "urls" ("id") ON DELETE CASCADE,
+    "position" integer NOT NULL,
+    "field" varchar(32) NOT NULL,
+    "pattern" varchar(499) NOT NULL,
+    "destination" varchar(499) NOT NULL,
+    UNIQUE ("url_id", "position")
+);
@@ -0,0 +1,2 @@
+DROP TABLE "url_clicks";
+DROP TABLE "url_variants";
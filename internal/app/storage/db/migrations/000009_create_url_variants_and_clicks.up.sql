@@ -0,0 +1,18 @@
+CREATE TABLE "url_variants" (
+    "id" bigserial PRIMARY KEY,
+    "url_id" bigint NOT NULL REFERENCES "urls" ("id") ON DELETE CASCADE,
+    "position" integer NOT NULL,
+    "name" varchar(64) NOT NULL,
+    "url" varchar(499) NOT NULL,
+    "weight" integer NOT NULL,
+    UNIQUE ("url_id", "position"),
+    UNIQUE ("url_id", "name")
+);
+
+CREATE TABLE "url_clicks" (
+    "id" bigserial PRIMARY KEY,
+    "url_id" bigint NOT NULL REFERENCES "urls" ("id") ON DELETE CASCADE,
+    "variant" varchar(64) NOT NULL DEFAULT '',
+    "created_at" timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX "url_clicks_url_id_idx" ON "url_clicks" ("url_id");
@@ -0,0 +1,3 @@
+ALTER TABLE "urls"
+DROP COLUMN "utm_params",
+DROP COLUMN "query_mode";
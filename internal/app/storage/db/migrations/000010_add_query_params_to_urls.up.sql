@@ -0,0 +1,3 @@
+ALTER TABLE "urls"
+ADD COLUMN "utm_params" jsonb,
+ADD COLUMN "query_mode" varchar(16) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE "urls"
+ADD COLUMN "redirect_code" integer NOT NULL DEFAULT 0;
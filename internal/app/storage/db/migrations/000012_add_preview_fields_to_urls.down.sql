@@ -0,0 +1,4 @@
+ALTER TABLE "urls"
+DROP COLUMN "title",
+DROP COLUMN "interstitial",
+DROP COLUMN "created_at";
@@ -0,0 +1,4 @@
+ALTER TABLE "urls"
+ADD COLUMN "title" varchar(255) NOT NULL DEFAULT '',
+ADD COLUMN "interstitial" boolean NOT NULL DEFAULT FALSE,
+ADD COLUMN "created_at" timestamptz NOT NULL DEFAULT now();
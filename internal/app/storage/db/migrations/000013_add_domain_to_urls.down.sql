@@ -0,0 +1,14 @@
+-- Links of additional domains can not be kept without domain column, refuse
+-- to roll back instead of deleting them
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM "urls" WHERE "domain" <> '') THEN
+        RAISE EXCEPTION 'urls of non-default domains exist, move or delete them before rolling back';
+    END IF;
+END
+$$;
+
+ALTER TABLE "urls"
+DROP CONSTRAINT "urls_domain_shortened_path_key",
+DROP COLUMN "domain",
+ADD CONSTRAINT "urls_shortened_path_key" UNIQUE ("shortened_path");
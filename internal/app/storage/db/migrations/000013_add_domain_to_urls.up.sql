@@ -0,0 +1,4 @@
+ALTER TABLE "urls"
+ADD COLUMN "domain" varchar(255) NOT NULL DEFAULT '',
+DROP CONSTRAINT "urls_shortened_path_key",
+ADD CONSTRAINT "urls_domain_shortened_path_key" UNIQUE ("domain", "shortened_path");
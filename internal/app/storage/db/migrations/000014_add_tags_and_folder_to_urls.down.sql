@@ -0,0 +1,6 @@
+DROP INDEX "urls_user_id_folder_idx";
+DROP INDEX "urls_tags_idx";
+
+ALTER TABLE "urls"
+DROP COLUMN "tags",
+DROP COLUMN "folder";
@@ -0,0 +1,6 @@
+ALTER TABLE "urls"
+ADD COLUMN "tags" text[],
+ADD COLUMN "folder" varchar(255) NOT NULL DEFAULT '';
+
+CREATE INDEX "urls_tags_idx" ON "urls" USING GIN ("tags");
+CREATE INDEX "urls_user_id_folder_idx" ON "urls" ("user_id", "folder");
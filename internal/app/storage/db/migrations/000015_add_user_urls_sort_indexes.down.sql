@@ -0,0 +1,2 @@
+DROP INDEX "urls_user_id_shortened_path_idx";
+DROP INDEX "urls_user_id_created_at_idx";
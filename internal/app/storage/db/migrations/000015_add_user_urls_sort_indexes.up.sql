@@ -0,0 +1,2 @@
+CREATE INDEX "urls_user_id_created_at_idx" ON "urls" ("user_id", "created_at", "domain", "shortened_path");
+CREATE INDEX "urls_user_id_shortened_path_idx" ON "urls" ("user_id", "shortened_path", "domain");
@@ -0,0 +1,3 @@
+ALTER TABLE "urls"
+DROP COLUMN "description",
+DROP COLUMN "updated_at";
@@ -0,0 +1,5 @@
+ALTER TABLE "urls"
+ADD COLUMN "description" text NOT NULL DEFAULT '',
+ADD COLUMN "updated_at" timestamptz NOT NULL DEFAULT now();
+
+UPDATE "urls" SET "updated_at" = "created_at";
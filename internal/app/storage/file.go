@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
 	"github.com/ilya-burinskiy/urlshort/internal/app/models"
@@ -51,21 +52,145 @@ func (fs *FileStorage) Snapshot() ([]models.Record, error) {
 
 // Save records to file
 func (fs *FileStorage) Dump(ms *MapStorage) error {
+	return fs.Write(ms.records)
+}
+
+// Replace file content with records
+func (fs *FileStorage) Write(records []models.Record) error {
 	file, err := os.OpenFile(fs.filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return fmt.Errorf("could not dump storage: %w", err)
 	}
 
+	if err = writeRecords(file, records); err != nil {
+		return fmt.Errorf("could not dump storage: %w", err)
+	}
+
+	return nil
+}
+
+// Snapshot check result. Line numbers start from 1
+type SnapshotReport struct {
+	Lines   int
+	Records int
+	Deleted int
+	Users   int
+	// Lines which are not records
+	MalformedLines []int
+	// Records without original URL or shortened path
+	InvalidLines []int
+	// Records with original URL or shortened path of a previous record. Restore
+	// keeps the first one
+	DuplicateLines []int
+}
+
+// Snapshot has nothing to compact
+func (r SnapshotReport) OK() bool {
+	return len(r.MalformedLines) == 0 && len(r.InvalidLines) == 0 && len(r.DuplicateLines) == 0
+}
+
+// Check every line of file. Unlike Snapshot it fails if file does not exist
+func (fs *FileStorage) Verify() (SnapshotReport, error) {
+	report, _, err := fs.check(false)
+	return report, err
+}
+
+// Rewrite file without malformed, invalid and duplicate records, and without
+// deleted records if purgeDeleted is set. File is replaced atomically, report
+// describes file before compaction
+func (fs *FileStorage) Compact(purgeDeleted bool) (SnapshotReport, error) {
+	report, records, err := fs.check(purgeDeleted)
+	if err != nil {
+		return report, err
+	}
+
+	info, err := os.Stat(fs.filePath)
+	if err != nil {
+		return report, fmt.Errorf("could not compact snapshot: %w", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(fs.filePath), filepath.Base(fs.filePath)+".*.tmp")
+	if err != nil {
+		return report, fmt.Errorf("could not compact snapshot: %w", err)
+	}
+	tmpPath := file.Name()
+	if err = file.Chmod(info.Mode().Perm()); err == nil {
+		err = writeRecords(file, records)
+	} else {
+		file.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, fs.filePath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return report, fmt.Errorf("could not compact snapshot: %w", err)
+	}
+
+	return report, nil
+}
+
+// Report of file and records restore would keep
+func (fs *FileStorage) check(skipDeleted bool) (SnapshotReport, []models.Record, error) {
+	file, err := os.Open(fs.filePath)
+	if err != nil {
+		return SnapshotReport{}, nil, fmt.Errorf("could not read snapshot: %w", err)
+	}
+	defer file.Close()
+
+	var report SnapshotReport
+	records := make([]models.Record, 0)
+	originalURLs := make(map[string]struct{})
+	shortKeys := make(map[shortKey]struct{})
+	users := make(map[int]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		report.Lines++
+		var r models.Record
+		if err = json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			report.MalformedLines = append(report.MalformedLines, report.Lines)
+			continue
+		}
+		if r.OriginalURL == "" || r.ShortenedPath == "" {
+			report.InvalidLines = append(report.InvalidLines, report.Lines)
+			continue
+		}
+		_, urlExists := originalURLs[r.OriginalURL]
+		_, pathExists := shortKeys[recordKey(r)]
+		if urlExists || pathExists {
+			report.DuplicateLines = append(report.DuplicateLines, report.Lines)
+			continue
+		}
+		originalURLs[r.OriginalURL] = struct{}{}
+		shortKeys[recordKey(r)] = struct{}{}
+		if r.UserID > 0 {
+			users[r.UserID] = struct{}{}
+		}
+
+		report.Records++
+		if r.IsDeleted {
+			report.Deleted++
+			if skipDeleted {
+				continue
+			}
+		}
+		records = append(records, r)
+	}
+	if err = scanner.Err(); err != nil {
+		return report, nil, fmt.Errorf("could not read snapshot line %d: %w", report.Lines+1, err)
+	}
+	report.Users = len(users)
+
+	return report, records, nil
+}
+
+// Write records as JSON lines and close file
+func writeRecords(file *os.File, records []models.Record) error {
 	encoder := json.NewEncoder(file)
-	// NOTE: maybe define some Iter method for MapStorage
-	for _, r := range ms.records {
-		if err = encoder.Encode(r); err != nil {
+	for _, r := range records {
+		if err := encoder.Encode(r); err != nil {
 			logger.Log.Info("failed to dump storage", zap.Error(err))
 		}
 	}
-	if err = file.Close(); err != nil {
-		return fmt.Errorf("could not dump storage: %w", err)
-	}
 
-	return nil
+	return file.Close()
 }
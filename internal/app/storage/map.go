@@ -2,36 +2,60 @@ package storage
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/ilya-burinskiy/urlshort/internal/app/logger"
 	"github.com/ilya-burinskiy/urlshort/internal/app/models"
 	"go.uber.org/zap"
 )
 
-// Inmemory storage
+// Inmemory storage. File storage keeps records only, click statistics are not
+// dumped and start from zero after restore
 type MapStorage struct {
+	mu                   sync.RWMutex
 	fs                   *FileStorage
 	indexOnOriginalURL   map[string]int
-	indexOnShortenedPath map[string]int
+	indexOnShortenedPath map[shortKey]int
 	indexOnUserID        map[int]map[int]struct{}
+	sortedIndexes        map[string]*sortedIndex
 	records              []models.Record
+	clicks               map[shortKey]models.ClickStats
 	userID               int
 }
 
+// Shortened paths are unique within domain
+type shortKey struct {
+	domain string
+	path   string
+}
+
+func recordKey(r models.Record) shortKey {
+	return shortKey{domain: r.Domain, path: r.ShortenedPath}
+}
+
 // New inmemory storage
 func NewMapStorage(fs *FileStorage) *MapStorage {
 	return &MapStorage{
 		records:              make([]models.Record, 0),
 		indexOnOriginalURL:   make(map[string]int),
-		indexOnShortenedPath: make(map[string]int),
+		indexOnShortenedPath: make(map[shortKey]int),
 		indexOnUserID:        make(map[int]map[int]struct{}),
+		clicks:               make(map[shortKey]models.ClickStats),
 		userID:               1,
 		fs:                   fs,
+		sortedIndexes: map[string]*sortedIndex{
+			models.SortByCreatedAt: newSortedIndex(lessByCreatedAt),
+			models.SortByCode:      newSortedIndex(lessByCode),
+		},
 	}
 }
 
 // Find record by original URL
 func (ms *MapStorage) FindByOriginalURL(ctx context.Context, originalURL string) (models.Record, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
 	idx, ok := ms.indexOnOriginalURL[originalURL]
 	if !ok {
 		return models.Record{}, ErrNotFound
@@ -41,8 +65,11 @@ func (ms *MapStorage) FindByOriginalURL(ctx context.Context, originalURL string)
 }
 
 // Find record by shortened path
-func (ms *MapStorage) FindByShortenedPath(ctx context.Context, shortenedPath string) (models.Record, error) {
-	idx, ok := ms.indexOnShortenedPath[shortenedPath]
+func (ms *MapStorage) FindByShortenedPath(ctx context.Context, domain, shortenedPath string) (models.Record, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	idx, ok := ms.indexOnShortenedPath[shortKey{domain: domain, path: shortenedPath}]
 	if !ok {
 		return models.Record{}, ErrNotFound
 	}
@@ -50,76 +77,216 @@ func (ms *MapStorage) FindByShortenedPath(ctx context.Context, shortenedPath str
 	return ms.records[idx], nil
 }
 
-// Find user records
-func (ms *MapStorage) FindByUser(ctx context.Context, user models.User) ([]models.Record, error) {
-	result := make([]models.Record, 0)
-	userRecordsIdx, ok := ms.indexOnUserID[user.ID]
+// Find user records matching filter in creation order
+func (ms *MapStorage) FindByUser(ctx context.Context, user models.User, filter models.RecordFilter) ([]models.Record, error) {
+	result, _, err := ms.FindByUserPage(ctx, user, filter, models.Page{SortBy: models.SortByCreatedAt})
+
+	return result, err
+}
+
+// Find page of sorted user records matching filter. Returns cursor of the last
+// record if there are more records
+func (ms *MapStorage) FindByUserPage(
+	ctx context.Context,
+	user models.User,
+	filter models.RecordFilter,
+	page models.Page) ([]models.Record, *models.Cursor, error) {
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	index, ok := ms.sortedIndexes[page.SortBy]
 	if !ok {
-		return result, nil
+		index = ms.sortedIndexes[models.SortByCreatedAt]
 	}
-
-	for idx := range userRecordsIdx {
+	result := make([]models.Record, 0)
+	var next *models.Cursor
+	index.scan(ms.records, user.ID, page.After, page.Desc, func(idx int) bool {
+		if !filter.Matches(ms.records[idx]) {
+			return true
+		}
+		if page.Limit > 0 && len(result) == page.Limit {
+			cursor := models.CursorOf(result[len(result)-1])
+			next = &cursor
+			return false
+		}
 		result = append(result, ms.records[idx])
+		return true
+	})
+
+	return result, next, nil
+}
+
+// Visit user records with click counts in creation order. Records are copied
+// before visiting, so visit may call storage
+func (ms *MapStorage) ExportByUser(
+	ctx context.Context,
+	user models.User,
+	includeDeleted bool,
+	visit func(record models.Record, clicks int) error) error {
+
+	ms.mu.RLock()
+	records := make([]models.Record, 0)
+	clicks := make([]int, 0)
+	ms.sortedIndexes[models.SortByCreatedAt].scan(ms.records, user.ID, nil, false, func(idx int) bool {
+		record := ms.records[idx]
+		if includeDeleted || !record.IsDeleted {
+			records = append(records, record)
+			clicks = append(clicks, ms.clicks[recordKey(record)].Total)
+		}
+		return true
+	})
+	ms.mu.RUnlock()
+
+	for i, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := visit(record, clicks[i]); err != nil {
+			return err
+		}
 	}
 
-	return result, nil
+	return nil
 }
 
 // Save record
 func (ms *MapStorage) Save(ctx context.Context, r models.Record) error {
-	_, ok := ms.indexOnOriginalURL[r.OriginalURL]
+	r = withTimestamps(r, time.Now())
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	existingIdx, ok := ms.indexOnOriginalURL[r.OriginalURL]
 	if ok {
-		return NewErrNotUnique(r)
+		return NewErrNotUnique(ms.records[existingIdx])
 	}
 
 	ms.records = append(ms.records, r)
 	idx := len(ms.records) - 1
 	ms.indexOnOriginalURL[r.OriginalURL] = idx
-	ms.indexOnShortenedPath[r.ShortenedPath] = idx
+	ms.indexOnShortenedPath[recordKey(r)] = idx
 	_, ok = ms.indexOnUserID[r.UserID]
 	if !ok {
 		ms.indexOnUserID[r.UserID] = make(map[int]struct{})
 	}
 	ms.indexOnUserID[r.UserID][idx] = struct{}{}
+	for _, index := range ms.sortedIndexes {
+		index.insert(ms.records, idx)
+	}
 
 	return nil
 }
 
-// Batch save records
+// Batch save records. Nothing is saved if any record conflicts with existing
+// one by original URL or shortened path, which is reported by ErrNotUnique
 func (ms *MapStorage) BatchSave(ctx context.Context, records []models.Record) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	originalURLs := make(map[string]struct{}, len(records))
+	for _, r := range records {
+		if idx, ok := ms.indexOnOriginalURL[r.OriginalURL]; ok {
+			return NewErrNotUnique(ms.records[idx])
+		}
+		if idx, ok := ms.indexOnShortenedPath[recordKey(r)]; ok {
+			return NewErrNotUnique(ms.records[idx])
+		}
+		if _, ok := originalURLs[r.OriginalURL]; ok {
+			return NewErrNotUnique(r)
+		}
+		originalURLs[r.OriginalURL] = struct{}{}
+	}
+
+	now := time.Now()
 	for _, r := range records {
-		idx, ok := ms.indexOnOriginalURL[r.OriginalURL]
-		if ok {
-			oldRecord := ms.records[idx]
-			delete(ms.indexOnShortenedPath, oldRecord.ShortenedPath)
-			delete(ms.indexOnUserID[oldRecord.UserID], idx)
-
-			ms.records[idx] = r
-			ms.indexOnShortenedPath[r.ShortenedPath] = idx
-			_, ok = ms.indexOnUserID[r.UserID]
-			if !ok {
-				ms.indexOnUserID[r.UserID] = make(map[int]struct{})
-			}
-			ms.indexOnUserID[r.UserID][idx] = struct{}{}
-		} else {
-			ms.records = append(ms.records, r)
-			idx = len(ms.records) - 1
-			ms.indexOnOriginalURL[r.OriginalURL] = idx
-			ms.indexOnShortenedPath[r.ShortenedPath] = idx
-			_, ok := ms.indexOnUserID[r.UserID]
-			if !ok {
-				ms.indexOnUserID[r.UserID] = make(map[int]struct{})
-			}
-			ms.indexOnUserID[r.UserID][idx] = struct{}{}
+		ms.records = append(ms.records, withTimestamps(r, now))
+		idx := len(ms.records) - 1
+		ms.indexOnOriginalURL[r.OriginalURL] = idx
+		ms.indexOnShortenedPath[recordKey(r)] = idx
+		if _, ok := ms.indexOnUserID[r.UserID]; !ok {
+			ms.indexOnUserID[r.UserID] = make(map[int]struct{})
+		}
+		ms.indexOnUserID[r.UserID][idx] = struct{}{}
+		for _, index := range ms.sortedIndexes {
+			index.insert(ms.records, idx)
+		}
+	}
+
+	return nil
+}
+
+// Batch insert records skipping ones conflicting with existing records by
+// original URL or shortened path. Reports which records were inserted
+func (ms *MapStorage) BatchInsert(ctx context.Context, records []models.Record) ([]bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	inserted := make([]bool, len(records))
+	for i, r := range records {
+		if _, ok := ms.indexOnOriginalURL[r.OriginalURL]; ok {
+			continue
+		}
+		if _, ok := ms.indexOnShortenedPath[recordKey(r)]; ok {
+			continue
+		}
+
+		ms.records = append(ms.records, withTimestamps(r, now))
+		idx := len(ms.records) - 1
+		ms.indexOnOriginalURL[r.OriginalURL] = idx
+		ms.indexOnShortenedPath[recordKey(r)] = idx
+		if _, ok := ms.indexOnUserID[r.UserID]; !ok {
+			ms.indexOnUserID[r.UserID] = make(map[int]struct{})
 		}
+		ms.indexOnUserID[r.UserID][idx] = struct{}{}
+		for _, index := range ms.sortedIndexes {
+			index.insert(ms.records, idx)
+		}
+		inserted[i] = true
+	}
+
+	return inserted, nil
+}
+
+// Update user record settings
+func (ms *MapStorage) Update(ctx context.Context, r models.Record) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	idx, ok := ms.indexOnShortenedPath[recordKey(r)]
+	if !ok || ms.records[idx].UserID != r.UserID {
+		return ErrNotFound
 	}
+
+	record := &ms.records[idx]
+	record.ActiveFrom = r.ActiveFrom
+	record.ActiveUntil = r.ActiveUntil
+	record.FallbackURL = r.FallbackURL
+	record.Rules = r.Rules
+	record.Variants = r.Variants
+	record.UTMParams = r.UTMParams
+	record.QueryMode = r.QueryMode
+	record.RedirectCode = r.RedirectCode
+	record.Title = r.Title
+	record.Interstitial = r.Interstitial
+	record.Tags = r.Tags
+	record.Folder = r.Folder
+	record.Description = r.Description
+	record.UpdatedAt = r.UpdatedAt
+	if record.UpdatedAt.IsZero() {
+		record.UpdatedAt = time.Now()
+	}
+
 	return nil
 }
 
 // Batch delete records
 func (ms *MapStorage) BatchDelete(ctx context.Context, records []models.Record) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	for _, r := range records {
-		idx, ok := ms.indexOnShortenedPath[r.ShortenedPath]
+		idx, ok := ms.indexOnShortenedPath[recordKey(r)]
 		if !ok {
 			continue
 		}
@@ -138,8 +305,73 @@ func (ms *MapStorage) BatchDelete(ctx context.Context, records []models.Record)
 	return nil
 }
 
+// Consume one click of click limited record
+func (ms *MapStorage) ConsumeClick(ctx context.Context, domain, shortenedPath string) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	idx, ok := ms.indexOnShortenedPath[shortKey{domain: domain, path: shortenedPath}]
+	if !ok {
+		return 0, ErrNotFound
+	}
+
+	record := &ms.records[idx]
+	if !record.IsClickLimited() {
+		return 0, nil
+	}
+	if record.RemainingClicks <= 0 {
+		return 0, ErrClicksExhausted
+	}
+	record.RemainingClicks--
+
+	return record.RemainingClicks, nil
+}
+
+// Save click on shortened URL
+func (ms *MapStorage) SaveClick(ctx context.Context, click models.Click) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	key := shortKey{domain: click.Domain, path: click.ShortenedPath}
+	if _, ok := ms.indexOnShortenedPath[key]; !ok {
+		return ErrNotFound
+	}
+
+	stats := ms.clicks[key]
+	stats.Total++
+	if click.Variant != "" {
+		if stats.ByVariant == nil {
+			stats.ByVariant = make(map[string]int)
+		}
+		stats.ByVariant[click.Variant]++
+	}
+	ms.clicks[key] = stats
+
+	return nil
+}
+
+// Click statistics of shortened URL
+func (ms *MapStorage) ClickStats(ctx context.Context, domain, shortenedPath string) (models.ClickStats, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	stats := ms.clicks[shortKey{domain: domain, path: shortenedPath}]
+	result := models.ClickStats{Total: stats.Total}
+	if len(stats.ByVariant) > 0 {
+		result.ByVariant = make(map[string]int, len(stats.ByVariant))
+		for variant, count := range stats.ByVariant {
+			result.ByVariant[variant] = count
+		}
+	}
+
+	return result, nil
+}
+
 // Create user
 func (ms *MapStorage) CreateUser(ctx context.Context) (models.User, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	id := ms.userID
 	ms.userID++
 
@@ -148,24 +380,38 @@ func (ms *MapStorage) CreateUser(ctx context.Context) (models.User, error) {
 
 // UsersCount
 func (ms *MapStorage) UsersCount(ctx context.Context) (int, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
 	return ms.userID - 1, nil
 }
 
 // URLsCount
 func (ms *MapStorage) URLsCount(ctx context.Context) (int, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
 	return len(ms.records), nil
 }
 
-// Dump inmemory storage to file
+// Inmemory storage is always reachable
+func (ms *MapStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Dump inmemory storage records to file. Click statistics are not dumped
 func (ms *MapStorage) Dump() error {
 	if ms.fs != nil {
+		ms.mu.RLock()
+		defer ms.mu.RUnlock()
+
 		return ms.fs.Dump(ms)
 	}
 
 	return nil
 }
 
-// Restore inmemory storage from file
+// Restore inmemory storage records from file. Click statistics start from zero
 func (ms *MapStorage) Restore(records []models.Record) {
 	ctx := context.TODO()
 	maxUserID := 0
@@ -177,5 +423,7 @@ func (ms *MapStorage) Restore(records []models.Record) {
 			logger.Log.Info("failed to restore", zap.Error(err))
 		}
 	}
+	ms.mu.Lock()
 	ms.userID = maxUserID + 1
+	ms.mu.Unlock()
 }
@@ -49,6 +49,21 @@ func (mr *MockStorageMockRecorder) BatchDelete(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchDelete", reflect.TypeOf((*MockStorage)(nil).BatchDelete), arg0, arg1)
 }
 
+// BatchInsert mocks base method.
+func (m *MockStorage) BatchInsert(arg0 context.Context, arg1 []models.Record) ([]bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BatchInsert", arg0, arg1)
+	ret0, _ := ret[0].([]bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BatchInsert indicates an expected call of BatchInsert.
+func (mr *MockStorageMockRecorder) BatchInsert(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchInsert", reflect.TypeOf((*MockStorage)(nil).BatchInsert), arg0, arg1)
+}
+
 // BatchSave mocks base method.
 func (m *MockStorage) BatchSave(arg0 context.Context, arg1 []models.Record) error {
 	m.ctrl.T.Helper()
@@ -63,6 +78,36 @@ func (mr *MockStorageMockRecorder) BatchSave(arg0, arg1 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchSave", reflect.TypeOf((*MockStorage)(nil).BatchSave), arg0, arg1)
 }
 
+// ClickStats mocks base method.
+func (m *MockStorage) ClickStats(arg0 context.Context, arg1, arg2 string) (models.ClickStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClickStats", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.ClickStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClickStats indicates an expected call of ClickStats.
+func (mr *MockStorageMockRecorder) ClickStats(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClickStats", reflect.TypeOf((*MockStorage)(nil).ClickStats), arg0, arg1, arg2)
+}
+
+// ConsumeClick mocks base method.
+func (m *MockStorage) ConsumeClick(arg0 context.Context, arg1, arg2 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeClick", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeClick indicates an expected call of ConsumeClick.
+func (mr *MockStorageMockRecorder) ConsumeClick(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeClick", reflect.TypeOf((*MockStorage)(nil).ConsumeClick), arg0, arg1, arg2)
+}
+
 // CreateUser mocks base method.
 func (m *MockStorage) CreateUser(arg0 context.Context) (models.User, error) {
 	m.ctrl.T.Helper()
@@ -78,6 +123,20 @@ func (mr *MockStorageMockRecorder) CreateUser(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStorage)(nil).CreateUser), arg0)
 }
 
+// ExportByUser mocks base method.
+func (m *MockStorage) ExportByUser(arg0 context.Context, arg1 models.User, arg2 bool, arg3 func(models.Record, int) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportByUser", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportByUser indicates an expected call of ExportByUser.
+func (mr *MockStorageMockRecorder) ExportByUser(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportByUser", reflect.TypeOf((*MockStorage)(nil).ExportByUser), arg0, arg1, arg2, arg3)
+}
+
 // FindByOriginalURL mocks base method.
 func (m *MockStorage) FindByOriginalURL(arg0 context.Context, arg1 string) (models.Record, error) {
 	m.ctrl.T.Helper()
@@ -94,33 +153,63 @@ func (mr *MockStorageMockRecorder) FindByOriginalURL(arg0, arg1 interface{}) *go
 }
 
 // FindByShortenedPath mocks base method.
-func (m *MockStorage) FindByShortenedPath(arg0 context.Context, arg1 string) (models.Record, error) {
+func (m *MockStorage) FindByShortenedPath(arg0 context.Context, arg1, arg2 string) (models.Record, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindByShortenedPath", arg0, arg1)
+	ret := m.ctrl.Call(m, "FindByShortenedPath", arg0, arg1, arg2)
 	ret0, _ := ret[0].(models.Record)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindByShortenedPath indicates an expected call of FindByShortenedPath.
-func (mr *MockStorageMockRecorder) FindByShortenedPath(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) FindByShortenedPath(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByShortenedPath", reflect.TypeOf((*MockStorage)(nil).FindByShortenedPath), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByShortenedPath", reflect.TypeOf((*MockStorage)(nil).FindByShortenedPath), arg0, arg1, arg2)
 }
 
 // FindByUser mocks base method.
-func (m *MockStorage) FindByUser(arg0 context.Context, arg1 models.User) ([]models.Record, error) {
+func (m *MockStorage) FindByUser(arg0 context.Context, arg1 models.User, arg2 models.RecordFilter) ([]models.Record, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindByUser", arg0, arg1)
+	ret := m.ctrl.Call(m, "FindByUser", arg0, arg1, arg2)
 	ret0, _ := ret[0].([]models.Record)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindByUser indicates an expected call of FindByUser.
-func (mr *MockStorageMockRecorder) FindByUser(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) FindByUser(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUser", reflect.TypeOf((*MockStorage)(nil).FindByUser), arg0, arg1, arg2)
+}
+
+// FindByUserPage mocks base method.
+func (m *MockStorage) FindByUserPage(arg0 context.Context, arg1 models.User, arg2 models.RecordFilter, arg3 models.Page) ([]models.Record, *models.Cursor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByUserPage", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.Record)
+	ret1, _ := ret[1].(*models.Cursor)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FindByUserPage indicates an expected call of FindByUserPage.
+func (mr *MockStorageMockRecorder) FindByUserPage(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUserPage", reflect.TypeOf((*MockStorage)(nil).FindByUserPage), arg0, arg1, arg2, arg3)
+}
+
+// Ping mocks base method.
+func (m *MockStorage) Ping(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockStorageMockRecorder) Ping(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUser", reflect.TypeOf((*MockStorage)(nil).FindByUser), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStorage)(nil).Ping), arg0)
 }
 
 // Save mocks base method.
@@ -137,6 +226,20 @@ func (mr *MockStorageMockRecorder) Save(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockStorage)(nil).Save), arg0, arg1)
 }
 
+// SaveClick mocks base method.
+func (m *MockStorage) SaveClick(arg0 context.Context, arg1 models.Click) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveClick", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveClick indicates an expected call of SaveClick.
+func (mr *MockStorageMockRecorder) SaveClick(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveClick", reflect.TypeOf((*MockStorage)(nil).SaveClick), arg0, arg1)
+}
+
 // URLsCount mocks base method.
 func (m *MockStorage) URLsCount(arg0 context.Context) (int, error) {
 	m.ctrl.T.Helper()
@@ -152,6 +255,20 @@ func (mr *MockStorageMockRecorder) URLsCount(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "URLsCount", reflect.TypeOf((*MockStorage)(nil).URLsCount), arg0)
 }
 
+// Update mocks base method.
+func (m *MockStorage) Update(arg0 context.Context, arg1 models.Record) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockStorageMockRecorder) Update(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockStorage)(nil).Update), arg0, arg1)
+}
+
 // UsersCount mocks base method.
 func (m *MockStorage) UsersCount(arg0 context.Context) (int, error) {
 	m.ctrl.T.Helper()
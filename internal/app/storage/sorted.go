@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+)
+
+// Positions of user records sorted by key. Keys are unique since they end
+// with domain and shortened path
+type sortedIndex struct {
+	less   func(a, b models.Record) bool
+	byUser map[int][]int
+}
+
+func newSortedIndex(less func(a, b models.Record) bool) *sortedIndex {
+	return &sortedIndex{less: less, byUser: make(map[int][]int)}
+}
+
+func lessByCreatedAt(a, b models.Record) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	if a.Domain != b.Domain {
+		return a.Domain < b.Domain
+	}
+
+	return a.ShortenedPath < b.ShortenedPath
+}
+
+func lessByCode(a, b models.Record) bool {
+	if a.ShortenedPath != b.ShortenedPath {
+		return a.ShortenedPath < b.ShortenedPath
+	}
+
+	return a.Domain < b.Domain
+}
+
+func (si *sortedIndex) insert(records []models.Record, idx int) {
+	r := records[idx]
+	ids := si.byUser[r.UserID]
+	pos := sort.Search(len(ids), func(i int) bool { return si.less(r, records[ids[i]]) })
+	ids = append(ids, 0)
+	copy(ids[pos+1:], ids[pos:])
+	ids[pos] = idx
+	si.byUser[r.UserID] = ids
+}
+
+// Must be called before record at idx is changed
+func (si *sortedIndex) remove(records []models.Record, idx int) {
+	r := records[idx]
+	ids := si.byUser[r.UserID]
+	pos := sort.Search(len(ids), func(i int) bool { return !si.less(records[ids[i]], r) })
+	if pos < len(ids) && ids[pos] == idx {
+		si.byUser[r.UserID] = append(ids[:pos], ids[pos+1:]...)
+	}
+}
+
+// Visit user records strictly after cursor in sort order until visit returns false
+func (si *sortedIndex) scan(
+	records []models.Record,
+	userID int,
+	after *models.Cursor,
+	desc bool,
+	visit func(idx int) bool) {
+
+	ids := si.byUser[userID]
+	var pivot models.Record
+	if after != nil {
+		pivot = models.Record{CreatedAt: after.CreatedAt, Domain: after.Domain, ShortenedPath: after.ShortenedPath}
+	}
+
+	if !desc {
+		start := 0
+		if after != nil {
+			start = sort.Search(len(ids), func(i int) bool { return si.less(pivot, records[ids[i]]) })
+		}
+		for i := start; i < len(ids); i++ {
+			if !visit(ids[i]) {
+				return
+			}
+		}
+		return
+	}
+
+	start := len(ids) - 1
+	if after != nil {
+		start = sort.Search(len(ids), func(i int) bool { return !si.less(records[ids[i]], pivot) }) - 1
+	}
+	for i := start; i >= 0; i-- {
+		if !visit(ids[i]) {
+			return
+		}
+	}
+}
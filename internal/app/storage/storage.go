@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/ilya-burinskiy/urlshort/internal/app/models"
 )
@@ -11,6 +12,9 @@ import (
 // Not found error
 var ErrNotFound = errors.New("not found")
 
+// No clicks left error
+var ErrClicksExhausted = errors.New("clicks exhausted")
+
 // Record not unique error
 type ErrNotUnique struct {
 	Record models.Record
@@ -29,13 +33,44 @@ func (err *ErrNotUnique) Error() string {
 // Storage interface
 type Storage interface {
 	FindByOriginalURL(ctx context.Context, originalURL string) (models.Record, error)
-	FindByShortenedPath(ctx context.Context, shortenedPath string) (models.Record, error)
-	FindByUser(ctx context.Context, user models.User) ([]models.Record, error)
+	FindByShortenedPath(ctx context.Context, domain, shortenedPath string) (models.Record, error)
+	FindByUser(ctx context.Context, user models.User, filter models.RecordFilter) ([]models.Record, error)
+	FindByUserPage(
+		ctx context.Context,
+		user models.User,
+		filter models.RecordFilter,
+		page models.Page,
+	) ([]models.Record, *models.Cursor, error)
+	ExportByUser(
+		ctx context.Context,
+		user models.User,
+		includeDeleted bool,
+		visit func(record models.Record, clicks int) error,
+	) error
 	Save(ctx context.Context, record models.Record) error
 	BatchSave(ctx context.Context, records []models.Record) error
+	BatchInsert(ctx context.Context, records []models.Record) ([]bool, error)
+	Update(ctx context.Context, record models.Record) error
 	BatchDelete(ctx context.Context, records []models.Record) error
+	ConsumeClick(ctx context.Context, domain, shortenedPath string) (int, error)
+	SaveClick(ctx context.Context, click models.Click) error
+	ClickStats(ctx context.Context, domain, shortenedPath string) (models.ClickStats, error)
 	URLsCount(ctx context.Context) (int, error)
 	UsersCount(ctx context.Context) (int, error)
+	Ping(ctx context.Context) error
 
 	CreateUser(ctx context.Context) (models.User, error)
 }
+
+// Fill zero timestamps of saved record. Records restored from old snapshots
+// have no timestamps
+func withTimestamps(r models.Record, now time.Time) models.Record {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = now
+	}
+	if r.UpdatedAt.IsZero() {
+		r.UpdatedAt = r.CreatedAt
+	}
+
+	return r
+}
@@ -1 +1,473 @@
 package storage_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/models"
+	"github.com/ilya-burinskiy/urlshort/internal/app/storage"
+)
+
+func TestMapStorageConsumeClick(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMapStorage(nil)
+	err := store.Save(ctx, models.Record{
+		OriginalURL:     "http://example.com",
+		ShortenedPath:   "123",
+		MaxClicks:       10,
+		RemainingClicks: 10,
+	})
+	require.NoError(t, err)
+
+	var consumed, exhausted int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.ConsumeClick(ctx, "", "123")
+			switch err {
+			case nil:
+				atomic.AddInt64(&consumed, 1)
+			case storage.ErrClicksExhausted:
+				atomic.AddInt64(&exhausted, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(10), consumed)
+	assert.Equal(t, int64(40), exhausted)
+
+	record, err := store.FindByShortenedPath(ctx, "", "123")
+	require.NoError(t, err)
+	assert.Equal(t, 0, record.RemainingClicks)
+
+	_, err = store.ConsumeClick(ctx, "", "321")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestMapStorageClickStats(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMapStorage(nil)
+	err := store.Save(ctx, models.Record{OriginalURL: "http://example.com", ShortenedPath: "123"})
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveClick(ctx, models.Click{ShortenedPath: "123", Variant: "a"}))
+	require.NoError(t, store.SaveClick(ctx, models.Click{ShortenedPath: "123", Variant: "b"}))
+	require.NoError(t, store.SaveClick(ctx, models.Click{ShortenedPath: "123", Variant: "b"}))
+	assert.ErrorIs(t, store.SaveClick(ctx, models.Click{ShortenedPath: "321"}), storage.ErrNotFound)
+
+	stats, err := store.ClickStats(ctx, "", "123")
+	require.NoError(t, err)
+	assert.Equal(t, models.ClickStats{Total: 3, ByVariant: map[string]int{"a": 1, "b": 2}}, stats)
+}
+
+func TestMapStorageSaveNotUnique(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMapStorage(nil)
+	err := store.Save(ctx, models.Record{OriginalURL: "http://example.com", ShortenedPath: "123", UserID: 1})
+	require.NoError(t, err)
+
+	err = store.Save(ctx, models.Record{OriginalURL: "http://example.com", ShortenedPath: "321", UserID: 2})
+	var notUniqErr *storage.ErrNotUnique
+	require.ErrorAs(t, err, &notUniqErr)
+	assert.Equal(t, "123", notUniqErr.Record.ShortenedPath)
+	assert.Equal(t, 1, notUniqErr.Record.UserID)
+}
+
+func TestMapStorageBatchSaveNotUnique(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMapStorage(nil)
+	rules := []models.RoutingRule{{Field: "country", Pattern: "DE", Destination: "http://example.de"}}
+	variants := []models.Variant{{Name: "a", URL: "http://example.com/a", Weight: 1}}
+	err := store.Save(ctx, models.Record{
+		OriginalURL:   "http://example.com",
+		ShortenedPath: "123",
+		UserID:        1,
+		Title:         "Example",
+		Rules:         rules,
+		Variants:      variants,
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		records []models.Record
+		want    string
+	}{
+		{
+			name: "conflicts by original URL",
+			records: []models.Record{
+				{OriginalURL: "http://example.org", ShortenedPath: "456", UserID: 2},
+				{
+					OriginalURL:   "http://example.com",
+					ShortenedPath: "321",
+					UserID:        2,
+					Title:         "Changed",
+					Variants:      []models.Variant{{Name: "b", URL: "http://example.com/b", Weight: 1}},
+				},
+			},
+			want: "123",
+		},
+		{
+			name: "conflicts by shortened path",
+			records: []models.Record{{
+				OriginalURL:   "http://example.net",
+				ShortenedPath: "123",
+				UserID:        2,
+				Rules:         []models.RoutingRule{{Field: "country", Pattern: "FR", Destination: "http://example.fr"}},
+			}},
+			want: "123",
+		},
+		{
+			name: "conflicts within batch",
+			records: []models.Record{
+				{OriginalURL: "http://example.net", ShortenedPath: "456", UserID: 2},
+				{OriginalURL: "http://example.net", ShortenedPath: "789", UserID: 2},
+			},
+			want: "789",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := store.BatchSave(ctx, tc.records)
+			var notUniqErr *storage.ErrNotUnique
+			require.ErrorAs(t, err, &notUniqErr)
+			assert.Equal(t, tc.want, notUniqErr.Record.ShortenedPath)
+
+			record, err := store.FindByShortenedPath(ctx, "", "123")
+			require.NoError(t, err)
+			assert.Equal(t, 1, record.UserID)
+			assert.Equal(t, "Example", record.Title)
+			assert.Equal(t, rules, record.Rules)
+			assert.Equal(t, variants, record.Variants)
+			for _, r := range tc.records {
+				if r.ShortenedPath != "123" {
+					_, err = store.FindByShortenedPath(ctx, "", r.ShortenedPath)
+					assert.ErrorIs(t, err, storage.ErrNotFound)
+				}
+			}
+		})
+	}
+}
+
+func TestMapStorageDomains(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMapStorage(nil)
+	err := store.Save(ctx, models.Record{OriginalURL: "http://example.com", ShortenedPath: "123"})
+	require.NoError(t, err)
+	err = store.Save(ctx, models.Record{OriginalURL: "http://example.org", Domain: "sho.rt", ShortenedPath: "123"})
+	require.NoError(t, err)
+
+	record, err := store.FindByShortenedPath(ctx, "", "123")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com", record.OriginalURL)
+
+	record, err = store.FindByShortenedPath(ctx, "sho.rt", "123")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.org", record.OriginalURL)
+
+	_, err = store.FindByShortenedPath(ctx, "go.example.com", "123")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestMapStorageFindByUserFilter(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMapStorage(nil)
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	records := []models.Record{
+		{
+			OriginalURL:   "http://example.com/docs",
+			ShortenedPath: "abc",
+			UserID:        1,
+			Tags:          []string{"work", "docs"},
+			Folder:        "projects",
+			CreatedAt:     createdAt,
+		},
+		{
+			OriginalURL:   "http://example.org",
+			ShortenedPath: "def",
+			UserID:        1,
+			Tags:          []string{"work"},
+			CreatedAt:     createdAt.Add(48 * time.Hour),
+		},
+		{
+			OriginalURL:   "http://example.net",
+			ShortenedPath: "ghi",
+			UserID:        2,
+			Tags:          []string{"work"},
+			CreatedAt:     createdAt,
+		},
+	}
+	for _, r := range records {
+		require.NoError(t, store.Save(ctx, r))
+	}
+	createdTo := createdAt.Add(24 * time.Hour)
+
+	testCases := []struct {
+		name   string
+		filter models.RecordFilter
+		want   []string
+	}{
+		{
+			name: "returns all user records without filter",
+			want: []string{"abc", "def"},
+		},
+		{
+			name:   "filters by every tag",
+			filter: models.RecordFilter{Tags: []string{"work", "docs"}},
+			want:   []string{"abc"},
+		},
+		{
+			name:   "filters by folder",
+			filter: models.RecordFilter{Folder: "projects"},
+			want:   []string{"abc"},
+		},
+		{
+			name:   "filters by original URL substring",
+			filter: models.RecordFilter{Query: "EXAMPLE.ORG"},
+			want:   []string{"def"},
+		},
+		{
+			name:   "filters by shortened path substring",
+			filter: models.RecordFilter{Query: "bc"},
+			want:   []string{"abc"},
+		},
+		{
+			name:   "filters by created range",
+			filter: models.RecordFilter{CreatedFrom: &createdAt, CreatedTo: &createdTo},
+			want:   []string{"abc"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := store.FindByUser(ctx, models.User{ID: 1}, tc.filter)
+			require.NoError(t, err)
+
+			paths := make([]string, len(result))
+			for i, r := range result {
+				paths[i] = r.ShortenedPath
+			}
+			assert.ElementsMatch(t, tc.want, paths)
+		})
+	}
+}
+
+func TestMapStorageFindByUserPage(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMapStorage(nil)
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for i, path := range []string{"d", "b", "e", "a", "c"} {
+		err := store.Save(ctx, models.Record{
+			OriginalURL:   "http://example.com/" + path,
+			ShortenedPath: path,
+			UserID:        1,
+			CreatedAt:     createdAt.Add(time.Duration(i/2) * time.Hour),
+		})
+		require.NoError(t, err)
+	}
+	err := store.Save(ctx, models.Record{OriginalURL: "http://example.org", ShortenedPath: "f", UserID: 2})
+	require.NoError(t, err)
+
+	collect := func(t *testing.T, page models.Page, filter models.RecordFilter) [][]string {
+		var pages [][]string
+		for {
+			records, next, err := store.FindByUserPage(ctx, models.User{ID: 1}, filter, page)
+			require.NoError(t, err)
+			paths := make([]string, len(records))
+			for i, r := range records {
+				paths[i] = r.ShortenedPath
+			}
+			pages = append(pages, paths)
+			if next == nil {
+				return pages
+			}
+			page.After = next
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		page   models.Page
+		filter models.RecordFilter
+		want   [][]string
+	}{
+		{
+			name: "sorts by created at breaking ties by code",
+			page: models.Page{Limit: 2, SortBy: models.SortByCreatedAt},
+			want: [][]string{{"b", "d"}, {"a", "e"}, {"c"}},
+		},
+		{
+			name: "sorts by created at descending",
+			page: models.Page{Limit: 2, SortBy: models.SortByCreatedAt, Desc: true},
+			want: [][]string{{"c", "e"}, {"a", "d"}, {"b"}},
+		},
+		{
+			name: "sorts by code",
+			page: models.Page{Limit: 3, SortBy: models.SortByCode},
+			want: [][]string{{"a", "b", "c"}, {"d", "e"}},
+		},
+		{
+			name: "returns every record without limit",
+			page: models.Page{SortBy: models.SortByCode, Desc: true},
+			want: [][]string{{"e", "d", "c", "b", "a"}},
+		},
+		{
+			name:   "applies filter",
+			page:   models.Page{Limit: 1, SortBy: models.SortByCode},
+			filter: models.RecordFilter{Query: "/a"},
+			want:   [][]string{{"a"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, collect(t, tc.page, tc.filter))
+		})
+	}
+
+	t.Run("keeps order after batch save", func(t *testing.T) {
+		err := store.BatchSave(ctx, []models.Record{{
+			OriginalURL:   "http://example.com/g",
+			ShortenedPath: "g",
+			UserID:        1,
+			CreatedAt:     createdAt.Add(-time.Hour),
+		}})
+		require.NoError(t, err)
+
+		page := models.Page{SortBy: models.SortByCreatedAt}
+		assert.Equal(t, [][]string{{"g", "b", "d", "a", "e", "c"}}, collect(t, page, models.RecordFilter{}))
+	})
+}
+
+func TestFileStorageSnapshotWithoutTimestamps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+	err := os.WriteFile(
+		path,
+		[]byte(`{"original_url":"http://example.com","shortened_path":"123","correlation_id":"","user_id":1,"is_deleted":false}`+"\n"),
+		0666,
+	)
+	require.NoError(t, err)
+
+	fs := storage.NewFileStorage(path)
+	records, err := fs.Snapshot()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	before := time.Now()
+	store := storage.NewMapStorage(fs)
+	store.Restore(records)
+	record, err := store.FindByShortenedPath(context.Background(), "", "123")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com", record.OriginalURL)
+	assert.False(t, record.CreatedAt.Before(before))
+	assert.Equal(t, record.CreatedAt, record.UpdatedAt)
+	require.NoError(t, store.SaveClick(context.Background(), models.Click{ShortenedPath: "123"}))
+
+	require.NoError(t, store.Dump())
+	records, err = fs.Snapshot()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.True(t, records[0].CreatedAt.Equal(record.CreatedAt))
+
+	// Click statistics are not dumped
+	restored := storage.NewMapStorage(fs)
+	restored.Restore(records)
+	stats, err := restored.ClickStats(context.Background(), "", "123")
+	require.NoError(t, err)
+	assert.Equal(t, models.ClickStats{}, stats)
+}
+
+func TestFileStorageCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+	lines := []string{
+		`{"original_url":"http://example.com","shortened_path":"1","user_id":1}`,
+		`not a record`,
+		`{"original_url":"http://example.org","shortened_path":"2","user_id":2,"is_deleted":true}`,
+		`{"original_url":"http://example.com","shortened_path":"3","user_id":1}`,
+		`{"original_url":"http://example.net","shortened_path":"2","user_id":1}`,
+		`{"original_url":"","shortened_path":"4","user_id":1}`,
+		`{"original_url":"http://example.net","shortened_path":"2","domain":"go.example.com","user_id":3}`,
+	}
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0640))
+	fs := storage.NewFileStorage(path)
+
+	report, err := fs.Verify()
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, storage.SnapshotReport{
+		Lines:          7,
+		Records:        3,
+		Deleted:        1,
+		Users:          3,
+		MalformedLines: []int{2},
+		InvalidLines:   []int{6},
+		DuplicateLines: []int{4, 5},
+	}, report)
+
+	compacted, err := fs.Compact(false)
+	require.NoError(t, err)
+	assert.Equal(t, report, compacted)
+	records, err := fs.Snapshot()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "1", records[0].ShortenedPath)
+	assert.True(t, records[1].IsDeleted)
+	assert.Equal(t, "go.example.com", records[2].Domain)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	report, err = fs.Verify()
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+
+	_, err = fs.Compact(true)
+	require.NoError(t, err)
+	records, err = fs.Snapshot()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	for _, record := range records {
+		assert.False(t, record.IsDeleted)
+	}
+
+	_, err = storage.NewFileStorage(filepath.Join(t.TempDir(), "missing.json")).Verify()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Rollbacks drop tables and columns they added, but must not delete links
+// kept by previous schema versions
+func TestDownMigrationsDoNotDeleteURLs(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("db", "migrations", "*.down.sql"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+	for _, path := range paths {
+		migration, err := os.ReadFile(path)
+		require.NoError(t, err)
+		sql := strings.ToUpper(string(migration))
+		assert.NotContains(t, sql, `DELETE FROM "URLS"`, path)
+		assert.NotContains(t, sql, `TRUNCATE`, path)
+	}
+}
+
+func TestLatestSchemaVersion(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("db", "migrations", "*.up.sql"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	version, err := storage.LatestSchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, uint(len(paths)), version)
+}
@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Postgres queries tracer, set as tracer of pgx connection config. Span of
+// query is named by its SQL operation, batch queries are events of batch span
+type QueryTracer struct{}
+
+var (
+	_ pgx.QueryTracer = QueryTracer{}
+	_ pgx.BatchTracer = QueryTracer{}
+)
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = Start(
+		ctx,
+		sqlOperation(data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperation(sqlOperation(data.SQL)),
+			semconv.DBStatement(data.SQL),
+		),
+	)
+
+	return ctx
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	End(span, queryError(data.Err))
+}
+
+func (QueryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	ctx, _ = Start(
+		ctx,
+		"BATCH",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperation("BATCH"),
+			attribute.Int("db.batch.size", data.Batch.Len()),
+		),
+	)
+
+	return ctx
+}
+
+func (QueryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	attrs := []attribute.KeyValue{semconv.DBStatement(data.SQL)}
+	if err := queryError(data.Err); err != nil {
+		attrs = append(attrs, attribute.String("error", err.Error()))
+	}
+	trace.SpanFromContext(ctx).AddEvent("query", trace.WithAttributes(attrs...))
+}
+
+func (QueryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	End(trace.SpanFromContext(ctx), queryError(data.Err))
+}
+
+// First keyword of SQL statement
+func sqlOperation(sql string) string {
+	if fields := strings.Fields(sql); len(fields) > 0 {
+		return strings.ToUpper(fields[0])
+	}
+
+	return "QUERY"
+}
+
+// Query error, not found rows are not a failure
+func queryError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,111 @@
+// Package tracing sets up OpenTelemetry tracing of the shortener. Spans are
+// created with the global tracer provider, W3C trace context of incoming HTTP
+// requests and gRPC calls is continued
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName = "urlshort"
+	tracerName  = "github.com/ilya-burinskiy/urlshort"
+)
+
+// Span exporters
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Initialize global tracer provider and propagator. Spans are exported by
+// exporter: OTLP over gRPC to endpoint, stdout or nowhere if exporter is
+// empty or none. Endpoint is host:port using TLS or URL, http scheme
+// disables TLS. Returned function flushes spans and stops the provider
+func Initialize(exporter, endpoint, version string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	if exporter == "" || exporter == ExporterNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	spanExporter, err := newExporter(exporter, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build tracing resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(exporter, endpoint string) (sdktrace.SpanExporter, error) {
+	switch exporter {
+	case ExporterOTLP:
+		var opts []otlptracegrpc.Option
+		if strings.Contains(endpoint, "://") {
+			opts = append(opts, otlptracegrpc.WithEndpointURL(endpoint))
+		} else if endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
+		}
+		// Exporter connects lazily, so unavailable collector does not stop the server
+		spanExporter, err := otlptracegrpc.New(context.Background(), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create otlp exporter: %w", err)
+		}
+		return spanExporter, nil
+	case ExporterStdout:
+		spanExporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout exporter: %w", err)
+		}
+		return spanExporter, nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter \"%s\"", exporter)
+	}
+}
+
+// Start span of operation. Spans are dropped until Initialize sets exporter
+func Start(
+	ctx context.Context,
+	name string,
+	opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// Record err in span and mark span failed, if err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,189 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/ilya-burinskiy/urlshort/internal/app/tracing"
+)
+
+const (
+	traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentID    = "00f067aa0ba902b7"
+	traceparent = "00-" + traceID + "-" + parentID + "-01"
+)
+
+// Record spans in memory
+func setupExporter(t *testing.T) *tracetest.InMemoryExporter {
+	_, err := tracing.Initialize(tracing.ExporterNone, "", "test")
+	require.NoError(t, err)
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		require.NoError(t, provider.Shutdown(context.Background()))
+	})
+
+	return exporter
+}
+
+// Span by name
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not found", "no span %s", name)
+
+	return tracetest.SpanStub{}
+}
+
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	result := make(map[attribute.Key]attribute.Value)
+	for _, attr := range span.Attributes {
+		result[attr.Key] = attr.Value
+	}
+
+	return result
+}
+
+func TestMiddleware(t *testing.T) {
+	exporter := setupExporter(t)
+	router := chi.NewRouter()
+	router.Use(tracing.Middleware)
+	router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.Start(r.Context(), "handler")
+		span.End()
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	request.Header.Set("traceparent", traceparent)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	server := findSpan(t, spans, "GET /{id}")
+	handler := findSpan(t, spans, "handler")
+	assert.Equal(t, traceID, server.SpanContext.TraceID().String())
+	assert.Equal(t, parentID, server.Parent.SpanID().String())
+	assert.True(t, server.Parent.IsRemote())
+	assert.Equal(t, server.SpanContext.SpanID(), handler.Parent.SpanID())
+	assert.Equal(t, "/{id}", attributes(server)["http.route"].AsString())
+	assert.Equal(t, int64(http.StatusTemporaryRedirect), attributes(server)["http.response.status_code"].AsInt64())
+	assert.Equal(t, codes.Unset, server.Status.Code)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	exporter := setupExporter(t)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", traceparent))
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+
+	_, err := tracing.UnaryServerInterceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, span := tracing.Start(ctx, "handler")
+		span.End()
+		return nil, status.Error(grpccodes.NotFound, "not found")
+	})
+	require.Error(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	server := findSpan(t, spans, "test.Service/Method")
+	handler := findSpan(t, spans, "handler")
+	assert.Equal(t, traceID, server.SpanContext.TraceID().String())
+	assert.Equal(t, parentID, server.Parent.SpanID().String())
+	assert.Equal(t, server.SpanContext.SpanID(), handler.Parent.SpanID())
+	assert.Equal(t, "test.Service", attributes(server)["rpc.service"].AsString())
+	assert.Equal(t, "Method", attributes(server)["rpc.method"].AsString())
+	assert.Equal(t, int64(grpccodes.NotFound), attributes(server)["rpc.grpc.status_code"].AsInt64())
+	assert.Equal(t, codes.Error, server.Status.Code)
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	exporter := setupExporter(t)
+	ctx, span := tracing.Start(context.Background(), "client")
+	ctx = metadata.AppendToOutgoingContext(ctx, "jwt", "token")
+
+	var sent metadata.MD
+	err := tracing.UnaryClientInterceptor(ctx, "/test.Service/Method", nil, nil, nil, func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		opts ...grpc.CallOption) error {
+
+		sent, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	})
+	require.NoError(t, err)
+	span.End()
+
+	client := findSpan(t, exporter.GetSpans(), "client")
+	assert.Equal(t, []string{"token"}, sent.Get("jwt"))
+	require.Len(t, sent.Get("traceparent"), 1)
+	assert.Contains(t, sent.Get("traceparent")[0], client.SpanContext.SpanID().String())
+}
+
+func TestQueryTracer(t *testing.T) {
+	exporter := setupExporter(t)
+	tracer := tracing.QueryTracer{}
+	ctx := context.Background()
+
+	queryCtx := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "select id from urls where id = $1"})
+	tracer.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{Err: pgx.ErrNoRows})
+	queryCtx = tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "UPDATE urls SET is_deleted = true"})
+	tracer.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{
+		CommandTag: pgconn.NewCommandTag("UPDATE 3"),
+		Err:        errors.New("connection reset"),
+	})
+
+	batch := &pgx.Batch{}
+	batch.Queue("INSERT INTO urls VALUES ($1)", 1)
+	batch.Queue("INSERT INTO urls VALUES ($1)", 2)
+	batchCtx := tracer.TraceBatchStart(ctx, nil, pgx.TraceBatchStartData{Batch: batch})
+	tracer.TraceBatchQuery(batchCtx, nil, pgx.TraceBatchQueryData{SQL: "INSERT INTO urls VALUES ($1)"})
+	tracer.TraceBatchQuery(batchCtx, nil, pgx.TraceBatchQueryData{SQL: "INSERT INTO urls VALUES ($1)"})
+	tracer.TraceBatchEnd(batchCtx, nil, pgx.TraceBatchEndData{})
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	selectSpan := findSpan(t, spans, "SELECT")
+	assert.Equal(t, "postgresql", attributes(selectSpan)["db.system"].AsString())
+	assert.Equal(t, "select id from urls where id = $1", attributes(selectSpan)["db.statement"].AsString())
+	assert.Equal(t, codes.Unset, selectSpan.Status.Code)
+	updateSpan := findSpan(t, spans, "UPDATE")
+	assert.Equal(t, int64(3), attributes(updateSpan)["db.rows_affected"].AsInt64())
+	assert.Equal(t, codes.Error, updateSpan.Status.Code)
+	batchSpan := findSpan(t, spans, "BATCH")
+	assert.Equal(t, int64(2), attributes(batchSpan)["db.batch.size"].AsInt64())
+	assert.Len(t, batchSpan.Events, 2)
+}
+
+func TestInitialize(t *testing.T) {
+	_, err := tracing.Initialize("jaeger", "", "test")
+	assert.Error(t, err)
+
+	shutdown, err := tracing.Initialize(tracing.ExporterOTLP, "http://localhost:1", "test")
+	require.NoError(t, err)
+	// Nothing to flush, so unavailable collector is not an error
+	assert.NoError(t, shutdown(context.Background()))
+}
@@ -14,7 +14,28 @@ var Analyzer = &analysis.Analyzer{
 	Run:  run,
 }
 
+// Comma separated import paths of packages, whose main function may call os.Exit
+var exempt string
+
+func init() {
+	Analyzer.Flags.StringVar(&exempt, "exempt", "", "comma separated import paths of packages whose main may call os.Exit")
+}
+
+func isExempt(pkgPath string) bool {
+	for _, path := range strings.Split(exempt, ",") {
+		if path != "" && path == pkgPath {
+			return true
+		}
+	}
+
+	return false
+}
+
 func run(pass *analysis.Pass) (interface{}, error) {
+	if isExempt(pass.Pkg.Path()) {
+		return nil, nil
+	}
+
 	checkForExitCalls := func(node ast.Node) {
 		ast.Inspect(node, func(node ast.Node) bool {
 			callExpr, isCallExpr := node.(*ast.CallExpr)
@@ -4,9 +4,19 @@ import (
 	"testing"
 
 	"github.com/ilya-burinskiy/urlshort/pkg/exitizer"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/tools/go/analysis/analysistest"
 )
 
 func TestExitizer(t *testing.T) {
-	analysistest.Run(t, analysistest.TestData(), exitizer.Analyzer, "./...")
+	analysistest.Run(t, analysistest.TestData(), exitizer.Analyzer, "exitcall")
+}
+
+func TestExitizerExempt(t *testing.T) {
+	require.NoError(t, exitizer.Analyzer.Flags.Set("exempt", "other,exempt"))
+	t.Cleanup(func() {
+		require.NoError(t, exitizer.Analyzer.Flags.Set("exempt", ""))
+	})
+
+	analysistest.Run(t, analysistest.TestData(), exitizer.Analyzer, "exempt")
 }